@@ -1,17 +1,33 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"rag-go-app/models"
+	"rag-go-app/parser"
+	"rag-go-app/retrieval"
 	"rag-go-app/service"
+	"rag-go-app/utils"
 
 	"github.com/gorilla/mux"
 )
 
 // Routes struct to hold the services
 type Routes struct {
-	DataService *service.DataService
-	FileService *service.FileService
+	DataService      *service.DataService
+	FileService      *service.FileService
+	ChunkService     *service.ChunkService
+	EmbeddingService *service.EmbeddingService
+	SearchService    *service.SearchService
+	AskService       *service.AskService
 }
 
 // NewRouter initializes the router and sets up the routes
@@ -19,9 +35,25 @@ func NewRouter(routes Routes) *mux.Router {
 	router := mux.NewRouter()
 
 	// Document routes
-	router.HandleFunc("/documents", createDocumentHandler(routes.DataService)).Methods("POST")
+	router.HandleFunc("/documents", createDocumentHandler(routes.DataService, routes.FileService)).Methods("POST")
 	router.HandleFunc("/documents/{id:[0-9]+}", getDocumentHandler(routes.DataService)).Methods("GET")
 	router.HandleFunc("/documents/{id:[0-9]+}", updateDocumentHandler(routes.DataService)).Methods("PUT")
+	router.HandleFunc("/documents/{id:[0-9]+}/finalize", finalizeDocumentHandler(routes.DataService)).Methods("POST")
+	router.HandleFunc("/documents/{id:[0-9]+}/chunks", listChunksHandler(routes.DataService, routes.ChunkService)).Methods("GET")
+	router.HandleFunc("/documents/{id:[0-9]+}/toc", getTableOfContentsHandler(routes.DataService)).Methods("GET")
+	router.HandleFunc("/documents/{id:[0-9]+}/extraction", getExtractionReportHandler(routes.DataService)).Methods("GET")
+	router.HandleFunc("/documents/flagged", listFlaggedDocumentsHandler(routes.DataService)).Methods("GET")
+	router.HandleFunc("/documents/by-author", listDocumentsByAuthorHandler(routes.DataService)).Methods("GET")
+
+	// Parser routes
+	router.HandleFunc("/parsers", listParsersHandler(routes.DataService)).Methods("GET")
+
+	// Search routes
+	router.HandleFunc("/search", searchHandler(routes.SearchService)).Methods("GET")
+	router.HandleFunc("/ask", askHandler(routes.AskService)).Methods("POST")
+
+	// Embedding routes
+	router.HandleFunc("/embedding-models", listEmbeddingModelsHandler(routes.EmbeddingService)).Methods("GET")
 
 	// File routes
 	router.HandleFunc("/files", uploadFileHandler(routes.FileService)).Methods("POST")
@@ -31,10 +63,60 @@ func NewRouter(routes Routes) *mux.Router {
 	return router
 }
 
+// createDocumentRequest is the body accepted by POST /documents. FileID is
+// the ID of a file uploaded through POST /files.
+type createDocumentRequest struct {
+	FileID   int64  `json:"file_id"`
+	Password string `json:"password,omitempty"`
+}
+
 // Handler functions for documents
-func createDocumentHandler(dataService *service.DataService) http.HandlerFunc {
+func createDocumentHandler(dataService *service.DataService, fileService *service.FileService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Logic to create a document
+		var req createDocumentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid request body")
+			return
+		}
+		if req.FileID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid_request", "file_id is required")
+			return
+		}
+
+		file, err := fileService.GetFile(req.FileID)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		doc, err := dataService.CreateDocumentStream(r.Context(), file, req.Password, nil)
+		if err != nil {
+			var pwErr *parser.PasswordRequiredError
+			if errors.As(err, &pwErr) {
+				code := "password_required"
+				if pwErr.PasswordSupplied {
+					code = "password_incorrect"
+				}
+				writeError(w, http.StatusUnprocessableEntity, code, pwErr.Error())
+				return
+			}
+			var limitErr *parser.LimitError
+			if errors.As(err, &limitErr) {
+				status := http.StatusUnprocessableEntity
+				if limitErr.Limit == parser.LimitInputBytes {
+					status = http.StatusRequestEntityTooLarge
+				}
+				writeError(w, status, "limit_exceeded", limitErr.Error())
+				return
+			}
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				writeValidationError(w, validationErr)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, doc)
 	}
 }
 
@@ -44,16 +126,401 @@ func getDocumentHandler(dataService *service.DataService) http.HandlerFunc {
 	}
 }
 
+// getTableOfContentsHandler returns the section tree of a document.
+func getTableOfContentsHandler(dataService *service.DataService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid document id")
+			return
+		}
+
+		doc, err := dataService.GetDocument(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		sections := doc.Sections
+		if sections == nil {
+			sections = []models.Section{}
+		}
+		writeJSON(w, http.StatusOK, sections)
+	}
+}
+
+// getExtractionReportHandler returns how each page of a document was
+// extracted, including failed extractors and quality scores.
+func getExtractionReportHandler(dataService *service.DataService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid document id")
+			return
+		}
+
+		doc, err := dataService.GetDocument(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		pages := doc.Extraction
+		if pages == nil {
+			pages = []models.PageExtraction{}
+		}
+		writeJSON(w, http.StatusOK, pages)
+	}
+}
+
+// listFlaggedDocumentsHandler lists documents with pages that need
+// reprocessing.
+func listFlaggedDocumentsHandler(dataService *service.DataService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flagged, err := dataService.FlaggedDocuments()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, flagged)
+	}
+}
+
+// updateDocumentRequest is the body accepted by PUT /documents/{id}.
+type updateDocumentRequest struct {
+	Text     string          `json:"text"`
+	Metadata models.Metadata `json:"metadata"`
+}
+
+// updateDocumentHandler replaces the text and metadata of a document. Every
+// invalid field is reported in one response.
 func updateDocumentHandler(dataService *service.DataService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Logic to update a document by ID
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid document id")
+			return
+		}
+		var req updateDocumentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid request body")
+			return
+		}
+
+		if _, err := dataService.GetDocument(id); err != nil {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		if err := dataService.UpdateDocument(id, req.Text, req.Metadata); err != nil {
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				writeValidationError(w, validationErr)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+
+		doc, err := dataService.GetDocument(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, doc)
 	}
 }
 
+// finalizeDocumentHandler moves a draft document to the finalized state once
+// it passes full validation.
+func finalizeDocumentHandler(dataService *service.DataService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid document id")
+			return
+		}
+
+		if _, err := dataService.GetDocument(id); err != nil {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		doc, err := dataService.FinalizeDocument(id)
+		if err != nil {
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				writeValidationError(w, validationErr)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+// listDocumentsByAuthorHandler lists documents by the author given in the
+// name query parameter, in any common form such as "Sharma, R." or
+// "Rahul Sharma", or by the orcid parameter.
+func listDocumentsByAuthorHandler(dataService *service.DataService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		author := models.ParseAuthorName(r.URL.Query().Get("name"))
+		if orcid := r.URL.Query().Get("orcid"); orcid != "" {
+			author.ORCID = strings.ToUpper(orcid)
+		}
+		if author.IsEmpty() && author.ORCID == "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "name or orcid is required")
+			return
+		}
+
+		docs, err := dataService.DocumentsByAuthor(author)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, docs)
+	}
+}
+
+// listChunksHandler returns the chunks of a document in order.
+func listChunksHandler(dataService *service.DataService, chunkService *service.ChunkService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid document id")
+			return
+		}
+
+		if _, err := dataService.GetDocument(id); err != nil {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		chunks, err := chunkService.Chunks(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, chunks)
+	}
+}
+
+// listEmbeddingModelsHandler lists the models that have stored embeddings,
+// so a migration can be followed until the old model can be dropped.
+func listEmbeddingModelsHandler(embeddingService *service.EmbeddingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := embeddingService.Models()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// searchHandler ranks chunks matching the q query parameter. Results can
+// be narrowed with the author, keyword, file_type and collection parameters,
+// each repeatable, and year, year_from and year_to. limit and cursor page
+// through the results, and fusion, lexical_weight and vector_weight tune
+// how lexical and vector matches are combined.
+func searchHandler(searchService *service.SearchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		req := service.SearchRequest{
+			Query:  strings.TrimSpace(params.Get("q")),
+			Cursor: params.Get("cursor"),
+			Filter: service.RetrievalFilter{
+				Authors:     params["author"],
+				Keywords:    params["keyword"],
+				FileTypes:   params["file_type"],
+				Collections: params["collection"],
+			},
+		}
+		if req.Query == "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "q is required")
+			return
+		}
+
+		var err error
+		if req.Limit, err = intParam(params, "limit", 1, service.MaxSearchLimit); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		year, err := intParam(params, "year", 1, 9999)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		req.Filter.YearFrom, req.Filter.YearTo = year, year
+		if params.Has("year_from") || params.Has("year_to") {
+			if year != 0 {
+				writeError(w, http.StatusBadRequest, "invalid_request", "year cannot be combined with year_from or year_to")
+				return
+			}
+			if req.Filter.YearFrom, err = intParam(params, "year_from", 1, 9999); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+			if req.Filter.YearTo, err = intParam(params, "year_to", 1, 9999); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+		}
+		if req.Fusion, err = retrieval.ParseMethod(params.Get("fusion")); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if req.LexicalWeight, err = weightParam(params, "lexical_weight"); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if req.VectorWeight, err = weightParam(params, "vector_weight"); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		page, err := searchService.Search(r.Context(), req)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				writeError(w, http.StatusBadRequest, "invalid_cursor", err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+	}
+}
+
+// askRequest is the body accepted by POST /ask. Sources is the number of
+// chunks the answer is drawn from.
+type askRequest struct {
+	Question string                  `json:"question"`
+	Filter   service.RetrievalFilter `json:"filter"`
+	Sources  int                     `json:"sources,omitempty"`
+}
+
+// askHandler answers a question from the notes, citing the chunks behind
+// each sentence of the answer.
+func askHandler(askService *service.AskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req askRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid request body")
+			return
+		}
+		req.Question = strings.TrimSpace(req.Question)
+		if req.Question == "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "question is required")
+			return
+		}
+		if req.Sources < 0 || req.Sources > service.MaxAskSources {
+			writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("sources must be from 1 to %d", service.MaxAskSources))
+			return
+		}
+
+		answer, err := askService.Ask(r.Context(), service.AskRequest{Question: req.Question, Filter: req.Filter, Sources: req.Sources})
+		if err != nil {
+			if errors.Is(err, service.ErrProviderFailed) {
+				writeError(w, http.StatusBadGateway, "llm_unavailable", err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, answer)
+	}
+}
+
+// intParam parses the query parameter name as an integer from lo to hi,
+// returning 0 if it is absent.
+func intParam(params url.Values, name string, lo, hi int) (int, error) {
+	value := params.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%s must be an integer from %d to %d", name, lo, hi)
+	}
+	return n, nil
+}
+
+// weightParam parses the query parameter name as a non-negative number,
+// returning 0 if it is absent.
+func weightParam(params url.Values, name string) (float64, error) {
+	value := params.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("%s must be a non-negative number", name)
+	}
+	return f, nil
+}
+
+// listParsersHandler lists the registered parsers and their versions.
+func listParsersHandler(dataService *service.DataService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, dataService.Parsers())
+	}
+}
+
+// uploadFileSlack allows for the multipart framing around an upload on
+// top of the largest file accepted.
+const uploadFileSlack = 1 << 20
+
 // Handler functions for files
+
+// uploadFileHandler stores the file sent as the "file" part of a
+// multipart/form-data body. The response carries the ID that POST
+// /documents takes.
 func uploadFileHandler(fileService *service.FileService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Logic to upload a file
+		if limit := fileService.MaxBytes(); limit > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, limit+uploadFileSlack)
+		}
+		reader, err := r.MultipartReader()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "body must be multipart/form-data")
+			return
+		}
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				writeError(w, http.StatusBadRequest, "invalid_request", "file is required")
+				return
+			}
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			if part.FormName() != "file" {
+				part.Close()
+				continue
+			}
+
+			file, err := fileService.UploadFile(part.FileName(), part.Header.Get("Content-Type"), part)
+			part.Close()
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, file)
+			return
+		}
+	}
+}
+
+// writeUploadError reports a failed upload, telling oversized and
+// unsupported files apart from server faults.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrFileTooLarge) || errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, "limit_exceeded", service.ErrFileTooLarge.Error())
+	case errors.Is(err, utils.ErrUnsupportedFileType):
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported_file_type", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 	}
 }
 
@@ -68,3 +535,31 @@ func deleteFileHandler(fileService *service.FileService) http.HandlerFunc {
 		// Logic to delete a file by ID
 	}
 }
+
+// errorResponse is the JSON body returned for failed requests. Code is a
+// stable identifier clients can switch on.
+type errorResponse struct {
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Errors  []models.FieldError `json:"errors,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, errorResponse{Code: code, Message: message})
+}
+
+// writeValidationError reports every problem in err at once, each addressed
+// by the path of the field it concerns.
+func writeValidationError(w http.ResponseWriter, err *models.ValidationError) {
+	writeJSON(w, http.StatusUnprocessableEntity, errorResponse{
+		Code:    "validation_failed",
+		Message: "the document has invalid or missing fields",
+		Errors:  err.Errors,
+	})
+}
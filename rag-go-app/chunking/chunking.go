@@ -0,0 +1,229 @@
+// Package chunking splits documents into chunks small enough to embed and
+// retrieve, keeping track of where each chunk came from.
+package chunking
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rag-go-app/langdetect"
+	"rag-go-app/models"
+)
+
+// Span is a byte range of a text, end exclusive.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Splitter splits the r part of text into chunk spans, in order.
+type Splitter interface {
+	Split(text string, r Span) []Span
+}
+
+// Strategy turns a document into chunks.
+type Strategy interface {
+	Chunk(doc *models.Document) []models.Chunk
+}
+
+// Default sizes, in tokens as counted by CountTokens.
+const (
+	DefaultChunkTokens   = 256
+	DefaultOverlapTokens = 32
+)
+
+// Chunker picks a chunking strategy by content type.
+type Chunker struct {
+	strategies map[string]Strategy
+	fallback   Strategy
+}
+
+// NewChunker creates a chunker that uses fallback for content types without
+// a strategy of their own.
+func NewChunker(fallback Strategy) *Chunker {
+	return &Chunker{strategies: make(map[string]Strategy), fallback: fallback}
+}
+
+// Register sets the strategy for contentType.
+func (c *Chunker) Register(contentType string, s Strategy) {
+	c.strategies[contentType] = s
+}
+
+// Strategy returns the strategy used for contentType.
+func (c *Chunker) Strategy(contentType string) Strategy {
+	if s, ok := c.strategies[contentType]; ok {
+		return s
+	}
+	return c.fallback
+}
+
+// Chunk splits doc with the strategy for contentType.
+func (c *Chunker) Chunk(contentType string, doc *models.Document) []models.Chunk {
+	return c.Strategy(contentType).Chunk(doc)
+}
+
+// DefaultChunker chunks paged formats by section, transcripts by segment,
+// and everything else into fixed-size windows.
+func DefaultChunker() *Chunker {
+	sentences := Sentences{MaxTokens: DefaultChunkTokens}
+	c := NewChunker(FixedTokens{Size: DefaultChunkTokens, Overlap: DefaultOverlapTokens})
+	for _, contentType := range []string{
+		"application/pdf",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	} {
+		c.Register(contentType, Sections{Splitter: sentences})
+	}
+	for _, contentType := range []string{"application/x-subrip", "text/srt", "text/vtt"} {
+		c.Register(contentType, Transcript{MaxTokens: DefaultChunkTokens})
+	}
+	return c
+}
+
+// Tokenize splits text into approximate model tokens: runs of letters,
+// digits and combining marks, and single punctuation characters. It
+// undercounts subword tokenizers on rare words but needs no vocabulary and
+// treats every script alike.
+func Tokenize(text string) []Span {
+	var tokens []Span
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+		if word {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, Span{start, i})
+			start = -1
+		}
+		if !unicode.IsSpace(r) {
+			tokens = append(tokens, Span{i, i + utf8.RuneLen(r)})
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Span{start, len(text)})
+	}
+	return tokens
+}
+
+// CountTokens returns the number of tokens Tokenize finds in text.
+func CountTokens(text string) int {
+	return len(Tokenize(text))
+}
+
+// chunksFromSpans builds chunks for doc from spans of its text, one section
+// path per span. Spans are trimmed of surrounding space and empty ones
+// dropped.
+func chunksFromSpans(doc *models.Document, spans []Span, paths [][]string) []models.Chunk {
+	text := doc.Text
+	pages := pageSpans(doc)
+
+	var chunks []models.Chunk
+	var trimmed []Span
+	for i, s := range spans {
+		s = trimSpan(text, s)
+		if s.Start >= s.End {
+			continue
+		}
+		trimmed = append(trimmed, s)
+
+		chunk := models.Chunk{
+			DocumentID: doc.ID,
+			Ordinal:    len(chunks),
+			Text:       text[s.Start:s.End],
+		}
+		if paths != nil {
+			chunk.SectionPath = paths[i]
+		}
+		chunk.TokenCount = CountTokens(chunk.Text)
+		chunk.Language = langdetect.Detect(chunk.Text).Language
+		if chunk.Language == langdetect.Unknown {
+			chunk.Language = doc.Metadata.Language
+		}
+		for _, p := range pages {
+			if p.span.Start < s.End && s.Start < p.span.End {
+				if chunk.PageStart == 0 {
+					chunk.PageStart = p.number
+				}
+				chunk.PageEnd = p.number
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	// Offsets are reported in characters, counted in one pass
+	toChars := charOffsets(text, trimmed)
+	for i, s := range trimmed {
+		chunks[i].StartChar = toChars[s.Start]
+		chunks[i].EndChar = toChars[s.End]
+	}
+	return chunks
+}
+
+// charOffsets maps each byte offset used by spans to a character offset.
+func charOffsets(text string, spans []Span) map[int]int {
+	offsets := make([]int, 0, 2*len(spans))
+	for _, s := range spans {
+		offsets = append(offsets, s.Start, s.End)
+	}
+	sort.Ints(offsets)
+
+	chars := make(map[int]int, len(offsets))
+	pos, count := 0, 0
+	for _, off := range offsets {
+		count += utf8.RuneCountInString(text[pos:off])
+		pos = off
+		chars[off] = count
+	}
+	return chars
+}
+
+func trimSpan(text string, s Span) Span {
+	for s.Start < s.End {
+		r, size := utf8.DecodeRuneInString(text[s.Start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.Start += size
+	}
+	for s.End > s.Start {
+		r, size := utf8.DecodeLastRuneInString(text[:s.End])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.End -= size
+	}
+	return s
+}
+
+type pageSpan struct {
+	number int
+	span   Span
+}
+
+// pageSpans locates each page's text in the document text. Pages are found
+// in order; a page whose text cannot be found, for example because the
+// document text was sanitized, is left out.
+func pageSpans(doc *models.Document) []pageSpan {
+	var spans []pageSpan
+	cursor := 0
+	for _, page := range doc.Pages {
+		pageText := strings.TrimSpace(page.Text)
+		if pageText == "" {
+			continue
+		}
+		i := strings.Index(doc.Text[cursor:], pageText)
+		if i < 0 {
+			continue
+		}
+		start := cursor + i
+		cursor = start + len(pageText)
+		spans = append(spans, pageSpan{number: page.Number, span: Span{start, cursor}})
+	}
+	return spans
+}
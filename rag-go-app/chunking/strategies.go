@@ -0,0 +1,395 @@
+package chunking
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rag-go-app/models"
+)
+
+// FixedTokens splits text into windows of Size tokens, each starting
+// Size-Overlap tokens after the previous one, so that a sentence cut at a
+// window edge appears whole in one of the two windows.
+type FixedTokens struct {
+	Size    int
+	Overlap int
+}
+
+// Split implements Splitter.
+func (f FixedTokens) Split(text string, r Span) []Span {
+	size := f.Size
+	if size <= 0 {
+		size = DefaultChunkTokens
+	}
+	step := size - f.Overlap
+	if step <= 0 || f.Overlap < 0 {
+		step = size
+	}
+
+	tokens := Tokenize(text[r.Start:r.End])
+	var spans []Span
+	for i := 0; i < len(tokens); i += step {
+		end := min(i+size, len(tokens))
+		spans = append(spans, Span{r.Start + tokens[i].Start, r.Start + tokens[end-1].End})
+		if end == len(tokens) {
+			break
+		}
+	}
+	return spans
+}
+
+// Chunk implements Strategy.
+func (f FixedTokens) Chunk(doc *models.Document) []models.Chunk {
+	return chunksFromSpans(doc, f.Split(doc.Text, Span{0, len(doc.Text)}), nil)
+}
+
+// Sentences packs whole sentences into chunks of up to MaxTokens. A
+// paragraph break ends a chunk that is at least half full, so chunks tend
+// to hold whole paragraphs. A sentence longer than MaxTokens is split into
+// fixed windows.
+type Sentences struct {
+	MaxTokens int
+}
+
+// Split implements Splitter.
+func (s Sentences) Split(text string, r Span) []Span {
+	maxTokens := s.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkTokens
+	}
+
+	var spans []Span
+	current := Span{-1, -1}
+	tokens := 0
+	flush := func() {
+		if current.Start >= 0 {
+			spans = append(spans, current)
+		}
+		current = Span{-1, -1}
+		tokens = 0
+	}
+
+	for _, para := range paragraphs(text, r) {
+		if tokens >= maxTokens/2 {
+			flush()
+		}
+		for _, sentence := range sentenceSpans(text, para) {
+			n := CountTokens(text[sentence.Start:sentence.End])
+			if n > maxTokens {
+				flush()
+				spans = append(spans, FixedTokens{Size: maxTokens}.Split(text, sentence)...)
+				continue
+			}
+			if tokens+n > maxTokens {
+				flush()
+			}
+			if current.Start < 0 {
+				current.Start = sentence.Start
+			}
+			current.End = sentence.End
+			tokens += n
+		}
+	}
+	flush()
+	return spans
+}
+
+// Chunk implements Strategy.
+func (s Sentences) Chunk(doc *models.Document) []models.Chunk {
+	return chunksFromSpans(doc, s.Split(doc.Text, Span{0, len(doc.Text)}), nil)
+}
+
+// Transcript packs whole transcript segments into chunks of up to MaxTokens
+// and records the time range each chunk was spoken in. A segment longer than
+// MaxTokens is split by Sentences, each piece keeping the segment's time
+// range. Documents without segments, or whose text no longer holds them
+// after an edit, are chunked by Sentences.
+type Transcript struct {
+	MaxTokens int
+}
+
+// Chunk implements Strategy.
+func (t Transcript) Chunk(doc *models.Document) []models.Chunk {
+	maxTokens := t.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkTokens
+	}
+	sentences := Sentences{MaxTokens: maxTokens}
+	segments, ok := segmentSpans(doc)
+	if !ok {
+		return sentences.Chunk(doc)
+	}
+
+	var spans []Span
+	var times []models.TimeRange
+	current := Span{-1, -1}
+	var currentTime models.TimeRange
+	tokens := 0
+	flush := func() {
+		if current.Start >= 0 {
+			spans = append(spans, current)
+			times = append(times, currentTime)
+		}
+		current = Span{-1, -1}
+		tokens = 0
+	}
+
+	for _, seg := range segments {
+		n := CountTokens(doc.Text[seg.span.Start:seg.span.End])
+		if n > maxTokens {
+			flush()
+			for _, span := range sentences.Split(doc.Text, seg.span) {
+				spans = append(spans, span)
+				times = append(times, seg.time)
+			}
+			continue
+		}
+		if tokens+n > maxTokens {
+			flush()
+		}
+		if current.Start < 0 {
+			current.Start = seg.span.Start
+			currentTime.Start = seg.time.Start
+		}
+		current.End = seg.span.End
+		currentTime.End = max(currentTime.End, seg.time.End)
+		tokens += n
+	}
+	flush()
+
+	// chunksFromSpans drops empty spans, so drop them here too to keep
+	// times in step
+	var kept []Span
+	var keptTimes []models.TimeRange
+	for i, span := range spans {
+		if trimmed := trimSpan(doc.Text, span); trimmed.Start < trimmed.End {
+			kept = append(kept, span)
+			keptTimes = append(keptTimes, times[i])
+		}
+	}
+	chunks := chunksFromSpans(doc, kept, nil)
+	for i := range chunks {
+		chunks[i].TimeRange = &keptTimes[i]
+	}
+	return chunks
+}
+
+type segmentSpan struct {
+	time models.TimeRange
+	span Span
+}
+
+// segmentSpans locates each transcript segment in the document text, in
+// order. ok is false when the document has no segments or one of them
+// cannot be found, as after the text was edited.
+func segmentSpans(doc *models.Document) (spans []segmentSpan, ok bool) {
+	if len(doc.Segments) == 0 {
+		return nil, false
+	}
+	cursor := 0
+	for _, seg := range doc.Segments {
+		text := strings.TrimSpace(seg.Text)
+		if text == "" {
+			continue
+		}
+		i := strings.Index(doc.Text[cursor:], text)
+		if i < 0 {
+			return nil, false
+		}
+		start := cursor + i
+		cursor = start + len(text)
+		spans = append(spans, segmentSpan{
+			time: models.TimeRange{Start: seg.Start, End: seg.End},
+			span: Span{start, cursor},
+		})
+	}
+	return spans, len(spans) > 0
+}
+
+// Sections chunks each section of a document separately with Splitter, so
+// no chunk spans two sections, and records the section path of each chunk.
+// Documents without sections are split as a whole.
+type Sections struct {
+	Splitter Splitter
+}
+
+// Chunk implements Strategy.
+func (s Sections) Chunk(doc *models.Document) []models.Chunk {
+	splitter := s.Splitter
+	if splitter == nil {
+		splitter = Sentences{MaxTokens: DefaultChunkTokens}
+	}
+
+	var spans []Span
+	var paths [][]string
+	for _, region := range sectionRegions(doc) {
+		for _, span := range splitter.Split(doc.Text, region.span) {
+			spans = append(spans, span)
+			paths = append(paths, region.path)
+		}
+	}
+	return chunksFromSpans(doc, spans, paths)
+}
+
+type sectionRegion struct {
+	path []string
+	span Span
+}
+
+// sectionRegions divides the document text at the start of each section.
+// A section starts where its title is found in the text, searching from the
+// start of its page when pages are known. Sections whose titles cannot be
+// found are merged into the section before them. Text before the first
+// section forms a region with no path.
+func sectionRegions(doc *models.Document) []sectionRegion {
+	type start struct {
+		path []string
+		at   int
+	}
+	pageStart := make(map[int]int)
+	for _, p := range pageSpans(doc) {
+		pageStart[p.number] = p.span.Start
+	}
+
+	var starts []start
+	cursor := 0
+	var walk func(sections []models.Section, parent []string)
+	walk = func(sections []models.Section, parent []string) {
+		for _, section := range sections {
+			path := append(append([]string(nil), parent...), section.Title)
+			from := cursor
+			if at, ok := pageStart[section.Page]; ok && at > from {
+				from = at
+			}
+			if i := indexFold(doc.Text[from:], section.Title); i >= 0 && section.Title != "" {
+				cursor = from + i
+				starts = append(starts, start{path: path, at: cursor})
+			}
+			walk(section.Children, path)
+		}
+	}
+	walk(doc.Sections, nil)
+
+	if len(starts) == 0 {
+		return []sectionRegion{{span: Span{0, len(doc.Text)}}}
+	}
+	var regions []sectionRegion
+	if starts[0].at > 0 {
+		regions = append(regions, sectionRegion{span: Span{0, starts[0].at}})
+	}
+	for i, s := range starts {
+		end := len(doc.Text)
+		if i+1 < len(starts) {
+			end = starts[i+1].at
+		}
+		regions = append(regions, sectionRegion{path: s.path, span: Span{s.at, end}})
+	}
+	return regions
+}
+
+// indexFold is strings.Index ignoring case and runs of whitespace, which
+// often differ between an outline title and the page text.
+func indexFold(s string, substr string) int {
+	words := strings.Fields(substr)
+	if len(words) == 0 {
+		return -1
+	}
+	parts := make([]string, len(words))
+	for i, w := range words {
+		parts[i] = regexp.QuoteMeta(w)
+	}
+	re, err := regexp.Compile(`(?i)` + strings.Join(parts, `\s+`))
+	if err != nil {
+		return -1
+	}
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return -1
+	}
+	return loc[0]
+}
+
+// paragraphs splits r at blank lines.
+func paragraphs(text string, r Span) []Span {
+	var spans []Span
+	start := r.Start
+	for start < r.End {
+		i := strings.Index(text[start:r.End], "\n\n")
+		if i < 0 {
+			spans = append(spans, Span{start, r.End})
+			break
+		}
+		if i > 0 {
+			spans = append(spans, Span{start, start + i})
+		}
+		start += i + 2
+	}
+	return spans
+}
+
+// abbreviations end in a full stop without ending the sentence.
+var abbreviations = map[string]bool{
+	"dr": true, "prof": true, "mr": true, "mrs": true, "ms": true, "vs": true,
+	"fig": true, "figs": true, "eq": true, "eqs": true, "no": true, "vol": true,
+	"pp": true, "ch": true, "sec": true, "al": true, "etc": true, "e.g": true,
+	"i.e": true, "cf": true, "approx": true, "st": true,
+}
+
+// SentenceSpans splits text into sentences the way Sentences does.
+func SentenceSpans(text string) []Span {
+	return sentenceSpans(text, Span{0, len(text)})
+}
+
+// sentenceSpans splits r after sentence-ending punctuation followed by
+// space: full stops, question and exclamation marks, and the Devanagari
+// danda and double danda. Full stops after abbreviations and single-letter
+// initials do not end sentences.
+func sentenceSpans(text string, r Span) []Span {
+	var spans []Span
+	start := r.Start
+	for i := r.Start; i < r.End; {
+		c, size := utf8.DecodeRuneInString(text[i:])
+		next := i + size
+		if isSentenceEnd(c) && (next >= r.End || startsWithSpace(text[next:r.End])) {
+			if c != '.' || !isAbbreviation(text[start:i]) {
+				spans = append(spans, Span{start, next})
+				start = next
+			}
+		}
+		i = next
+	}
+	if start < r.End && strings.TrimSpace(text[start:r.End]) != "" {
+		spans = append(spans, Span{start, r.End})
+	}
+	return spans
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '?', '!', '।', '॥':
+		return true
+	}
+	return false
+}
+
+func startsWithSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+// isAbbreviation reports whether the word ending before must be an
+// abbreviation or an initial.
+func isAbbreviation(before string) bool {
+	fields := strings.Fields(before)
+	if len(fields) == 0 {
+		return false
+	}
+	word := strings.TrimLeft(fields[len(fields)-1], "([\"'")
+	if utf8.RuneCountInString(word) == 1 {
+		r, _ := utf8.DecodeRuneInString(word)
+		return unicode.IsUpper(r)
+	}
+	return abbreviations[strings.ToLower(word)]
+}
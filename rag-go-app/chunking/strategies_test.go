@@ -0,0 +1,105 @@
+package chunking
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"rag-go-app/models"
+)
+
+// transcript returns a document of segments, one second each, with the
+// given texts.
+func transcript(texts ...string) *models.Document {
+	doc := &models.Document{Text: strings.Join(texts, "\n\n")}
+	for i, text := range texts {
+		doc.Segments = append(doc.Segments, models.TranscriptSegment{
+			Start: time.Duration(i) * time.Second,
+			End:   time.Duration(i+1) * time.Second,
+			Text:  text,
+		})
+	}
+	return doc
+}
+
+func TestTranscriptChunk(t *testing.T) {
+	type want struct {
+		text       string
+		start, end time.Duration
+	}
+	tests := []struct {
+		name      string
+		doc       *models.Document
+		maxTokens int
+		want      []want
+	}{
+		{
+			name:      "packs segments",
+			doc:       transcript("one two three.", "four five six.", "seven eight nine."),
+			maxTokens: 8,
+			want: []want{
+				{"one two three.\n\nfour five six.", 0, 2 * time.Second},
+				{"seven eight nine.", 2 * time.Second, 3 * time.Second},
+			},
+		},
+		{
+			name:      "splits a long segment and keeps its time range",
+			doc:       transcript("short.", "a b c. d e f. g h i."),
+			maxTokens: 5,
+			want: []want{
+				{"short.", 0, time.Second},
+				{"a b c.", time.Second, 2 * time.Second},
+				{"d e f.", time.Second, 2 * time.Second},
+				{"g h i.", time.Second, 2 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := Transcript{MaxTokens: tt.maxTokens}.Chunk(tt.doc)
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(tt.want), chunks)
+			}
+			for i, c := range chunks {
+				w := tt.want[i]
+				if c.Text != w.text || c.TimeRange == nil || c.TimeRange.Start != w.start || c.TimeRange.End != w.end {
+					t.Errorf("chunk %d = %q %+v, want %q from %v to %v", i, c.Text, c.TimeRange, w.text, w.start, w.end)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscriptChunkFallsBackToSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *models.Document
+	}{
+		{"no segments", &models.Document{Text: "one two. three four."}},
+		{"edited text", func() *models.Document {
+			doc := transcript("one two.", "three four.")
+			doc.Text = "one two.\n\nthree five."
+			return doc
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := Transcript{}.Chunk(tt.doc)
+			if len(chunks) != 1 || chunks[0].Text != tt.doc.Text || chunks[0].TimeRange != nil {
+				t.Errorf("chunks = %+v, want the whole text without a time range", chunks)
+			}
+		})
+	}
+}
+
+func TestChunkTimeRangeJSON(t *testing.T) {
+	chunks := Transcript{}.Chunk(transcript("one two.", "three four."))
+	data, err := json.Marshal(chunks[0].TimeRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"start":0,"end":2}`; string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+}
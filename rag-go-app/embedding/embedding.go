@@ -0,0 +1,77 @@
+// Package embedding turns text into vectors for retrieval. Providers sit
+// behind the Embedder interface so that a deployment can switch between a
+// local model and a remote service without touching its callers.
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"rag-go-app/models"
+)
+
+// Model identifies the model that produced a set of vectors. It is stored
+// alongside every vector, since vectors from different models cannot be
+// compared.
+type Model = models.EmbeddingModel
+
+// Embedder computes embeddings. Embed returns one vector per text, in order.
+// MaxBatch is the most texts a single call to Embed accepts, or zero when
+// there is no limit. Implementations must be safe for concurrent use.
+type Embedder interface {
+	Model() Model
+	MaxBatch() int
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ErrDimensionMismatch is returned when an embedder produces a vector whose
+// length differs from the dimension of its model.
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+// EmbedAll embeds any number of texts, splitting them into batches the
+// embedder accepts, and checks that every vector has the model's dimension.
+func EmbedAll(ctx context.Context, e Embedder, texts []string) ([][]float32, error) {
+	batch := e.MaxBatch()
+	if batch <= 0 {
+		batch = len(texts)
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batch {
+		end := min(start+batch, len(texts))
+		out, err := e.Embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("embedding texts %d-%d with %s: %w", start, end-1, e.Model(), err)
+		}
+		if len(out) != end-start {
+			return nil, fmt.Errorf("embedding with %s: got %d vectors for %d texts", e.Model(), len(out), end-start)
+		}
+		vectors = append(vectors, out...)
+	}
+
+	dim := e.Model().Dimension
+	for i, v := range vectors {
+		if len(v) != dim {
+			return nil, fmt.Errorf("text %d: %w: got %d, want %d", i, ErrDimensionMismatch, len(v), dim)
+		}
+	}
+	return vectors, nil
+}
+
+// Normalize scales v to unit length in place. The zero vector is left as
+// it is.
+func Normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= scale
+	}
+}
@@ -0,0 +1,105 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashedDimension is the dimension NewHashed uses when given zero.
+const DefaultHashedDimension = 384
+
+// hashedVersion changes whenever Hashed would produce different vectors for
+// the same text, so stored vectors are never compared with new ones.
+const hashedVersion = "1"
+
+// Hashed is a deterministic offline embedder. Each text is broken into
+// lowercased words and the character trigrams and fourgrams of each word,
+// and every feature is hashed into one of Dimension buckets with a hashed
+// sign, weighted by the logarithm of its count. Texts sharing words or word
+// fragments get similar vectors, which is enough for tests and for small
+// collections where a neural model is not available. It needs no model
+// files and gives the same vectors on every machine.
+type Hashed struct {
+	dim int
+}
+
+// NewHashed creates a hashed embedder producing vectors of dim components.
+func NewHashed(dim int) *Hashed {
+	if dim <= 0 {
+		dim = DefaultHashedDimension
+	}
+	return &Hashed{dim: dim}
+}
+
+// Model implements Embedder.
+func (h *Hashed) Model() Model {
+	return Model{Name: "hashed-ngram-" + strconv.Itoa(h.dim), Version: hashedVersion, Dimension: h.dim, Normalized: true}
+}
+
+// MaxBatch implements Embedder; there is no limit.
+func (h *Hashed) MaxBatch() int {
+	return 0
+}
+
+// Embed implements Embedder.
+func (h *Hashed) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = h.embed(text)
+	}
+	return vectors, nil
+}
+
+func (h *Hashed) embed(text string) []float32 {
+	counts := make(map[string]int)
+	for _, word := range words(text) {
+		counts["w:"+word]++
+		// Boundary markers keep prefixes and suffixes apart from the
+		// same letters inside a word
+		runes := []rune("<" + word + ">")
+		for n := 3; n <= 4; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				counts["c:"+string(runes[i:i+n])]++
+			}
+		}
+	}
+
+	// Features are added in sorted order so that rounding, and with it
+	// the vector, is the same on every run
+	features := make([]string, 0, len(counts))
+	for feature := range counts {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+
+	v := make([]float32, h.dim)
+	for _, feature := range features {
+		count := counts[feature]
+		f := fnv.New64a()
+		f.Write([]byte(feature))
+		sum := f.Sum64()
+		weight := float32(1 + math.Log(float64(count)))
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		v[sum%uint64(h.dim)] += weight
+	}
+	Normalize(v)
+	return v
+}
+
+// words splits text into lowercased runs of letters, digits and combining
+// marks. Marks are kept so Indic words are not cut at their vowel signs.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
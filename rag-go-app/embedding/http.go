@@ -0,0 +1,209 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rag-go-app/httpretry"
+)
+
+// HTTP API flavours understood by HTTPEmbedder.
+const (
+	APIOpenAI = "openai"
+	APIOllama = "ollama"
+)
+
+// HTTPConfig configures an HTTPEmbedder.
+//
+// URL is the base of the API: for example https://api.openai.com/v1 for
+// OpenAI-compatible servers, to which /embeddings is appended, or
+// http://localhost:11434 for Ollama, to which /api/embed is appended.
+// Dimension may be left zero, in which case it is learned from the first
+// response. Normalize scales vectors to unit length on the client, for
+// servers that do not. Version is recorded in the model identity and should
+// be changed whenever the server's model is replaced under the same name.
+// Failed requests are retried MaxRetries times when the server is rate
+// limiting or failing; a negative MaxRetries disables retries. Timeout is
+// ignored when Client is set.
+type HTTPConfig struct {
+	API        string
+	URL        string
+	APIKey     string
+	Model      string
+	Version    string
+	Dimension  int
+	Normalize  bool
+	BatchSize  int
+	Timeout    time.Duration
+	MaxRetries int
+	Client     *http.Client
+}
+
+// Defaults for HTTPConfig fields left zero.
+const (
+	DefaultHTTPBatchSize  = 64
+	DefaultHTTPTimeout    = 30 * time.Second
+	DefaultHTTPMaxRetries = 2
+)
+
+// HTTPEmbedder embeds text with a remote model server speaking the
+// OpenAI embeddings API or the Ollama embed API.
+type HTTPEmbedder struct {
+	cfg    HTTPConfig
+	client *http.Client
+
+	mu  sync.Mutex
+	dim int
+}
+
+// NewHTTPEmbedder creates an embedder for the server described by cfg. It
+// does not contact the server.
+func NewHTTPEmbedder(cfg HTTPConfig) (*HTTPEmbedder, error) {
+	if cfg.API == "" {
+		cfg.API = APIOpenAI
+	}
+	if cfg.API != APIOpenAI && cfg.API != APIOllama {
+		return nil, fmt.Errorf("unsupported embedding API %q", cfg.API)
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("embedding URL is required")
+	}
+	if cfg.Model == "" {
+		return nil, errors.New("embedding model is required")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultHTTPBatchSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultHTTPTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultHTTPMaxRetries
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &HTTPEmbedder{cfg: cfg, client: client, dim: cfg.Dimension}, nil
+}
+
+// Model implements Embedder. Dimension is zero until it is configured or
+// learned from a response.
+func (e *HTTPEmbedder) Model() Model {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// OpenAI's embedding models return unit vectors
+	normalized := e.cfg.Normalize || e.cfg.API == APIOpenAI
+	return Model{Name: e.cfg.Model, Version: e.cfg.Version, Dimension: e.dim, Normalized: normalized}
+}
+
+// MaxBatch implements Embedder.
+func (e *HTTPEmbedder) MaxBatch() int {
+	return e.cfg.BatchSize
+}
+
+// Embed implements Embedder.
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var vectors [][]float32
+	err := httpretry.Do(ctx, e.cfg.MaxRetries, func() error {
+		var err error
+		vectors, err = e.request(ctx, texts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedding server returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+
+	if err := e.checkDimension(vectors); err != nil {
+		return nil, err
+	}
+	if e.cfg.Normalize {
+		for _, v := range vectors {
+			Normalize(v)
+		}
+	}
+	return vectors, nil
+}
+
+// checkDimension verifies every vector against the known dimension,
+// learning it from the first vector if it is not known yet.
+func (e *HTTPEmbedder) checkDimension(vectors [][]float32) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dim == 0 {
+		e.dim = len(vectors[0])
+	}
+	for i, v := range vectors {
+		if len(v) != e.dim {
+			return fmt.Errorf("text %d: %w: got %d, want %d", i, ErrDimensionMismatch, len(v), e.dim)
+		}
+	}
+	return nil
+}
+
+func (e *HTTPEmbedder) request(ctx context.Context, texts []string) ([][]float32, error) {
+	var url string
+	var body any
+	switch e.cfg.API {
+	case APIOllama:
+		url = e.cfg.URL + "/api/embed"
+		body = map[string]any{"model": e.cfg.Model, "input": texts}
+	default:
+		url = e.cfg.URL + "/embeddings"
+		req := map[string]any{"model": e.cfg.Model, "input": texts, "encoding_format": "float"}
+		if e.cfg.Dimension > 0 {
+			req["dimensions"] = e.cfg.Dimension
+		}
+		body = req
+	}
+
+	data, err := httpretry.PostJSON(ctx, e.client, url, e.cfg.APIKey, body, "embedding server")
+	if err != nil {
+		return nil, err
+	}
+
+	if e.cfg.API == APIOllama {
+		var out struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		if err := json.Unmarshal(data, &out); err != nil {
+			return nil, fmt.Errorf("decoding embedding response: %w", err)
+		}
+		return out.Embeddings, nil
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("decoding embedding response: %w", err)
+	}
+	// The API does not promise to return vectors in input order
+	sort.Slice(out.Data, func(i, j int) bool { return out.Data[i].Index < out.Data[j].Index })
+	vectors := make([][]float32, len(out.Data))
+	for i, d := range out.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
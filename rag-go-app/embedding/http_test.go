@@ -0,0 +1,252 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// embedRequest is the body HTTPEmbedder sends to either API.
+type embedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions"`
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       HTTPConfig
+		texts     []string
+		responses []func(w http.ResponseWriter, r *http.Request, req embedRequest)
+		want      [][]float32
+		wantErr   string
+		wantDim   int
+	}{
+		{
+			name:  "openai reorders by index",
+			cfg:   HTTPConfig{API: APIOpenAI, Model: "m", APIKey: "secret"},
+			texts: []string{"a", "b"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					if r.URL.Path != "/embeddings" {
+						t.Errorf("path = %q, want /embeddings", r.URL.Path)
+					}
+					if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+						t.Errorf("Authorization = %q", got)
+					}
+					if req.Model != "m" || strings.Join(req.Input, ",") != "a,b" {
+						t.Errorf("request = %+v", req)
+					}
+					w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+				},
+			},
+			want:    [][]float32{{1, 0}, {0, 1}},
+			wantDim: 2,
+		},
+		{
+			name:  "openai sends configured dimension",
+			cfg:   HTTPConfig{API: APIOpenAI, Model: "m", Dimension: 3},
+			texts: []string{"a"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					if req.Dimensions != 3 {
+						t.Errorf("dimensions = %d, want 3", req.Dimensions)
+					}
+					w.Write([]byte(`{"data":[{"index":0,"embedding":[1,2,3]}]}`))
+				},
+			},
+			want:    [][]float32{{1, 2, 3}},
+			wantDim: 3,
+		},
+		{
+			name:  "ollama",
+			cfg:   HTTPConfig{API: APIOllama, Model: "nomic"},
+			texts: []string{"a", "b"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					if r.URL.Path != "/api/embed" {
+						t.Errorf("path = %q, want /api/embed", r.URL.Path)
+					}
+					w.Write([]byte(`{"embeddings":[[1,0],[0,1]]}`))
+				},
+			},
+			want:    [][]float32{{1, 0}, {0, 1}},
+			wantDim: 2,
+		},
+		{
+			name:  "normalizes on the client",
+			cfg:   HTTPConfig{API: APIOllama, Model: "nomic", Normalize: true},
+			texts: []string{"a"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					w.Write([]byte(`{"embeddings":[[3,4]]}`))
+				},
+			},
+			want:    [][]float32{{0.6, 0.8}},
+			wantDim: 2,
+		},
+		{
+			name:  "retries rate limiting",
+			cfg:   HTTPConfig{Model: "m", MaxRetries: 1},
+			texts: []string{"a"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					http.Error(w, `{"error":{"message":"slow down"}}`, http.StatusTooManyRequests)
+				},
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					w.Write([]byte(`{"data":[{"index":0,"embedding":[1,0]}]}`))
+				},
+			},
+			want:    [][]float32{{1, 0}},
+			wantDim: 2,
+		},
+		{
+			name:  "does not retry client errors",
+			cfg:   HTTPConfig{Model: "m", MaxRetries: 2},
+			texts: []string{"a"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					http.Error(w, `{"error":{"message":"unknown model"}}`, http.StatusBadRequest)
+				},
+			},
+			wantErr: "400: unknown model",
+		},
+		{
+			name:  "retries disabled",
+			cfg:   HTTPConfig{API: APIOllama, Model: "m", MaxRetries: -1},
+			texts: []string{"a"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					http.Error(w, `{"error":"model loading"}`, http.StatusServiceUnavailable)
+				},
+			},
+			wantErr: "503: model loading",
+		},
+		{
+			name:  "wrong vector count",
+			cfg:   HTTPConfig{API: APIOllama, Model: "m"},
+			texts: []string{"a", "b"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					w.Write([]byte(`{"embeddings":[[1,0]]}`))
+				},
+			},
+			wantErr: "returned 1 vectors for 2 texts",
+		},
+		{
+			name:  "dimension mismatch",
+			cfg:   HTTPConfig{API: APIOllama, Model: "m", Dimension: 3},
+			texts: []string{"a"},
+			responses: []func(http.ResponseWriter, *http.Request, embedRequest){
+				func(w http.ResponseWriter, r *http.Request, req embedRequest) {
+					w.Write([]byte(`{"embeddings":[[1,0]]}`))
+				},
+			},
+			wantErr: ErrDimensionMismatch.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1))
+				if n > len(tt.responses) {
+					t.Errorf("unexpected request %d", n)
+					http.Error(w, "unexpected", http.StatusInternalServerError)
+					return
+				}
+				var req embedRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Errorf("decoding request: %v", err)
+				}
+				tt.responses[n-1](w, r, req)
+			}))
+			defer server.Close()
+
+			cfg := tt.cfg
+			cfg.URL = server.URL + "/"
+			e, err := NewHTTPEmbedder(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.Embed(context.Background(), tt.texts)
+
+			if n := int(calls.Load()); n != len(tt.responses) {
+				t.Errorf("server got %d requests, want %d", n, len(tt.responses))
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !vectorsEqual(got, tt.want) {
+				t.Errorf("vectors = %v, want %v", got, tt.want)
+			}
+			if dim := e.Model().Dimension; dim != tt.wantDim {
+				t.Errorf("dimension = %d, want %d", dim, tt.wantDim)
+			}
+		})
+	}
+}
+
+func TestHTTPEmbedderDimensionMismatchIsWrapped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"embeddings":[[1,0],[1,0,0]]}`))
+	}))
+	defer server.Close()
+
+	e, err := NewHTTPEmbedder(HTTPConfig{API: APIOllama, URL: server.URL, Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Embed(context.Background(), []string{"a", "b"}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Fatalf("err = %v, want ErrDimensionMismatch", err)
+	}
+}
+
+func TestNewHTTPEmbedderValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  HTTPConfig
+	}{
+		{"unsupported API", HTTPConfig{API: "grpc", URL: "http://x", Model: "m"}},
+		{"missing URL", HTTPConfig{Model: "m"}},
+		{"missing model", HTTPConfig{URL: "http://x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHTTPEmbedder(tt.cfg); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func vectorsEqual(a, b [][]float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if math.Abs(float64(a[i][j]-b[i][j])) > 1e-6 {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+// Package httpretry posts JSON to model servers, such as embedding and
+// language model APIs, and retries the requests they fail transiently.
+package httpretry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// backoff is the wait before the first retry; each later one waits twice
+// as long as the one before.
+const backoff = 500 * time.Millisecond
+
+// StatusError is a non-2xx response from a server. Server names it in the
+// message, such as "embedding server".
+type StatusError struct {
+	Server  string
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Server, e.Status, e.Message)
+}
+
+// Retryable reports whether a failed request may succeed if repeated: the
+// server was rate limiting or had a transient fault.
+func Retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Status == http.StatusTooManyRequests || se.Status >= 500
+	}
+	return false
+}
+
+// Do calls call, and calls it again up to retries more times while it
+// fails with a retryable error, backing off between attempts.
+func Do(ctx context.Context, retries int, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || !Retryable(err) || attempt >= retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(1<<attempt) * backoff):
+		}
+	}
+}
+
+// PostJSON posts body, encoded as JSON, to url and returns the response
+// body. apiKey, if set, is sent as a bearer token. A non-2xx response is
+// returned as a *StatusError for server.
+func PostJSON(ctx context.Context, client *http.Client, url string, apiKey string, body any, server string) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling %s: %w", server, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s response: %w", server, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{Server: server, Status: resp.StatusCode, Message: errorMessage(data)}
+	}
+	return data, nil
+}
+
+// errorMessage extracts the message from an error response body. OpenAI
+// nests it in an object and Ollama gives it as a string; anything else is
+// returned as text, shortened.
+func errorMessage(data []byte) string {
+	var openAI struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &openAI) == nil && openAI.Error.Message != "" {
+		return openAI.Error.Message
+	}
+	var ollama struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &ollama) == nil && ollama.Error != "" {
+		return ollama.Error
+	}
+	text := strings.TrimSpace(string(data))
+	if len(text) > 200 {
+		text = text[:200] + "..."
+	}
+	return text
+}
@@ -0,0 +1,105 @@
+package httpretry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	errFatal := errors.New("malformed response")
+	tests := []struct {
+		name      string
+		retries   int
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", retries: 2, errs: []error{nil}, wantCalls: 1},
+		{name: "retries rate limiting", retries: 2, errs: []error{&StatusError{Status: 429}, nil}, wantCalls: 2},
+		{name: "gives up", retries: 1, errs: []error{&StatusError{Status: 503}, &StatusError{Status: 502}}, wantCalls: 2, wantErr: &StatusError{Status: 502}},
+		{name: "no retries", retries: 0, errs: []error{&StatusError{Status: 500}}, wantCalls: 1, wantErr: &StatusError{Status: 500}},
+		{name: "client error", retries: 2, errs: []error{&StatusError{Status: 400}}, wantCalls: 1, wantErr: &StatusError{Status: 400}},
+		{name: "other error", retries: 2, errs: []error{errFatal}, wantCalls: 1, wantErr: errFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Do(context.Background(), tt.retries, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("called %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error()) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := Do(ctx, 5, func() error {
+		calls++
+		return &StatusError{Status: 503}
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantErr string
+	}{
+		{name: "ok", status: 200, body: `{"ok":true}`, want: `{"ok":true}`},
+		{name: "openai error", status: 401, body: `{"error":{"message":"bad key"}}`, wantErr: "test server returned 401: bad key"},
+		{name: "ollama error", status: 404, body: `{"error":"model not found"}`, wantErr: "test server returned 404: model not found"},
+		{name: "text error", status: 502, body: "bad gateway\n", wantErr: "test server returned 502: bad gateway"},
+		{name: "long text error", status: 500, body: strings.Repeat("x", 300), wantErr: strings.Repeat("x", 200) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer key" {
+					t.Errorf("Authorization = %q", got)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			data, err := PostJSON(context.Background(), server.Client(), server.URL, "key", map[string]int{"n": 1}, "test server")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				var se *StatusError
+				if !errors.As(err, &se) || se.Status != tt.status {
+					t.Errorf("err = %#v, want a StatusError with status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("body = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+// Package langdetect identifies the language of a text offline, from its
+// script and character n-gram statistics.
+package langdetect
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Language codes returned by Detect, following ISO 639-1. Unknown is the
+// ISO 639-2 code for an undetermined language.
+const (
+	English = "en"
+	Hindi   = "hi"
+	Marathi = "mr"
+	Tamil   = "ta"
+	Bengali = "bn"
+	Unknown = "und"
+)
+
+const (
+	// profileSize is the number of most frequent n-grams kept per profile.
+	profileSize = 400
+	// maxLetters caps how much of a long text is examined.
+	maxLetters = 20000
+	// minLetters is the shortest text a language is guessed for.
+	minLetters = 3
+	// commonWords is the number of most frequent words kept per profile.
+	commonWords = 40
+	// minFitWords is the length in words from which a text is judged by its
+	// common words rather than by n-gram distance alone.
+	minFitWords = 8
+	// minCommonShare is the share of a text's words that must be common
+	// words of the language, and fullCommonShare the share that counts as
+	// a full match. Prose in a language runs well above it, while prose in
+	// another language of the same alphabet, such as German against
+	// English, shares almost none.
+	minCommonShare  = 0.1
+	fullCommonShare = 0.3
+	// maxShortDistance is the largest normalized n-gram distance at which
+	// a text shorter than minFitWords, such as a heading, is still taken to
+	// be in the language.
+	maxShortDistance = 0.6
+	// minShortMargin is how much closer, relative to the runner-up's
+	// distance, a text shorter than minFitWords must be to one language of
+	// its script than to the others. Below it, as for many headings in
+	// Devanagari, the text is too short to tell Hindi from Marathi.
+	minShortMargin = 0.2
+)
+
+// Result is the outcome of Detect. Confidence is between 0 and 1 and is only
+// a rough indicator: it falls when the text mixes scripts, when it matches
+// its language's profile poorly, and when languages sharing a script, such
+// as Hindi and Marathi, score alike.
+type Result struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+type script int
+
+const (
+	scriptOther script = iota
+	scriptLatin
+	scriptDevanagari
+	scriptBengali
+	scriptTamil
+)
+
+func scriptOf(r rune) script {
+	switch {
+	case r >= 0x0900 && r <= 0x097F:
+		return scriptDevanagari
+	case r >= 0x0980 && r <= 0x09FF:
+		return scriptBengali
+	case r >= 0x0B80 && r <= 0x0BFF:
+		return scriptTamil
+	case unicode.Is(unicode.Latin, r):
+		return scriptLatin
+	}
+	return scriptOther
+}
+
+// profile ranks the most frequent n-grams of a language's sample text and
+// holds its most common words.
+type profile struct {
+	language string
+	script   script
+	ranks    map[string]int
+	words    map[string]bool
+}
+
+var (
+	profilesOnce sync.Once
+	profiles     []profile
+)
+
+func loadProfiles() []profile {
+	profilesOnce.Do(func() {
+		for _, s := range samples {
+			ranked := rankNgrams(s.text)
+			ranks := make(map[string]int, len(ranked))
+			for i, g := range ranked {
+				ranks[g] = i
+			}
+			profiles = append(profiles, profile{language: s.language, script: s.script, ranks: ranks, words: mostCommonWords(s.text, commonWords)})
+		}
+	})
+	return profiles
+}
+
+// Languages lists the languages Detect can return, besides Unknown.
+func Languages() []string {
+	var langs []string
+	for _, p := range loadProfiles() {
+		langs = append(langs, p.language)
+	}
+	return langs
+}
+
+// Detect identifies the language of text. The dominant script narrows the
+// candidates, and languages sharing a script are told apart by comparing
+// the text's n-gram ranking with each language's profile. The closest
+// language must also fit its profile, so that text in an unsupported
+// language written in a supported script, such as German, is not taken for
+// the only candidate. Text too short, in an unsupported script or fitting
+// no profile yields Unknown.
+func Detect(text string) Result {
+	counts := make(map[script]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		counts[scriptOf(r)]++
+		letters++
+		if letters >= maxLetters {
+			break
+		}
+	}
+	if letters < minLetters {
+		return Result{Language: Unknown}
+	}
+
+	dominant, best := scriptOther, 0
+	for s, n := range counts {
+		if n > best || (n == best && s < dominant) {
+			dominant, best = s, n
+		}
+	}
+	share := float64(best) / float64(letters)
+
+	var candidates []profile
+	for _, p := range loadProfiles() {
+		if p.script == dominant {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return Result{Language: Unknown}
+	}
+
+	text = truncateLetters(text, maxLetters)
+	ranked := rankNgrams(text)
+	type scored struct {
+		profile  profile
+		distance float64
+	}
+	scores := make([]scored, len(candidates))
+	for i, p := range candidates {
+		scores[i] = scored{profile: p, distance: float64(outOfPlace(ranked, p.ranks)) / float64(len(ranked)*profileSize)}
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].distance != scores[j].distance {
+			return scores[i].distance < scores[j].distance
+		}
+		return scores[i].profile.language < scores[j].profile.language
+	})
+
+	closest := scores[0]
+	textWords := words(text)
+	quality := fit(textWords, closest.profile, closest.distance)
+	if quality == 0 {
+		return Result{Language: Unknown}
+	}
+	confidence := share * quality
+	if len(scores) > 1 {
+		margin := 0.0
+		if second := scores[1].distance; second > 0 {
+			margin = (second - closest.distance) / second
+		}
+		if len(textWords) < minFitWords && margin < minShortMargin {
+			return Result{Language: Unknown}
+		}
+		confidence *= 0.5 + 0.5*margin
+	}
+	return Result{Language: closest.profile.language, Confidence: confidence}
+}
+
+// fit is how well a text matches a language's profile, from 0 for not at
+// all to 1. Prose is judged by the share of its words that are among the
+// language's common words, since function words are what tell apart
+// languages sharing an alphabet. Texts too short to have many, such as
+// headings, are judged by their normalized n-gram distance.
+func fit(textWords []string, p profile, distance float64) float64 {
+	if len(textWords) >= minFitWords {
+		common := 0
+		for _, w := range textWords {
+			if p.words[w] {
+				common++
+			}
+		}
+		share := float64(common) / float64(len(textWords))
+		if share < minCommonShare {
+			return 0
+		}
+		return min(1, share/fullCommonShare)
+	}
+	if distance > maxShortDistance {
+		return 0
+	}
+	return 1 - distance
+}
+
+// outOfPlace is the Cavnar-Trenkle distance between a text's n-gram ranking
+// and a profile: the sum of how far each n-gram's rank is from its rank in
+// the profile, with n-grams missing from the profile costing the most.
+func outOfPlace(ranked []string, ranks map[string]int) int {
+	distance := 0
+	for i, g := range ranked {
+		r, ok := ranks[g]
+		if !ok {
+			distance += profileSize
+			continue
+		}
+		if r > i {
+			distance += r - i
+		} else {
+			distance += i - r
+		}
+	}
+	return distance
+}
+
+// rankNgrams returns the profileSize most frequent 1- to 3-grams of the
+// words in text, most frequent first. Words are padded with spaces so that
+// n-grams at word boundaries, which capture affixes and short function
+// words, are counted separately.
+func rankNgrams(text string) []string {
+	counts := make(map[string]int)
+	for _, word := range words(text) {
+		padded := []rune(" " + word + " ")
+		for n := 1; n <= 3; n++ {
+			for i := 0; i+n <= len(padded); i++ {
+				g := string(padded[i : i+n])
+				if g == " " {
+					continue
+				}
+				counts[g]++
+			}
+		}
+	}
+
+	ranked := make([]string, 0, len(counts))
+	for g := range counts {
+		ranked = append(ranked, g)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if counts[ranked[i]] != counts[ranked[j]] {
+			return counts[ranked[i]] > counts[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	if len(ranked) > profileSize {
+		ranked = ranked[:profileSize]
+	}
+	return ranked
+}
+
+// mostCommonWords returns the n most frequent words of text.
+func mostCommonWords(text string, n int) map[string]bool {
+	counts := make(map[string]int)
+	for _, w := range words(text) {
+		counts[w]++
+	}
+	ranked := make([]string, 0, len(counts))
+	for w := range counts {
+		ranked = append(ranked, w)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if counts[ranked[i]] != counts[ranked[j]] {
+			return counts[ranked[i]] > counts[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	common := make(map[string]bool, len(ranked))
+	for _, w := range ranked {
+		common[w] = true
+	}
+	return common
+}
+
+// words splits text into lower-cased words. Combining marks such as Indic
+// vowel signs and viramas belong to the word they follow.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r)
+	})
+}
+
+func truncateLetters(text string, max int) string {
+	letters := 0
+	for i, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if letters > max {
+				return text[:i]
+			}
+		}
+	}
+	return text
+}
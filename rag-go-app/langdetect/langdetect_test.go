@@ -0,0 +1,52 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "Photosynthesis converts light energy into chemical energy stored in glucose molecules within chloroplasts.", English},
+		{"english physics", "Newton's laws describe how forces change the motion of objects.", English},
+		{"hindi", "प्रकाश संश्लेषण में पौधे सूर्य के प्रकाश से भोजन बनाते हैं।", Hindi},
+		{"marathi", "प्रकाश संश्लेषणात वनस्पती सूर्यप्रकाशापासून अन्न तयार करतात आणि ऑक्सिजन सोडतात.", Marathi},
+		{"tamil", "ஒளிச்சேர்க்கையில் தாவரங்கள் சூரிய ஒளியிலிருந்து உணவைத் தயாரிக்கின்றன.", Tamil},
+		{"bengali", "সালোকসংশ্লেষণে উদ্ভিদ সূর্যের আলো থেকে খাদ্য তৈরি করে।", Bengali},
+		// unsupported languages in a supported script fit no profile
+		{"german", "Der Lehrer erklärt den Schülern die Bedeutung der Umwelt für die Zukunft unseres Landes.", Unknown},
+		{"spanish", "Las plantas convierten la energía de la luz en energía química que se almacena en la glucosa.", Unknown},
+		{"unsupported script", "Фотосинтез превращает энергию света в химическую энергию.", Unknown},
+		{"too short", "ok", Unknown},
+		{"no letters", "12 34 56.7", Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Detect(tt.text)
+			if got.Language != tt.want {
+				t.Errorf("Detect() = %+v, want %s", got, tt.want)
+			}
+			if got.Language == Unknown && got.Confidence != 0 {
+				t.Errorf("confidence = %v for an unknown language", got.Confidence)
+			}
+			if got.Confidence < 0 || got.Confidence > 1 {
+				t.Errorf("confidence = %v, want it within [0, 1]", got.Confidence)
+			}
+		})
+	}
+}
+
+func TestDetectMixedScripts(t *testing.T) {
+	// a Hindi paragraph quoting an English term is still Hindi, with less
+	// confidence than the paragraph alone
+	hindi := "प्रकाश संश्लेषण में पौधे सूर्य के प्रकाश से भोजन बनाते हैं।"
+	alone := Detect(hindi)
+	mixed := Detect(hindi + " (photosynthesis)")
+	if mixed.Language != Hindi {
+		t.Fatalf("Detect() = %+v, want %s", mixed, Hindi)
+	}
+	if mixed.Confidence >= alone.Confidence {
+		t.Errorf("confidence with an English term = %v, want less than %v", mixed.Confidence, alone.Confidence)
+	}
+}
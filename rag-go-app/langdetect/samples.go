@@ -0,0 +1,78 @@
+package langdetect
+
+// samples are the texts the language profiles are built from. They are
+// written in the register of the academic material the app ingests, and
+// lean on the function words and inflections that tell languages sharing a
+// script apart, such as Hindi "है", "के", "में" against Marathi "आहे", "च्या",
+// "मध्ये".
+var samples = []struct {
+	language string
+	script   script
+	text     string
+}{
+	{English, scriptLatin, `
+India is a vast country in which many languages are spoken. In this study we
+analysed the performance of students across several schools. The results show
+that regular practice improves the ability to learn and that the effect is
+stronger for younger children. The purpose of education is not only to give
+information but also to develop the power of thinking. The government has
+started many schemes to increase the number of schools in the villages. This
+book tries to explain the relationship between science and society. Scientists
+believe that climate change is having an effect on agriculture and on the
+water available to farmers. We should protect our environment because it is
+necessary for the generations that will come after us. The chapter describes
+the method, the data that were collected and the limitations of the analysis.
+He said that those who work hard are never defeated. Plants convert the energy
+of sunlight into chemical energy, which is stored in sugar molecules within
+their leaves. During respiration this energy is released from food and used by
+every cell of the body. Water moves through the roots into the stem and from
+there to the leaves, where most of it is lost by evaporation. An atom consists
+of a nucleus surrounded by electrons, and the number of protons determines the
+element. When a force acts on an object, its motion changes in the direction of
+the force. Heat flows from a hotter body to a colder one until both reach the
+same temperature. The cell membrane controls which substances enter or leave
+the cell. Light travels faster than sound, which is why we see lightning before
+we hear thunder. Acids react with metals to form salts and release hydrogen
+gas, while bases neutralise acids to produce salt and water.`},
+	{Hindi, scriptDevanagari, `
+भारत एक विशाल देश है जिसमें अनेक भाषाएँ बोली जाती हैं। हिंदी भारत की राजभाषा है
+और इसे करोड़ों लोग बोलते हैं। इस अध्ययन में हमने कई विद्यालयों के छात्रों के
+प्रदर्शन का विश्लेषण किया है। परिणामों से पता चलता है कि नियमित अभ्यास से सीखने की
+क्षमता में सुधार होता है और छोटे बच्चों पर इसका प्रभाव अधिक होता है। शिक्षा का
+उद्देश्य केवल जानकारी देना नहीं बल्कि सोचने की शक्ति का विकास करना भी है। सरकार ने
+गाँवों में स्कूलों की संख्या बढ़ाने के लिए कई योजनाएँ शुरू की हैं। यह पुस्तक विज्ञान
+और समाज के बीच संबंध को समझाने का प्रयास करती है। वैज्ञानिकों का मानना है कि
+जलवायु परिवर्तन का प्रभाव खेती पर और किसानों को मिलने वाले पानी पर पड़ रहा है। हमें
+अपने पर्यावरण की रक्षा करनी चाहिए क्योंकि यह आने वाली पीढ़ियों के लिए आवश्यक है। इस
+अध्याय में विधि, एकत्र किए गए आँकड़ों और विश्लेषण की सीमाओं का वर्णन किया गया है।
+उन्होंने कहा था कि मेहनत करने वालों की कभी हार नहीं होती।`},
+	{Marathi, scriptDevanagari, `
+महाराष्ट्र हे भारतातील एक महत्त्वाचे राज्य आहे आणि मराठी ही त्याची राजभाषा आहे।
+कोट्यवधी लोक मराठी बोलतात आणि लिहितात। या अभ्यासात आम्ही अनेक शाळांमधील
+विद्यार्थ्यांच्या कामगिरीचे विश्लेषण केले आहे। निकालांवरून असे दिसते की नियमित
+सरावामुळे शिकण्याची क्षमता वाढते आणि लहान मुलांवर त्याचा परिणाम अधिक होतो।
+शिक्षणाचा उद्देश फक्त माहिती देणे नसून विचार करण्याची शक्ती विकसित करणे हा आहे।
+सरकारने गावांमध्ये शाळांची संख्या वाढवण्यासाठी अनेक योजना सुरू केल्या आहेत। हे
+पुस्तक विज्ञान आणि समाज यांच्यातील संबंध समजावून सांगण्याचा प्रयत्न करते।
+शास्त्रज्ञांच्या मते हवामान बदलाचा परिणाम शेतीवर आणि शेतकऱ्यांना मिळणाऱ्या
+पाण्यावर होत आहे। आपण आपल्या पर्यावरणाचे रक्षण केले पाहिजे कारण ते पुढच्या
+पिढ्यांसाठी आवश्यक आहे। या प्रकरणात पद्धत, गोळा केलेली माहिती आणि विश्लेषणाच्या
+मर्यादा यांचे वर्णन केले आहे। सकाळी लवकर उठून तो शाळेत गेला होता पण वेळेवर पोहोचला
+नाही। त्यांनी सांगितले की कष्ट करणाऱ्यांना यश नक्की मिळते।`},
+	{Tamil, scriptTamil, `
+தமிழ் உலகின் மிகப் பழமையான மொழிகளில் ஒன்றாகும். கோடிக்கணக்கான மக்கள் தமிழ்
+பேசுகிறார்கள். இந்த ஆய்வில் பல பள்ளிகளின் மாணவர்களின் செயல்திறனை நாங்கள்
+பகுப்பாய்வு செய்தோம். வழக்கமான பயிற்சி கற்றல் திறனை மேம்படுத்துகிறது என்பதை
+முடிவுகள் காட்டுகின்றன. கல்வியின் நோக்கம் தகவல்களை வழங்குவது மட்டுமல்ல, சிந்திக்கும்
+ஆற்றலை வளர்ப்பதும் ஆகும். அரசு கிராமங்களில் பள்ளிகளின் எண்ணிக்கையை அதிகரிக்க பல
+திட்டங்களைத் தொடங்கியுள்ளது. இந்த நூல் அறிவியலுக்கும் சமூகத்துக்கும் இடையிலான உறவை
+விளக்க முயல்கிறது. காலநிலை மாற்றம் விவசாயத்தைப் பாதிக்கிறது என்று விஞ்ஞானிகள்
+நம்புகிறார்கள்.`},
+	{Bengali, scriptBengali, `
+বাংলা বিশ্বের অন্যতম প্রধান ভাষা এবং কোটি কোটি মানুষ এই ভাষায় কথা বলে। এই গবেষণায়
+আমরা বেশ কয়েকটি বিদ্যালয়ের ছাত্রদের কর্মক্ষমতা বিশ্লেষণ করেছি। ফলাফল থেকে দেখা যায়
+যে নিয়মিত অনুশীলন শেখার ক্ষমতা বাড়ায়। শিক্ষার উদ্দেশ্য শুধু তথ্য দেওয়া নয়, চিন্তা
+করার শক্তি বিকাশ করাও। সরকার গ্রামে বিদ্যালয়ের সংখ্যা বাড়ানোর জন্য অনেক প্রকল্প শুরু
+করেছে। এই বইটি বিজ্ঞান ও সমাজের মধ্যে সম্পর্ক ব্যাখ্যা করার চেষ্টা করে। বিজ্ঞানীরা মনে
+করেন যে জলবায়ু পরিবর্তন কৃষির উপর প্রভাব ফেলছে।`},
+}
@@ -0,0 +1,159 @@
+// Package lexical is a BM25 inverted index over text, for the exact terms
+// that vector search misses: formula names, section numbers, roll numbers
+// and the like.
+package lexical
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a term found in a text. Position counts the words before it,
+// including ones an analyzer dropped, such as stopwords, so phrases match
+// only words that were adjacent in the text. Start and End are the byte
+// offsets of the original word.
+type Token struct {
+	Term     string
+	Position int
+	Start    int
+	End      int
+}
+
+// Analyzer turns text into the terms it is indexed and searched by. An
+// index must analyze queries the same way as the text it holds.
+type Analyzer interface {
+	Analyze(text string) []Token
+}
+
+// Standard lowercases words and drops nothing. It suits scripts without an
+// analyzer of their own.
+type Standard struct{}
+
+// Analyze implements Analyzer.
+func (Standard) Analyze(text string) []Token {
+	tokens := words(text)
+	for i := range tokens {
+		tokens[i].Term = strings.ToLower(tokens[i].Term)
+	}
+	return tokens
+}
+
+// English lowercases words, drops English stopwords and reduces words to
+// their Porter stems, so "indexing" finds "indexed". Words with digits are
+// kept whole, so "H2SO4" and "21BCE1234" are found only as written.
+type English struct{}
+
+// Analyze implements Analyzer.
+func (English) Analyze(text string) []Token {
+	return filter(words(text), englishTerm)
+}
+
+// Devanagari normalizes spelling variants in Hindi and Marathi text, drops
+// stopwords of both languages and strips common inflectional suffixes.
+// Latin words in the text are treated as by Standard.
+type Devanagari struct{}
+
+// Analyze implements Analyzer.
+func (Devanagari) Analyze(text string) []Token {
+	return filter(words(text), func(word string) string {
+		if isDevanagari(word) {
+			return devanagariTerm(word)
+		}
+		return strings.ToLower(word)
+	})
+}
+
+// Multilingual picks the analysis for each word by its script: Devanagari
+// words as by Devanagari, Latin words as by English and the rest as by
+// Standard. It suits collections that mix languages, even within a text.
+type Multilingual struct{}
+
+// Analyze implements Analyzer.
+func (Multilingual) Analyze(text string) []Token {
+	return filter(words(text), func(word string) string {
+		switch {
+		case isDevanagari(word):
+			return devanagariTerm(word)
+		case isLatin(word):
+			return englishTerm(word)
+		}
+		return strings.ToLower(word)
+	})
+}
+
+// filter replaces each token's term with term(word), dropping tokens for
+// which it returns "".
+func filter(tokens []Token, term func(string) string) []Token {
+	out := tokens[:0]
+	for _, t := range tokens {
+		if t.Term = term(t.Term); t.Term != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func englishTerm(word string) string {
+	word = strings.ToLower(word)
+	if englishStopwords[word] {
+		return ""
+	}
+	if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+		return word
+	}
+	return stem(word)
+}
+
+// words splits text into runs of letters, digits and combining marks.
+// Zero-width joiners, which shape Indic conjuncts, stay inside words, and
+// a full stop between digits does too, so "3.2.1" is one word.
+func words(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if r == '.' && start >= 0 && i+1 < len(text) {
+			prev, _ := utf8.DecodeLastRuneInString(text[:i])
+			next, _ := utf8.DecodeRuneInString(text[i+1:])
+			if unicode.IsDigit(prev) && unicode.IsDigit(next) {
+				continue
+			}
+		}
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: text[start:i], Position: len(tokens), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Term: text[start:], Position: len(tokens), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '\u200c' || r == '\u200d'
+}
+
+func isDevanagari(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Devanagari, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLatin(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Latin, r) {
+			return true
+		}
+	}
+	return false
+}
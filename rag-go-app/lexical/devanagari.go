@@ -0,0 +1,149 @@
+package lexical
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// devanagariTerm normalizes, filters and stems one Devanagari word.
+func devanagariTerm(word string) string {
+	word = normalizeDevanagari(word)
+	if devanagariStopwords[word] {
+		return ""
+	}
+	return stemDevanagari(word)
+}
+
+// Devanagari code points used in normalization.
+const (
+	virama       = '्'
+	nukta        = '़'
+	anusvara     = 'ं'
+	chandrabindu = 'ँ'
+)
+
+// nuktaForms maps precomposed letters with a nukta to their base letters.
+var nuktaForms = map[rune]rune{
+	'क़': 'क', 'ख़': 'ख', 'ग़': 'ग', 'ज़': 'ज',
+	'ड़': 'ड', 'ढ़': 'ढ', 'फ़': 'फ', 'य़': 'य',
+	'ऩ': 'न', 'ऱ': 'र', 'ऴ': 'ळ',
+}
+
+// vowelVariants maps vowels and vowel signs to the forms they are often
+// spelled with interchangeably: long and short i and u, and the candra
+// vowels of English loanwords.
+var vowelVariants = map[rune]rune{
+	'ई': 'इ', 'ऊ': 'उ', 'ी': 'ि', 'ू': 'ु',
+	'ऍ': 'ए', 'ऑ': 'ओ', 'ॅ': 'े', 'ॉ': 'ो',
+}
+
+// nasalClass maps each stop consonant to the nasal of its class. A half
+// nasal is spelled interchangeably with an anusvara only before a stop of
+// its own class, as in हिन्दी and हिंदी; before other consonants, as in
+// अन्य and कन्या, the half nasal is the only spelling.
+var nasalClass = map[rune]rune{
+	'क': 'ङ', 'ख': 'ङ', 'ग': 'ङ', 'घ': 'ङ',
+	'च': 'ञ', 'छ': 'ञ', 'ज': 'ञ', 'झ': 'ञ',
+	'ट': 'ण', 'ठ': 'ण', 'ड': 'ण', 'ढ': 'ण',
+	'त': 'न', 'थ': 'न', 'द': 'न', 'ध': 'न',
+	'प': 'म', 'फ': 'म', 'ब': 'म', 'भ': 'म',
+}
+
+// normalizeDevanagari folds the spelling variants of a word together:
+// nuktas are dropped, chandrabindu becomes anusvara, a half nasal inside a
+// word before a stop of its class becomes anusvara, long i and u become
+// short, candra vowels become plain ones, zero-width joiners are dropped
+// and Devanagari digits become ASCII ones.
+func normalizeDevanagari(word string) string {
+	runes := []rune(word)
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == nukta || r == '\u200c' || r == '\u200d':
+			continue
+		case r == chandrabindu:
+			r = anusvara
+		case r >= '०' && r <= '९':
+			r = '0' + (r - '०')
+		case i > 0 && i+2 < len(runes) && runes[i+1] == virama && nasalClass[baseLetter(runes[i+2])] == r:
+			out = append(out, anusvara)
+			i++
+			continue
+		}
+		r = baseLetter(r)
+		if plain, ok := vowelVariants[r]; ok {
+			r = plain
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// baseLetter returns r without a precomposed nukta.
+func baseLetter(r rune) rune {
+	if base, ok := nuktaForms[r]; ok {
+		return base
+	}
+	return r
+}
+
+// devanagariSuffixes are inflectional suffixes stripped from words, longest
+// first: the Hindi suffixes of Ramanathan and Rao's light stemmer and the
+// Marathi genitive and case endings. Words are normalized before they are
+// stemmed, so the suffixes are too, which folds ती into ति and so on.
+// Plurals in ियों and ियां are handled by devanagariPlurals instead.
+var devanagariSuffixes = func() []string {
+	hindi := []string{
+		"ाएंगी", "ाएंगे", "ाऊंगी", "ाऊंगा", "ाइयाँ", "ाइयों", "ाइयां",
+		"ाएगी", "ाएगा", "ाओगी", "ाओगे", "एंगी", "ेंगी", "एंगे", "ेंगे", "ूंगी", "ूंगा",
+		"ातीं", "नाओं", "नाएं", "ताओं", "ताएं",
+		"ाकर", "ाइए", "ाईं", "ाया", "ेगी", "ेगा", "ोगी", "ोगे", "ाने", "ाना", "ाते",
+		"ाती", "ाता", "तीं", "ाओं", "ाएं", "ुओं", "ुएं", "ुआं",
+		"कर", "ाओ", "िए", "ाई", "ाए", "ने", "नी", "ना", "ते", "ीं", "ती", "ता",
+		"ाँ", "ां", "ों", "ें",
+		"ो", "े", "ू", "ु", "ी", "ि", "ा",
+	}
+	marathi := []string{
+		"ांच्या", "ाच्या", "च्या", "मध्ये", "साठी", "ांना", "ांचा", "ांची", "ांचे",
+		"ाचा", "ाची", "ाचे",
+	}
+
+	seen := make(map[string]bool)
+	var suffixes []string
+	for _, suffix := range append(hindi, marathi...) {
+		suffix = normalizeDevanagari(suffix)
+		if !seen[suffix] {
+			seen[suffix] = true
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	sort.SliceStable(suffixes, func(i, j int) bool {
+		return utf8.RuneCountInString(suffixes[i]) > utf8.RuneCountInString(suffixes[j])
+	})
+	return suffixes
+}()
+
+// devanagariPlurals are the plural endings of nouns in ी and ि, normalized.
+// They are replaced by ि before stemming, so that a plural is stemmed as
+// its singular is: राजधानियों as राजधानी.
+var devanagariPlurals = []string{"ियों", "ियां"}
+
+// stemDevanagari strips the longest suffix that leaves at least two
+// characters, after turning a plural into its singular.
+func stemDevanagari(word string) string {
+	for _, plural := range devanagariPlurals {
+		if stem, ok := strings.CutSuffix(word, plural); ok && utf8.RuneCountInString(stem) >= 2 {
+			word = stem + "ि"
+			break
+		}
+	}
+	n := utf8.RuneCountInString(word)
+	for _, suffix := range devanagariSuffixes {
+		if strings.HasSuffix(word, suffix) && n-utf8.RuneCountInString(suffix) >= 2 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
@@ -0,0 +1,69 @@
+package lexical
+
+import "testing"
+
+func TestNormalizeDevanagari(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"हिन्दी", "हिंदि"},
+		{"हिंदी", "हिंदि"},
+		{"सम्बन्ध", "संबंध"},
+		{"पण्डित", "पंडित"},
+		{"गङ्गा", "गंगा"},
+		{"चञ्चल", "चंचल"},
+		// a half nasal before a consonant of another class, or starting
+		// the word, is the only spelling
+		{"अन्य", "अन्य"},
+		{"कन्या", "कन्या"},
+		{"न्यूटन", "न्युटन"},
+		{"सम्मान", "सम्मान"},
+		{"पढ़ना", "पढना"},
+		{"ज़िंदगी", "जिंदगि"},
+		{"आँख", "आंख"},
+		{"डॉक्टर", "डोक्टर"},
+		{"२०२४", "2024"},
+	}
+	for _, tt := range tests {
+		if got := normalizeDevanagari(tt.word); got != tt.want {
+			t.Errorf("normalizeDevanagari(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestDevanagariTermConflates(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+	}{
+		{"verb forms", []string{"पढ़ता", "पढ़ती", "पढ़ते", "पढ़ना"}},
+		{"plural in ियों", []string{"राजधानी", "राजधानियों", "राजधानियाँ"}},
+		{"plural of ि", []string{"प्रगति", "प्रगतियों"}},
+		{"plural in इयों", []string{"लड़ाई", "लड़ाइयों"}},
+		{"plural in ियां", []string{"लड़की", "लड़कियाँ", "लड़कियों"}},
+		{"spelling", []string{"हिन्दी", "हिंदी"}},
+		{"marathi cases", []string{"भारताच्या", "भारताचा", "भारत"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := devanagariTerm(tt.words[0])
+			if want == "" {
+				t.Fatalf("%q was dropped", tt.words[0])
+			}
+			for _, w := range tt.words[1:] {
+				if got := devanagariTerm(w); got != want {
+					t.Errorf("term(%q) = %q, want %q as for %q", w, got, want, tt.words[0])
+				}
+			}
+		})
+	}
+}
+
+func TestDevanagariStopwords(t *testing.T) {
+	for _, w := range []string{"और", "की", "में", "आणि", "आहे"} {
+		if got := devanagariTerm(w); got != "" {
+			t.Errorf("term(%q) = %q, want it dropped", w, got)
+		}
+	}
+}
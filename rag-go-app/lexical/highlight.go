@@ -0,0 +1,165 @@
+package lexical
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ellipsis marks where a snippet cuts its text.
+const ellipsis = "…"
+
+// Snippet is an excerpt of a text with the query terms it contains.
+// Highlights are byte ranges of Text, in order.
+type Snippet struct {
+	Text       string      `json:"text"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// Highlight is a byte range of a snippet's text.
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// MakeSnippet excerpts about width bytes of text around its densest run of
+// the terms in q, as analyzed by analyzer, and highlights every word that
+// matches a query term or a word of a quoted phrase. The excerpt starts and
+// ends at word boundaries and is marked with an ellipsis where it cuts the
+// text. Line breaks become spaces.
+func MakeSnippet(analyzer Analyzer, text string, q Query, width int) Snippet {
+	terms := make(map[string]bool)
+	for _, w := range q.Words {
+		terms[w.Term] = true
+	}
+	for _, p := range q.Phrases {
+		for _, t := range p.Tokens {
+			terms[t.Term] = true
+		}
+	}
+	var matches []Token
+	for _, t := range analyzer.Analyze(text) {
+		if terms[t.Term] {
+			matches = append(matches, t)
+		}
+	}
+
+	start, end := snippetWindow(text, matches, width)
+	var snippet strings.Builder
+	offset := 0
+	if strings.TrimSpace(text[:start]) != "" {
+		snippet.WriteString(ellipsis)
+		offset = len(ellipsis)
+	}
+	snippet.WriteString(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, text[start:end]))
+	if strings.TrimSpace(text[end:]) != "" {
+		snippet.WriteString(ellipsis)
+	}
+
+	highlights := []Highlight{}
+	for _, t := range matches {
+		if t.Start >= start && t.End <= end {
+			highlights = append(highlights, Highlight{Start: t.Start - start + offset, End: t.End - start + offset})
+		}
+	}
+	return Snippet{Text: snippet.String(), Highlights: highlights}
+}
+
+// Mark returns the snippet's text with each highlight wrapped in pre and
+// post. The text around and inside highlights is passed through escape, if
+// not nil, so the result can be embedded in markup.
+func (s Snippet) Mark(pre, post string, escape func(string) string) string {
+	if escape == nil {
+		escape = func(s string) string { return s }
+	}
+	var b strings.Builder
+	last := 0
+	for _, h := range s.Highlights {
+		b.WriteString(escape(s.Text[last:h.Start]))
+		b.WriteString(pre)
+		b.WriteString(escape(s.Text[h.Start:h.End]))
+		b.WriteString(post)
+		last = h.End
+	}
+	b.WriteString(escape(s.Text[last:]))
+	return b.String()
+}
+
+// snippetWindow picks the byte range of text to excerpt: the width bytes
+// holding the most distinct matched terms, then the most matches, widened
+// evenly around them and trimmed to word boundaries.
+func snippetWindow(text string, matches []Token, width int) (int, int) {
+	if width <= 0 || len(text) <= width {
+		return 0, len(text)
+	}
+
+	first, last := 0, -1
+	bestTerms, bestCount := 0, 0
+	for i := range matches {
+		distinct := make(map[string]bool)
+		j := i
+		for ; j < len(matches) && matches[j].End-matches[i].Start <= width; j++ {
+			distinct[matches[j].Term] = true
+		}
+		if len(distinct) > bestTerms || (len(distinct) == bestTerms && j-i > bestCount) {
+			first, last = i, j-1
+			bestTerms, bestCount = len(distinct), j-i
+		}
+	}
+
+	start := 0
+	if last >= 0 {
+		lo, hi := matches[first].Start, matches[last].End
+		start = max(0, lo-(width-(hi-lo))/2)
+	}
+	end := min(len(text), start+width)
+	start = max(0, end-width)
+
+	// Trim partial words, keeping the matches
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	keepFrom, keepTo := len(text), 0
+	if last >= 0 {
+		keepFrom, keepTo = matches[first].Start, matches[last].End
+	}
+	if start > 0 && !spaceBefore(text, start) {
+		if i := strings.IndexFunc(text[start:], unicode.IsSpace); i >= 0 && start+i < keepFrom {
+			start += i
+		}
+	}
+	if end < len(text) && !spaceAt(text, end) {
+		if i := strings.LastIndexFunc(text[:end], unicode.IsSpace); i > start && i >= keepTo {
+			end = i
+		}
+	}
+	for start < end && spaceAt(text, start) {
+		_, size := utf8.DecodeRuneInString(text[start:])
+		start += size
+	}
+	for end > start && spaceBefore(text, end) {
+		_, size := utf8.DecodeLastRuneInString(text[:end])
+		end -= size
+	}
+	return start, end
+}
+
+// spaceAt reports whether the rune at byte i of text is a space.
+func spaceAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsSpace(r)
+}
+
+// spaceBefore reports whether the rune ending at byte i of text is a space.
+func spaceBefore(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsSpace(r)
+}
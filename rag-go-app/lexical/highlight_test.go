@@ -0,0 +1,68 @@
+package lexical
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMakeSnippet(t *testing.T) {
+	long := strings.Repeat("Filler words about nothing much. ", 20) +
+		"Volta stacked zinc and copper discs into the first battery." +
+		strings.Repeat(" More filler words about nothing much.", 20)
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		width int
+		want  string
+	}{
+		{
+			name:  "whole text with stemmed matches",
+			text:  "Batteries store charge; a battery\nreleases it.",
+			query: "battery",
+			width: 240,
+			want:  "[Batteries] store charge; a [battery] releases it.",
+		},
+		{
+			name:  "phrase words",
+			text:  "The theory of relativity changed physics.",
+			query: `"theory of relativity"`,
+			width: 240,
+			want:  "The [theory] of [relativity] changed physics.",
+		},
+		{
+			name:  "no matches",
+			text:  "Nothing to see here.",
+			query: "battery",
+			width: 240,
+			want:  "Nothing to see here.",
+		},
+		{
+			name:  "cut around the matches",
+			text:  long,
+			query: "zinc copper battery",
+			width: 80,
+			want:  "…Volta stacked [zinc] and [copper] discs into the first [battery]. More filler…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := English{}
+			snippet := MakeSnippet(analyzer, tt.text, ParseQuery(analyzer, tt.query), tt.width)
+			if got := snippet.Mark("[", "]", nil); got != tt.want {
+				t.Errorf("snippet = %q, want %q", got, tt.want)
+			}
+			if len(snippet.Text) > tt.width+2*len(ellipsis) {
+				t.Errorf("snippet is %d bytes, want at most %d and the ellipses", len(snippet.Text), tt.width)
+			}
+		})
+	}
+}
+
+func TestSnippetMarkEscapes(t *testing.T) {
+	s := Snippet{Text: "a <b> & c", Highlights: []Highlight{{Start: 2, End: 5}}}
+	escape := strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;").Replace
+	if got, want := s.Mark("<mark>", "</mark>", escape), "a <mark>&lt;b&gt;</mark> &amp; c"; got != want {
+		t.Errorf("Mark() = %q, want %q", got, want)
+	}
+}
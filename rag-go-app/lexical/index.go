@@ -0,0 +1,349 @@
+package lexical
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"rag-go-app/vectorindex"
+)
+
+// Errors returned by Index.
+var (
+	ErrExists   = errors.New("text already in index")
+	ErrNotFound = errors.New("text not in index")
+)
+
+// BM25 holds the parameters of the BM25 ranking function. K1 controls how
+// quickly repeated occurrences of a term stop adding to the score, and B
+// how much long texts are penalized.
+type BM25 struct {
+	K1 float64
+	B  float64
+}
+
+// DefaultBM25 returns the customary parameters.
+func DefaultBM25() BM25 {
+	return BM25{K1: 1.2, B: 0.75}
+}
+
+// Result is one search hit. Terms gives the part of the score each query
+// word or quoted phrase contributed.
+type Result struct {
+	ID       int64                `json:"id"`
+	Score    float64              `json:"score"`
+	Terms    map[string]float64   `json:"terms"`
+	Metadata vectorindex.Metadata `json:"metadata,omitempty"`
+}
+
+// Index is an inverted index with positional postings, ranking texts with
+// BM25. Texts are identified by ID, typically chunk IDs, and carry the same
+// metadata as vector index entries so both can be filtered alike. Index is
+// safe for concurrent use.
+type Index struct {
+	analyzer Analyzer
+	params   BM25
+
+	mu       sync.RWMutex
+	docs     map[int64]*document
+	postings map[string]map[int64][]int32
+	totalLen int
+}
+
+type document struct {
+	length   int
+	terms    []string
+	metadata vectorindex.Metadata
+}
+
+// NewIndex creates an empty index that analyzes texts and queries with
+// analyzer.
+func NewIndex(analyzer Analyzer, params BM25) *Index {
+	return &Index{
+		analyzer: analyzer,
+		params:   params,
+		docs:     make(map[int64]*document),
+		postings: make(map[string]map[int64][]int32),
+	}
+}
+
+// Analyzer returns the analyzer the index uses.
+func (x *Index) Analyzer() Analyzer {
+	return x.analyzer
+}
+
+// Len returns the number of texts in the index.
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Insert adds a text. It fails with ErrExists if id is already indexed.
+func (x *Index) Insert(id int64, text string, metadata vectorindex.Metadata) error {
+	tokens := x.analyzer.Analyze(text)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, exists := x.docs[id]; exists {
+		return ErrExists
+	}
+	x.add(id, tokens, metadata)
+	return nil
+}
+
+// Update replaces an indexed text. It fails with ErrNotFound if id is not
+// indexed.
+func (x *Index) Update(id int64, text string, metadata vectorindex.Metadata) error {
+	tokens := x.analyzer.Analyze(text)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, exists := x.docs[id]; !exists {
+		return ErrNotFound
+	}
+	x.remove(id)
+	x.add(id, tokens, metadata)
+	return nil
+}
+
+// Delete removes a text. It fails with ErrNotFound if id is not indexed.
+func (x *Index) Delete(id int64) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, exists := x.docs[id]; !exists {
+		return ErrNotFound
+	}
+	x.remove(id)
+	return nil
+}
+
+// add indexes tokens under id. The caller holds x.mu.
+func (x *Index) add(id int64, tokens []Token, metadata vectorindex.Metadata) {
+	doc := &document{length: len(tokens), metadata: copyMetadata(metadata)}
+	for _, t := range tokens {
+		postings, ok := x.postings[t.Term]
+		if !ok {
+			postings = make(map[int64][]int32)
+			x.postings[t.Term] = postings
+		}
+		if _, seen := postings[id]; !seen {
+			doc.terms = append(doc.terms, t.Term)
+		}
+		postings[id] = append(postings[id], int32(t.Position))
+	}
+	x.docs[id] = doc
+	x.totalLen += doc.length
+}
+
+// remove drops id from the index. The caller holds x.mu.
+func (x *Index) remove(id int64) {
+	doc := x.docs[id]
+	for _, term := range doc.terms {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	delete(x.docs, id)
+	x.totalLen -= doc.length
+}
+
+// Query is a parsed search. Words are optional: a text matching more of
+// them ranks higher. Phrases, written in double quotes, are required: only
+// texts containing every phrase match.
+type Query struct {
+	Words   []QueryTerm
+	Phrases []QueryPhrase
+}
+
+// QueryTerm is an analyzed query word and the word as written.
+type QueryTerm struct {
+	Term string
+	Text string
+}
+
+// QueryPhrase is a quoted phrase, analyzed. Tokens keep their positions
+// relative to each other, so words dropped from the phrase, such as
+// stopwords, still have to be there to fill the gap.
+type QueryPhrase struct {
+	Tokens []Token
+	Text   string
+}
+
+// ParseQuery splits q into quoted phrases and loose words and analyzes
+// them with analyzer. An unbalanced quote runs to the end of the query.
+func ParseQuery(analyzer Analyzer, q string) Query {
+	var query Query
+	seen := make(map[string]bool)
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			if tokens := analyzer.Analyze(part); len(tokens) > 0 {
+				query.Phrases = append(query.Phrases, QueryPhrase{Tokens: tokens, Text: strings.TrimSpace(part)})
+			}
+			continue
+		}
+		for _, t := range analyzer.Analyze(part) {
+			if !seen[t.Term] {
+				seen[t.Term] = true
+				query.Words = append(query.Words, QueryTerm{Term: t.Term, Text: part[t.Start:t.End]})
+			}
+		}
+	}
+	return query
+}
+
+// IsEmpty reports whether the query has nothing to search for, for
+// example because it held only stopwords.
+func (q Query) IsEmpty() bool {
+	return len(q.Words) == 0 && len(q.Phrases) == 0
+}
+
+// Search parses query with the index's analyzer and returns the k best
+// texts whose metadata matches filter, best first. A nil filter matches
+// everything.
+func (x *Index) Search(query string, k int, filter vectorindex.Filter) ([]Result, error) {
+	return x.SearchQuery(ParseQuery(x.analyzer, query), k, filter)
+}
+
+// SearchQuery is like Search for a query already parsed.
+func (x *Index) SearchQuery(q Query, k int, filter vectorindex.Filter) ([]Result, error) {
+	if k <= 0 || q.IsEmpty() {
+		return nil, nil
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	n := float64(len(x.docs))
+	if n == 0 {
+		return nil, nil
+	}
+	avgLen := float64(x.totalLen) / n
+
+	allowed := func(id int64) bool {
+		return filter == nil || filter.Match(x.docs[id].metadata)
+	}
+	scores := make(map[int64]*Result)
+	hit := func(id int64, key string, score float64) {
+		r, ok := scores[id]
+		if !ok {
+			r = &Result{ID: id, Terms: make(map[string]float64)}
+			scores[id] = r
+		}
+		r.Score += score
+		r.Terms[key] += score
+	}
+
+	// Phrases decide which texts match at all
+	var required map[int64]bool
+	for _, phrase := range q.Phrases {
+		matches := x.phraseMatches(phrase.Tokens)
+		next := make(map[int64]bool)
+		for id, tf := range matches {
+			if (required == nil || required[id]) && allowed(id) {
+				next[id] = true
+				hit(id, `"`+phrase.Text+`"`, x.termScore(tf, len(matches), n, x.docs[id].length, avgLen))
+			}
+		}
+		required = next
+	}
+
+	for _, word := range q.Words {
+		postings := x.postings[word.Term]
+		for id, positions := range postings {
+			if required != nil && !required[id] || !allowed(id) {
+				continue
+			}
+			hit(id, word.Text, x.termScore(len(positions), len(postings), n, x.docs[id].length, avgLen))
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, r := range scores {
+		if required == nil || required[id] {
+			r.Metadata = copyMetadata(x.docs[id].metadata)
+			results = append(results, *r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// termScore is the BM25 score of a term, or phrase, occurring tf times in
+// a text of length docLen and in df of n texts.
+func (x *Index) termScore(tf int, df int, n float64, docLen int, avgLen float64) float64 {
+	idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	k1, b := x.params.K1, x.params.B
+	norm := k1 * (1 - b + b*float64(docLen)/avgLen)
+	return idf * float64(tf) * (k1 + 1) / (float64(tf) + norm)
+}
+
+// phraseMatches returns, for each text containing the phrase, how many
+// times it does. The caller holds x.mu.
+func (x *Index) phraseMatches(tokens []Token) map[int64]int {
+	lists := make([]map[int64][]int32, len(tokens))
+	for i, t := range tokens {
+		lists[i] = x.postings[t.Term]
+		if len(lists[i]) == 0 {
+			return nil
+		}
+	}
+
+	// Walk the rarest term's texts and check the others at the offsets
+	// the phrase puts them
+	rarest := 0
+	for i := range lists {
+		if len(lists[i]) < len(lists[rarest]) {
+			rarest = i
+		}
+	}
+	matches := make(map[int64]int)
+	for id, positions := range lists[rarest] {
+		count := 0
+		for _, p := range positions {
+			start := p - int32(tokens[rarest].Position-tokens[0].Position)
+			if phraseAt(lists, tokens, id, start) {
+				count++
+			}
+		}
+		if count > 0 {
+			matches[id] = count
+		}
+	}
+	return matches
+}
+
+// phraseAt reports whether text id has every phrase token at its offset
+// from start.
+func phraseAt(lists []map[int64][]int32, tokens []Token, id int64, start int32) bool {
+	for i, t := range tokens {
+		want := start + int32(t.Position-tokens[0].Position)
+		positions := lists[i][id]
+		j := sort.Search(len(positions), func(j int) bool { return positions[j] >= want })
+		if j == len(positions) || positions[j] != want {
+			return false
+		}
+	}
+	return true
+}
+
+func copyMetadata(m vectorindex.Metadata) vectorindex.Metadata {
+	if m == nil {
+		return nil
+	}
+	out := make(vectorindex.Metadata, len(m))
+	for k, v := range m {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
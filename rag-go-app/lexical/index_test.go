@@ -0,0 +1,104 @@
+package lexical
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPhrasePositionsAcrossStopwords(t *testing.T) {
+	x := NewIndex(English{}, DefaultBM25())
+	texts := map[int64]string{
+		1: "The theory of relativity was published in 1905.",
+		2: "A theory, relativity and quantum mechanics.",
+		3: "Relativity of the theory.",
+		4: "A theory in relativity, and the theory of relativity again.",
+		5: "Theories of relativistic motion.",
+	}
+	for id, text := range texts {
+		if err := x.Insert(id, text, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		// the dropped "of" still holds its place, but any dropped word
+		// may fill it
+		{`"theory of relativity"`, []int64{1, 4}},
+		{`"theory relativity"`, []int64{2}},
+		{`"relativity of theory"`, nil},
+		{`"relativity of the theory"`, []int64{3, 4}},
+		{`"theory of relativity was published"`, []int64{1}},
+		// leading and trailing stopwords do not constrain the phrase
+		{`"the theory"`, []int64{1, 2, 3, 4, 5}},
+		{`"of the"`, nil},
+		{`"published in 1905"`, []int64{1}},
+		{`"theory of relativity" quantum`, []int64{1, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := x.Search(tt.query, 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, r := range results {
+				got = append(got, r.ID)
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("matched %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAnalyzerPositionsCountDroppedWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		analyzer Analyzer
+		text     string
+		want     []Token
+	}{
+		{
+			name:     "english",
+			analyzer: English{},
+			text:     "the theory of relativity",
+			want: []Token{
+				{Term: "theori", Position: 1, Start: 4, End: 10},
+				{Term: "rel", Position: 3, Start: 14, End: 24},
+			},
+		},
+		{
+			name:     "devanagari",
+			analyzer: Devanagari{},
+			text:     "भारत की राजधानी",
+			want: []Token{
+				{Term: "भारत", Position: 0, Start: 0, End: 12},
+				{Term: devanagariTerm("राजधानी"), Position: 2, Start: 20, End: 41},
+			},
+		},
+		{
+			name:     "section numbers",
+			analyzer: English{},
+			text:     "see section 3.2.1 of H2SO4",
+			want: []Token{
+				{Term: "see", Position: 0, Start: 0, End: 3},
+				{Term: "section", Position: 1, Start: 4, End: 11},
+				{Term: "3.2.1", Position: 2, Start: 12, End: 17},
+				{Term: "h2so4", Position: 4, Start: 21, End: 26},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.analyzer.Analyze(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Analyze(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package lexical
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	// From Porter's paper and the vocabulary distributed with the
+	// reference implementation
+	tests := []struct {
+		word string
+		want string
+	}{
+		// step 1a
+		{"caresses", "caress"}, {"ponies", "poni"}, {"ties", "ti"},
+		{"caress", "caress"}, {"cats", "cat"},
+		// step 1b
+		{"feed", "feed"}, {"agreed", "agre"}, {"plastered", "plaster"},
+		{"bled", "bled"}, {"motoring", "motor"}, {"sing", "sing"},
+		{"conflated", "conflat"}, {"troubled", "troubl"}, {"sized", "size"},
+		{"hopping", "hop"}, {"tanned", "tan"}, {"falling", "fall"},
+		{"hissing", "hiss"}, {"fizzed", "fizz"}, {"failing", "fail"},
+		{"filing", "file"},
+		// step 1c
+		{"happy", "happi"}, {"sky", "sky"},
+		// step 2
+		{"relational", "relat"}, {"conditional", "condit"}, {"rational", "ration"},
+		{"valenci", "valenc"}, {"hesitanci", "hesit"}, {"digitizer", "digit"},
+		{"conformabli", "conform"}, {"radicalli", "radic"}, {"differentli", "differ"},
+		{"vileli", "vile"}, {"analogousli", "analog"}, {"vietnamization", "vietnam"},
+		{"predication", "predic"}, {"operator", "oper"}, {"feudalism", "feudal"},
+		{"decisiveness", "decis"}, {"hopefulness", "hope"}, {"callousness", "callous"},
+		{"formaliti", "formal"}, {"sensitiviti", "sensit"}, {"sensibiliti", "sensibl"},
+		{"archaeology", "archaeolog"},
+		// step 3
+		{"triplicate", "triplic"}, {"formative", "form"}, {"formalize", "formal"},
+		{"electriciti", "electr"}, {"electrical", "electr"}, {"hopeful", "hope"},
+		{"goodness", "good"},
+		// step 4
+		{"revival", "reviv"}, {"allowance", "allow"}, {"inference", "infer"},
+		{"airliner", "airlin"}, {"gyroscopic", "gyroscop"}, {"adjustable", "adjust"},
+		{"defensible", "defens"}, {"irritant", "irrit"}, {"replacement", "replac"},
+		{"adjustment", "adjust"}, {"dependent", "depend"}, {"adoption", "adopt"},
+		{"communism", "commun"}, {"activate", "activ"}, {"angulariti", "angular"},
+		{"homologous", "homolog"}, {"effective", "effect"}, {"bowdlerize", "bowdler"},
+		// step 5
+		{"probate", "probat"}, {"rate", "rate"}, {"cease", "ceas"},
+		{"controll", "control"}, {"roll", "roll"},
+		// whole words
+		{"generalizations", "gener"}, {"oscillators", "oscil"}, {"abilities", "abil"},
+		{"absolutely", "absolut"}, {"generously", "gener"}, {"accordingly", "accordingli"},
+		{"dying", "dy"}, {"lying", "ly"}, {"news", "new"}, {"syllogism", "syllog"},
+		// left alone
+		{"is", "is"}, {"a", "a"}, {"naïve", "naïve"}, {"x-ray", "x-ray"},
+	}
+	for _, tt := range tests {
+		if got := stem(tt.word); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package lexical
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// englishStopwords are common English function words.
+var englishStopwords = wordSet(
+	"a", "an", "and", "are", "as", "at", "be", "been", "but", "by", "for",
+	"from", "had", "has", "have", "he", "her", "his", "if", "in", "into",
+	"is", "it", "its", "of", "on", "or", "she", "such", "that", "the",
+	"their", "then", "there", "these", "they", "this", "to", "was", "were",
+	"which", "will", "with", "we", "our", "can", "not", "no", "than", "so",
+)
+
+// devanagariStopwords are common Hindi and Marathi function words, in
+// normalized form.
+var devanagariStopwords = func() map[string]bool {
+	words := []string{
+		// Hindi
+		"और", "का", "की", "के", "को", "में", "से", "पर", "है", "हैं", "था",
+		"थी", "थे", "यह", "वह", "ये", "वे", "इस", "उस", "इन", "उन", "एक",
+		"भी", "तो", "ही", "या", "ने", "हो", "जो", "कि", "तक", "साथ", "लिए",
+		"द्वारा", "रहा", "रही", "रहे", "गया", "गई", "गए", "किया", "करता",
+		"करते", "करना", "होता", "होती", "होते", "अपने", "कुछ", "सकता", "जा",
+		// Marathi
+		"आणि", "आहे", "आहेत", "होता", "होती", "होते", "हे", "ही", "हा", "तो",
+		"ती", "ते", "या", "व", "की", "ला", "ने", "चा", "ची", "चे", "त",
+		"मध्ये", "साठी", "पण", "तर", "एक", "म्हणून", "असे", "असा", "केले",
+		"करून", "नाही", "आपण", "त्या", "त्याचे", "त्यांच्या",
+	}
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[normalizeDevanagari(w)] = true
+	}
+	return set
+}()
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"rag-go-app/httpretry"
+)
+
+// HTTPConfig configures an HTTPProvider.
+//
+// URL is the base of an OpenAI-compatible API, such as
+// https://api.openai.com/v1 or http://localhost:11434/v1 for Ollama, to
+// which /chat/completions is appended. Failed requests are retried
+// MaxRetries times when the server is rate limiting or failing; a negative
+// MaxRetries disables retries. Timeout is ignored when Client is set.
+type HTTPConfig struct {
+	URL        string
+	APIKey     string
+	Model      string
+	Timeout    time.Duration
+	MaxRetries int
+	Client     *http.Client
+}
+
+// Defaults for HTTPConfig fields left zero. Answers take longer than
+// embeddings, so the timeout is generous.
+const (
+	DefaultHTTPTimeout    = 2 * time.Minute
+	DefaultHTTPMaxRetries = 2
+)
+
+// HTTPProvider completes requests with a remote model server speaking the
+// OpenAI chat completions API.
+type HTTPProvider struct {
+	cfg    HTTPConfig
+	client *http.Client
+}
+
+// NewHTTPProvider creates a provider for the server described by cfg. It
+// does not contact the server.
+func NewHTTPProvider(cfg HTTPConfig) (*HTTPProvider, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("LLM URL is required")
+	}
+	if cfg.Model == "" {
+		return nil, errors.New("LLM model is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultHTTPTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultHTTPMaxRetries
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &HTTPProvider{cfg: cfg, client: client}, nil
+}
+
+// Name implements Provider.
+func (p *HTTPProvider) Name() string {
+	return p.cfg.Model
+}
+
+// Complete implements Provider.
+func (p *HTTPProvider) Complete(ctx context.Context, req Request) (string, error) {
+	var reply string
+	err := httpretry.Do(ctx, p.cfg.MaxRetries, func() error {
+		var err error
+		reply, err = p.request(ctx, req)
+		return err
+	})
+	return reply, err
+}
+
+func (p *HTTPProvider) request(ctx context.Context, r Request) (string, error) {
+	body := map[string]any{
+		"model":       p.cfg.Model,
+		"messages":    r.Messages,
+		"temperature": r.Temperature,
+	}
+	if r.MaxTokens > 0 {
+		body["max_tokens"] = r.MaxTokens
+	}
+	data, err := httpretry.PostJSON(ctx, p.client, p.cfg.URL+"/chat/completions", p.cfg.APIKey, body, "LLM server")
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("decoding LLM response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", errors.New("LLM server returned no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	request := GroundedRequest("What is Ohm's law?", []Source{{Number: 1, Label: "Physics", Text: "V = IR."}})
+	request.MaxTokens = 200
+
+	tests := []struct {
+		name      string
+		retries   int
+		responses []func(w http.ResponseWriter)
+		want      string
+		wantErr   string
+	}{
+		{
+			name: "answer",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"V = IR. [1]"}}]}`))
+				},
+			},
+			want: "V = IR. [1]",
+		},
+		{
+			name:    "retries a failing server",
+			retries: 1,
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusServiceUnavailable)
+				},
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`{"choices":[{"message":{"content":"NOT_FOUND"}}]}`))
+				},
+			},
+			want: NotFound,
+		},
+		{
+			name:    "client error",
+			retries: 2,
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					http.Error(w, `{"error":{"message":"context length exceeded"}}`, http.StatusBadRequest)
+				},
+			},
+			wantErr: "LLM server returned 400: context length exceeded",
+		},
+		{
+			name:    "retries disabled",
+			retries: -1,
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					http.Error(w, "rate limited", http.StatusTooManyRequests)
+				},
+			},
+			wantErr: "LLM server returned 429: rate limited",
+		},
+		{
+			name: "no choices",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`{"choices":[]}`))
+				},
+			},
+			wantErr: "no choices",
+		},
+		{
+			name: "malformed response",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`<html>`))
+				},
+			},
+			wantErr: "decoding LLM response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1))
+				if r.URL.Path != "/v1/chat/completions" {
+					t.Errorf("path = %q, want /v1/chat/completions", r.URL.Path)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+					t.Errorf("Authorization = %q", got)
+				}
+				var body struct {
+					Model       string    `json:"model"`
+					Messages    []Message `json:"messages"`
+					MaxTokens   int       `json:"max_tokens"`
+					Temperature float64   `json:"temperature"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("decoding request: %v", err)
+				}
+				if body.Model != "m" || body.MaxTokens != 200 || len(body.Messages) != 2 || body.Messages[1].Content != request.Messages[1].Content {
+					t.Errorf("request = %+v", body)
+				}
+				if n > len(tt.responses) {
+					t.Errorf("unexpected request %d", n)
+					http.Error(w, "unexpected", http.StatusInternalServerError)
+					return
+				}
+				tt.responses[n-1](w)
+			}))
+			defer server.Close()
+
+			p, err := NewHTTPProvider(HTTPConfig{URL: server.URL + "/v1/", APIKey: "secret", Model: "m", MaxRetries: tt.retries})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Complete(context.Background(), request)
+			if n := int(calls.Load()); n != len(tt.responses) {
+				t.Errorf("server got %d requests, want %d", n, len(tt.responses))
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
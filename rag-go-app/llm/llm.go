@@ -0,0 +1,119 @@
+// Package llm generates text with a language model. Providers sit behind
+// the Provider interface so that answers can come from a hosted model, a
+// local server or, in tests, a deterministic stub.
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Message roles.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation with a model.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request is what a provider is asked to complete. MaxTokens limits the
+// reply, or is left to the provider when zero.
+type Request struct {
+	Messages    []Message
+	MaxTokens   int
+	Temperature float64
+}
+
+// Provider completes requests with a language model. Name identifies the
+// model in answers. Implementations must be safe for concurrent use.
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, req Request) (string, error)
+}
+
+// Source is a numbered passage a grounded answer may cite. Label tells the
+// model where it comes from, such as a title and page.
+type Source struct {
+	Number int
+	Label  string
+	Text   string
+}
+
+// NotFound is the whole reply of a model told to answer from sources that
+// do not hold the answer.
+const NotFound = "NOT_FOUND"
+
+// groundedInstructions tells the model to answer from the sources alone and
+// to cite them after every sentence.
+const groundedInstructions = `You answer questions about the user's notes using only the numbered sources given.
+Write the answer as plain sentences. End every sentence with the numbers of the sources that support it in square brackets, such as [1] or [1][3].
+Do not state anything the sources do not support.
+If the sources do not answer the question, reply with exactly ` + NotFound + ` and nothing else.`
+
+// GroundedRequest builds a request asking for an answer to question drawn
+// only from sources, with every sentence citing the source numbers that
+// support it.
+func GroundedRequest(question string, sources []Source) Request {
+	var b strings.Builder
+	b.WriteString("Sources:\n")
+	for _, s := range sources {
+		fmt.Fprintf(&b, "\n[%d] %s\n%s\n", s.Number, s.Label, strings.TrimSpace(s.Text))
+	}
+	fmt.Fprintf(&b, "\nQuestion: %s", strings.TrimSpace(question))
+
+	return Request{
+		Messages: []Message{
+			{Role: RoleSystem, Content: groundedInstructions},
+			{Role: RoleUser, Content: b.String()},
+		},
+		Temperature: 0,
+	}
+}
+
+// sourceHeader matches the line that opens a source in a grounded prompt.
+var sourceHeader = regexp.MustCompile(`^\[(\d+)\] (.*)$`)
+
+// parseGroundedPrompt recovers the question and sources from the user
+// message of a request built by GroundedRequest. A source's text may hold
+// lines that look like source headers, so a header is taken only where the
+// next number in sequence is expected after a blank line.
+func parseGroundedPrompt(prompt string) (string, []Source, bool) {
+	body, ok := strings.CutPrefix(prompt, "Sources:\n")
+	if !ok {
+		return "", nil, false
+	}
+	cut := strings.LastIndex(body, "\nQuestion: ")
+	if cut < 0 {
+		return "", nil, false
+	}
+	question := strings.TrimSpace(body[cut+len("\nQuestion: "):])
+
+	var sources []Source
+	var text []string
+	lines := strings.Split(body[:cut], "\n")
+	for i, line := range lines {
+		if m := sourceHeader.FindStringSubmatch(line); m != nil && (i == 0 || lines[i-1] == "") {
+			if n, err := strconv.Atoi(m[1]); err == nil && n == len(sources)+1 {
+				if len(sources) > 0 {
+					sources[len(sources)-1].Text = strings.TrimSpace(strings.Join(text, "\n"))
+				}
+				sources = append(sources, Source{Number: n, Label: m[2]})
+				text = nil
+				continue
+			}
+		}
+		text = append(text, line)
+	}
+	if len(sources) > 0 {
+		sources[len(sources)-1].Text = strings.TrimSpace(strings.Join(text, "\n"))
+	}
+	return question, sources, true
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"rag-go-app/chunking"
+	"rag-go-app/lexical"
+)
+
+// DefaultStubSentences is the number of sentences Stub answers with when
+// MaxSentences is zero.
+const DefaultStubSentences = 2
+
+// Stub answers grounded requests without a model, by quoting the source
+// sentences sharing the most terms with the question and citing their
+// sources. It reads the question and sources from the prompt, as a model
+// would. The same request always gets the same reply, which makes it
+// suitable for tests and for running without a model server. Requests not
+// built by GroundedRequest are answered with NotFound.
+type Stub struct {
+	MaxSentences int
+}
+
+// Name implements Provider.
+func (Stub) Name() string {
+	return "stub"
+}
+
+// Complete implements Provider.
+func (s Stub) Complete(ctx context.Context, req Request) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	var prompt string
+	for _, m := range req.Messages {
+		if m.Role == RoleUser {
+			prompt = m.Content
+		}
+	}
+	questionText, sources, ok := parseGroundedPrompt(prompt)
+	if !ok {
+		return NotFound, nil
+	}
+	maxSentences := s.MaxSentences
+	if maxSentences <= 0 {
+		maxSentences = DefaultStubSentences
+	}
+
+	analyzer := lexical.Multilingual{}
+	question := make(map[string]bool)
+	for _, t := range analyzer.Analyze(questionText) {
+		question[t.Term] = true
+	}
+
+	type candidate struct {
+		text   string
+		source int
+		score  int
+	}
+	var candidates []candidate
+	for _, src := range sources {
+		for _, span := range chunking.SentenceSpans(src.Text) {
+			text := strings.Join(strings.Fields(src.Text[span.Start:span.End]), " ")
+			seen := make(map[string]bool)
+			for _, t := range analyzer.Analyze(text) {
+				if question[t.Term] {
+					seen[t.Term] = true
+				}
+			}
+			if len(seen) > 0 {
+				candidates = append(candidates, candidate{text: text, source: src.Number, score: len(seen)})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return NotFound, nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var reply []string
+	used := make(map[string]bool)
+	for _, c := range candidates {
+		if len(reply) == maxSentences {
+			break
+		}
+		if used[c.text] {
+			continue
+		}
+		used[c.text] = true
+		reply = append(reply, fmt.Sprintf("%s [%d]", c.text, c.source))
+	}
+	return strings.Join(reply, " "), nil
+}
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseGroundedPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		question string
+		sources  []Source
+	}{
+		{
+			name:     "one source",
+			question: "What is Ohm's law?",
+			sources:  []Source{{Number: 1, Label: "Physics notes, page 3", Text: "Ohm's law states V = IR."}},
+		},
+		{
+			name:     "several sources",
+			question: "When was the battery invented?",
+			sources: []Source{
+				{Number: 1, Label: "History", Text: "Volta built the first battery in 1800.\nIt was a pile of discs."},
+				{Number: 2, Label: "Chemistry, pages 4-5", Text: "A cell converts chemical energy."},
+			},
+		},
+		{
+			name:     "text that looks like a source",
+			question: "Which references are cited?",
+			sources: []Source{
+				{Number: 1, Label: "Bibliography", Text: "References:\n\n[1] Volta, A. On electricity.\n\n[7] Ohm, G. S.\nQuestion: none"},
+				{Number: 2, Label: "Notes", Text: "See [1]."},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := GroundedRequest(tt.question, tt.sources)
+			question, sources, ok := parseGroundedPrompt(req.Messages[1].Content)
+			if !ok {
+				t.Fatal("prompt not recognized")
+			}
+			if question != tt.question {
+				t.Errorf("question = %q, want %q", question, tt.question)
+			}
+			if !reflect.DeepEqual(sources, tt.sources) {
+				t.Errorf("sources = %+v, want %+v", sources, tt.sources)
+			}
+		})
+	}
+
+	if _, _, ok := parseGroundedPrompt("Tell me a joke"); ok {
+		t.Error("a prompt without sources was recognized")
+	}
+}
+
+func TestStub(t *testing.T) {
+	sources := []Source{
+		{Number: 1, Label: "Physics", Text: "Ohm's law relates voltage and current. Resistance is measured in ohms."},
+		{Number: 2, Label: "History", Text: "Georg Ohm published the law in 1827."},
+	}
+	tests := []struct {
+		name    string
+		stub    Stub
+		request Request
+		want    string
+	}{
+		{
+			name:    "quotes and cites the closest sentences",
+			request: GroundedRequest("When was Ohm's law published?", sources),
+			want:    "Ohm's law relates voltage and current. [1] Georg Ohm published the law in 1827. [2]",
+		},
+		{
+			name:    "one sentence",
+			stub:    Stub{MaxSentences: 1},
+			request: GroundedRequest("What is resistance measured in?", sources),
+			want:    "Resistance is measured in ohms. [1]",
+		},
+		{
+			name:    "nothing relevant",
+			request: GroundedRequest("Who painted the Mona Lisa?", sources),
+			want:    NotFound,
+		},
+		{
+			name:    "not grounded",
+			request: Request{Messages: []Message{{Role: RoleUser, Content: "Hello"}}},
+			want:    NotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.stub.Complete(context.Background(), tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
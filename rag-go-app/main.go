@@ -4,9 +4,15 @@ import (
 	"log"
 	"net/http"
 	"rag-go-app/api"
+	"rag-go-app/parser"
 )
 
 func main() {
+	// Plugins that fail to load are skipped; the built-in parsers still work
+	if err := parser.LoadConfiguredPlugins(parser.DefaultRegistry()); err != nil {
+		log.Printf("Some parser plugins were not loaded: %v", err)
+	}
+
 	// Initialize and run the server
 	log.Println("Starting server on port 8080...")
 	err := http.ListenAndServe(":8080", api.SetupRoutes())
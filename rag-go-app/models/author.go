@@ -0,0 +1,336 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Author is a person credited on a document or citation. Family is the
+// name used for sorting and citing; people known by a single name have it
+// in Family. Initials are those of the given names, such as "R.K.", and are
+// set even when only initials are known.
+type Author struct {
+	Given       string `json:"given,omitempty"`
+	Family      string `json:"family,omitempty"`
+	Initials    string `json:"initials,omitempty"`
+	ORCID       string `json:"orcid,omitempty"`
+	Affiliation string `json:"affiliation,omitempty"`
+}
+
+var (
+	orcidPattern       = regexp.MustCompile(`(?i)(?:orcid:?\s*|https?://orcid\.org/)?(\d{4}-\d{4}-\d{4}-\d{3}[\dX])`)
+	etAlPattern        = regexp.MustCompile(`(?i)[,;]?\s*(?:\bet\.?\s*al\b\.?|\band\s+others\b\.?)\s*$`)
+	affiliationPattern = regexp.MustCompile(`\s*\(([^)]*)\)\s*`)
+	listSeparator      = regexp.MustCompile(`(?i)\s*(?:;|&|,?\s+and\s+)\s*`)
+	initialsPattern    = regexp.MustCompile(`^(?:\p{Lu}\.?-?){1,3}$`)
+)
+
+// honorifics are dropped from names, compared without the trailing dot.
+var honorifics = map[string]bool{
+	"dr": true, "prof": true, "professor": true, "mr": true, "mrs": true, "ms": true,
+	"shri": true, "smt": true, "kumari": true,
+}
+
+// suffixes are generational suffixes kept out of the family name.
+var suffixes = map[string]bool{"jr": true, "sr": true, "ii": true, "iii": true}
+
+// particles are lower-case prefixes that belong to the family name, as in
+// "Ludwig van Beethoven" or "Manuel da Silva".
+var particles = map[string]bool{
+	"van": true, "von": true, "de": true, "der": true, "den": true, "da": true,
+	"das": true, "dos": true, "di": true, "del": true, "della": true, "le": true, "la": true,
+}
+
+// UnmarshalJSON accepts an author object or, as stored before authors were
+// structured, a plain name string, which is parsed with ParseAuthorName.
+func (a *Author) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*a = ParseAuthorName(name)
+		return nil
+	}
+	type author Author
+	return json.Unmarshal(data, (*author)(a))
+}
+
+// String formats the author as "Family, Initials", or "Family, Given" when
+// no initials are known.
+func (a Author) String() string {
+	switch {
+	case a.Family == "":
+		return strings.TrimSpace(a.Given)
+	case a.Initials != "":
+		return a.Family + ", " + a.Initials
+	case a.Given != "":
+		return a.Family + ", " + a.Given
+	}
+	return a.Family
+}
+
+// IsEmpty reports whether the author has no name.
+func (a Author) IsEmpty() bool {
+	return strings.TrimSpace(a.Given) == "" && strings.TrimSpace(a.Family) == ""
+}
+
+// ParseAuthorName parses one person's name. It handles "Last, First",
+// "First Last", initials before the family name ("R. K. Sharma", common in
+// Indian usage) and after it without a comma ("Sharma R K", common in
+// Indian bibliographies), single names, honorifics, a parenthesised
+// affiliation and an embedded ORCID.
+func ParseAuthorName(name string) Author {
+	var a Author
+	if m := orcidPattern.FindStringSubmatch(name); m != nil {
+		a.ORCID = strings.ToUpper(m[1])
+		name = strings.Replace(name, m[0], " ", 1)
+	}
+	if m := affiliationPattern.FindStringSubmatch(name); m != nil {
+		a.Affiliation = strings.TrimSpace(m[1])
+		name = strings.Replace(name, m[0], " ", 1)
+	}
+	name = etAlPattern.ReplaceAllString(name, "")
+
+	if family, given, ok := strings.Cut(name, ","); ok {
+		a.Family = strings.Join(cleanNameTokens(strings.Fields(family)), " ")
+		setGiven(&a, cleanNameTokens(strings.Fields(given)))
+		return a
+	}
+
+	tokens := cleanNameTokens(strings.Fields(name))
+	switch {
+	case len(tokens) == 0:
+		return a
+	case len(tokens) == 1:
+		a.Family = tokens[0]
+		return a
+	}
+
+	// "Sharma R K": initials after the family name
+	if !isInitials(tokens[0]) {
+		split := len(tokens)
+		for split > 1 && isInitials(tokens[split-1]) {
+			split--
+		}
+		if split < len(tokens) {
+			a.Family = strings.Join(tokens[:split], " ")
+			setGiven(&a, tokens[split:])
+			return a
+		}
+	}
+
+	// "First [Middle] [particle] Last". A name that starts with a particle
+	// is all family name only when the particle is written in lower case,
+	// as in "de la Cruz"; "Van Morrison" keeps Van as a given name.
+	split := len(tokens) - 1
+	for split > 1 && particles[strings.ToLower(tokens[split-1])] {
+		split--
+	}
+	if split == 1 && particles[tokens[0]] {
+		split = 0
+	}
+	a.Family = strings.Join(tokens[split:], " ")
+	setGiven(&a, tokens[:split])
+	return a
+}
+
+// ParseAuthors parses a list of names such as "R. Sharma, A. Gupta and
+// S. Iyer" or "Sharma, R.; Gupta, A.", and reports whether it ended with
+// "et al." or "and others".
+func ParseAuthors(list string) ([]Author, bool) {
+	etAl := etAlPattern.MatchString(list)
+	list = etAlPattern.ReplaceAllString(list, "")
+
+	var authors []Author
+	for _, name := range splitAuthorList(list) {
+		if a := ParseAuthorName(name); !a.IsEmpty() {
+			authors = append(authors, a)
+		}
+	}
+	return authors, etAl
+}
+
+// splitAuthorList splits a list on semicolons, "and" and ampersands, then
+// on commas. Commas also separate family and given names, so a piece that
+// is only a given name or initials is joined back onto the family name
+// before it, as in "Sharma, R., Gupta, A." or "de la Cruz, Juan".
+func splitAuthorList(list string) []string {
+	var names []string
+	for _, part := range listSeparator.Split(list, -1) {
+		pieces := strings.Split(part, ",")
+		for i := 0; i < len(pieces); i++ {
+			piece := strings.TrimSpace(pieces[i])
+			if piece == "" {
+				continue
+			}
+			if i+1 < len(pieces) && isFamilyPart(piece) && isGivenPart(pieces[i+1], len(pieces)) {
+				piece += ", " + strings.TrimSpace(pieces[i+1])
+				i++
+			}
+			names = append(names, piece)
+		}
+	}
+	return names
+}
+
+// isFamilyPart reports whether a comma-separated piece can be the family
+// half of "Family, Given": a single word, or one following particles, as
+// "da Silva" or "van der Berg".
+func isFamilyPart(piece string) bool {
+	tokens := strings.Fields(piece)
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, t := range tokens[:len(tokens)-1] {
+		if !particles[strings.ToLower(t)] {
+			return false
+		}
+	}
+	return true
+}
+
+// isGivenPart reports whether a comma-separated piece is the given-name half
+// of "Family, Given". Initials always are; a single full word only when the
+// list has an even number of pieces, as "Sharma, Rahul" or "Sharma, Rahul,
+// Gupta, Amit".
+func isGivenPart(piece string, pieces int) bool {
+	tokens := strings.Fields(piece)
+	if len(tokens) == 0 {
+		return false
+	}
+	allInitials := true
+	for _, t := range tokens {
+		if !isInitials(t) {
+			allInitials = false
+			break
+		}
+	}
+	return allInitials || (len(tokens) == 1 && pieces%2 == 0)
+}
+
+// setGiven fills in the given names and initials from the given-name tokens.
+// Tokens that are only initials contribute to Initials but not Given.
+func setGiven(a *Author, tokens []string) {
+	var given []string
+	var initials strings.Builder
+	for _, t := range tokens {
+		if isInitials(t) {
+			for _, r := range t {
+				if unicode.IsLetter(r) {
+					initials.WriteRune(unicode.ToUpper(r))
+					initials.WriteByte('.')
+				}
+			}
+			continue
+		}
+		given = append(given, t)
+		for _, part := range strings.Split(t, "-") {
+			if r := []rune(part); len(r) > 0 {
+				initials.WriteRune(unicode.ToUpper(r[0]))
+				initials.WriteByte('.')
+			}
+		}
+	}
+	a.Given = strings.Join(given, " ")
+	a.Initials = initials.String()
+}
+
+// cleanNameTokens drops honorifics, suffixes and stray punctuation.
+func cleanNameTokens(tokens []string) []string {
+	var cleaned []string
+	for _, t := range tokens {
+		t = strings.Trim(t, ",;")
+		key := strings.ToLower(strings.TrimSuffix(t, "."))
+		if t == "" || honorifics[key] || suffixes[key] {
+			continue
+		}
+		cleaned = append(cleaned, t)
+	}
+	return cleaned
+}
+
+// isInitials reports whether a token is one to three initials, such as "R",
+// "R.", "R.K." or "RK". Undotted capitals with a vowel after the first
+// letter, such as "RAO" or "JHA", are a surname written in capitals.
+func isInitials(token string) bool {
+	if !initialsPattern.MatchString(token) {
+		return false
+	}
+	if strings.Contains(token, ".") {
+		return true
+	}
+	_, size := utf8.DecodeRuneInString(token)
+	return !strings.ContainsAny(token[size:], "AEIOU")
+}
+
+// Matches reports whether a and b plausibly name the same person. Authors
+// with ORCIDs match only on them. Otherwise family names must be equal,
+// ignoring case, and the initials of one must extend the other's, so
+// "Sharma, R." matches "Rahul Kumar Sharma" but not "S. Sharma". Full given
+// names, when both are known, must agree on the first.
+func (a Author) Matches(b Author) bool {
+	if a.ORCID != "" && b.ORCID != "" {
+		return strings.EqualFold(a.ORCID, b.ORCID)
+	}
+	if a.Family == "" || !strings.EqualFold(normalizeName(a.Family), normalizeName(b.Family)) {
+		return false
+	}
+
+	ai, bi := compactInitials(a.Initials), compactInitials(b.Initials)
+	if !strings.HasPrefix(ai, bi) && !strings.HasPrefix(bi, ai) {
+		return false
+	}
+	if a.Given != "" && b.Given != "" {
+		ag, bg := strings.Fields(a.Given), strings.Fields(b.Given)
+		return strings.EqualFold(normalizeName(ag[0]), normalizeName(bg[0]))
+	}
+	return true
+}
+
+// Key groups authors that are likely the same person: the lower-cased family
+// name and first initial.
+func (a Author) Key() string {
+	key := strings.ToLower(normalizeName(a.Family))
+	if initials := compactInitials(a.Initials); initials != "" {
+		key += "|" + strings.ToLower(string([]rune(initials)[:1]))
+	}
+	return key
+}
+
+func compactInitials(initials string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, initials)
+}
+
+// normalizeName drops dots and hyphens and collapses spaces, so "Ramaswamy"
+// and "Rama-swamy" compare equal.
+func normalizeName(name string) string {
+	name = strings.NewReplacer(".", "", "-", "").Replace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// validORCID checks the ISO 7064 MOD 11-2 check digit of an ORCID iD.
+func validORCID(orcid string) bool {
+	digits := strings.ReplaceAll(orcid, "-", "")
+	if len(digits) != 16 {
+		return false
+	}
+	total := 0
+	for _, r := range digits[:15] {
+		if r < '0' || r > '9' {
+			return false
+		}
+		total = (total + int(r-'0')) * 2
+	}
+	check := (12 - total%11) % 11
+	want := byte('0' + check)
+	if check == 10 {
+		want = 'X'
+	}
+	return digits[15] == want
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseAuthorName(t *testing.T) {
+	tests := []struct {
+		name string
+		want Author
+	}{
+		{"Maria D'Souza", Author{Given: "Maria", Family: "D'Souza", Initials: "M."}},
+		{"D'Souza, Maria", Author{Given: "Maria", Family: "D'Souza", Initials: "M."}},
+		{"Rahul Kumar Sharma", Author{Given: "Rahul Kumar", Family: "Sharma", Initials: "R.K."}},
+		{"R. K. Sharma", Author{Family: "Sharma", Initials: "R.K."}},
+		{"Sharma R K", Author{Family: "Sharma", Initials: "R.K."}},
+		{"Sharma RK", Author{Family: "Sharma", Initials: "R.K."}},
+		{"Sharma, R.K.", Author{Family: "Sharma", Initials: "R.K."}},
+		{"RAO R K", Author{Family: "RAO", Initials: "R.K."}},
+		{"R K JHA", Author{Family: "JHA", Initials: "R.K."}},
+		{"JHA, Amit", Author{Given: "Amit", Family: "JHA", Initials: "A."}},
+		{"Jean-Paul Sartre", Author{Given: "Jean-Paul", Family: "Sartre", Initials: "J.P."}},
+		{"Ludwig van Beethoven", Author{Given: "Ludwig", Family: "van Beethoven", Initials: "L."}},
+		{"Juan de la Cruz", Author{Given: "Juan", Family: "de la Cruz", Initials: "J."}},
+		{"de la Cruz, Juan", Author{Given: "Juan", Family: "de la Cruz", Initials: "J."}},
+		{"Van Morrison", Author{Given: "Van", Family: "Morrison", Initials: "V."}},
+		{"Kalidasa", Author{Family: "Kalidasa"}},
+		{"Dr. Priya Iyer", Author{Given: "Priya", Family: "Iyer", Initials: "P."}},
+		{"Martin Luther King Jr.", Author{Given: "Martin Luther", Family: "King", Initials: "M.L."}},
+		{"Priya Iyer (IIT Madras)", Author{Given: "Priya", Family: "Iyer", Initials: "P.", Affiliation: "IIT Madras"}},
+		{"Priya Iyer https://orcid.org/0000-0002-1825-0097", Author{Given: "Priya", Family: "Iyer", Initials: "P.", ORCID: "0000-0002-1825-0097"}},
+		{"Iyer, P. ORCID: 0000-0001-5109-373x", Author{Family: "Iyer", Initials: "P.", ORCID: "0000-0001-5109-373X"}},
+		{"Sharma, R. et al.", Author{Family: "Sharma", Initials: "R."}},
+		{"", Author{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAuthorName(tt.name); got != tt.want {
+				t.Errorf("ParseAuthorName(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuthors(t *testing.T) {
+	tests := []struct {
+		list     string
+		want     []string
+		wantEtAl bool
+	}{
+		{"R. Sharma, A. Gupta and S. Iyer", []string{"Sharma, R.", "Gupta, A.", "Iyer, S."}, false},
+		{"Sharma, R.; Gupta, A.", []string{"Sharma, R.", "Gupta, A."}, false},
+		{"Sharma, R., Gupta, A.", []string{"Sharma, R.", "Gupta, A."}, false},
+		{"Sharma, Rahul, Gupta, Amit", []string{"Sharma, R.", "Gupta, A."}, false},
+		{"Maria D'Souza & RAO R K", []string{"D'Souza, M.", "RAO, R.K."}, false},
+		{"de la Cruz, Juan and van der Berg, Anna", []string{"de la Cruz, J.", "van der Berg, A."}, false},
+		{"Sharma R K, Gupta A et al.", []string{"Sharma, R.K.", "Gupta, A."}, true},
+		{"R. Sharma and others", []string{"Sharma, R."}, true},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			authors, etAl := ParseAuthors(tt.list)
+			var got []string
+			for _, a := range authors {
+				got = append(got, a.String())
+			}
+			if !slices.Equal(got, tt.want) || etAl != tt.wantEtAl {
+				t.Errorf("ParseAuthors(%q) = %q, %v, want %q, %v", tt.list, got, etAl, tt.want, tt.wantEtAl)
+			}
+		})
+	}
+}
+
+func TestAuthorMatches(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Sharma, R.", "Rahul Kumar Sharma", true},
+		{"Sharma R K", "R. K. Sharma", true},
+		{"Sharma, R.", "S. Sharma", false},
+		{"Rahul Sharma", "Rohit Sharma", false},
+		{"Maria D'Souza", "D'Souza, M.", true},
+		{"RAO R K", "Rao, Ravi Kumar", true},
+		{"Ramaswamy, K.", "K. Rama-swamy", true},
+		{"Priya Iyer 0000-0002-1825-0097", "P. Iyer 0000-0001-5109-3700", false},
+		{"Priya Iyer 0000-0002-1825-0097", "Iyer, Priya (orcid 0000-0002-1825-0097)", true},
+		{"Sharma, R.", "Gupta, R.", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			a, b := ParseAuthorName(tt.a), ParseAuthorName(tt.b)
+			if got := a.Matches(b); got != tt.want {
+				t.Errorf("%+v.Matches(%+v) = %v, want %v", a, b, got, tt.want)
+			}
+			if got := b.Matches(a); got != tt.want {
+				t.Errorf("Matches is not symmetric for %q and %q", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestValidateAuthorKeepsPlainText(t *testing.T) {
+	tests := []struct {
+		name    string
+		author  Author
+		want    Author
+		wantErr bool
+	}{
+		{
+			name:   "apostrophe",
+			author: Author{Given: "Maria", Family: "D'Souza", Affiliation: "St. Xavier's College"},
+			want:   Author{Given: "Maria", Family: "D'Souza", Affiliation: "St. Xavier's College"},
+		},
+		{
+			name:   "ampersand",
+			author: Author{Family: "Iyer", Affiliation: "Tata Institute of Fundamental Research & IISc"},
+			want:   Author{Family: "Iyer", Affiliation: "Tata Institute of Fundamental Research & IISc"},
+		},
+		{
+			name:   "markup is stripped",
+			author: Author{Given: "<b>Priya</b>", Family: "Iyer<script>alert(1)</script>"},
+			want:   Author{Given: "Priya", Family: "Iyer"},
+		},
+		{
+			name:    "nothing left",
+			author:  Author{Family: "<img src=x>"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &ValidationError{}
+			validateAuthor("authors[0]", &tt.author, v)
+			if gotErr := len(v.Errors) > 0; gotErr != tt.wantErr {
+				t.Fatalf("errors = %v, want error %v", v.Errors, tt.wantErr)
+			}
+			if !tt.wantErr && tt.author != tt.want {
+				t.Errorf("author = %+v, want %+v", tt.author, tt.want)
+			}
+		})
+	}
+}
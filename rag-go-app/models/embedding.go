@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// EmbeddingModel identifies the model that produced a vector. Vectors are
+// only comparable when their models are equal, so every stored vector
+// carries one. Normalized is true when vectors have unit length.
+type EmbeddingModel struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Dimension  int    `json:"dimension"`
+	Normalized bool   `json:"normalized"`
+}
+
+// String returns the model as name@version.
+func (m EmbeddingModel) String() string {
+	if m.Version == "" {
+		return m.Name
+	}
+	return m.Name + "@" + m.Version
+}
+
+// Embedding is the vector of one chunk under one model. A chunk has one
+// embedding per model, so an index can be rebuilt with a new model while
+// the old one keeps serving.
+type Embedding struct {
+	ChunkID    int64          `json:"chunk_id"`
+	DocumentID int64          `json:"document_id"`
+	Model      EmbeddingModel `json:"model"`
+	Vector     []float32      `json:"vector"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
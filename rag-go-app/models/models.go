@@ -1,8 +1,13 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"html"
+	"math"
 	"regexp"
+	"strings"
 	"time"
 	"unicode"
 
@@ -10,8 +15,11 @@ import (
 )
 
 type File struct {
-	ID          int64     `json:"id"`
-	Filename    string    `json:"filename"`
+	ID       int64  `json:"id"`
+	Filename string `json:"filename"`
+	// Path is where the uploaded content is stored on the server. It is
+	// never sent to clients.
+	Path        string    `json:"-"`
 	ContentType string    `json:"content_type"`
 	Processed   bool      `json:"processed"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -19,59 +27,346 @@ type File struct {
 }
 
 type Document struct {
-	ID         int64     `json:"id"`
-	FileID     int64     `json:"file_id"`
-	Text       string    `json:"text"`
-	Metadata   Metadata  `json:"metadata"`
-	Embeddings []float32 `json:"embeddings"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID          int64               `json:"id"`
+	FileID      int64               `json:"file_id"`
+	Text        string              `json:"text"`
+	Metadata    Metadata            `json:"metadata"`
+	Pages       []Page              `json:"pages,omitempty"`
+	Sections    []Section           `json:"sections,omitempty"`
+	Segments    []TranscriptSegment `json:"segments,omitempty"`
+	Annotations []Annotation        `json:"annotations,omitempty"`
+	Hyperlinks  []Hyperlink         `json:"hyperlinks,omitempty"`
+	Extraction  []PageExtraction    `json:"extraction,omitempty"`
+	Warnings    []string            `json:"warnings,omitempty"`
+	State       string              `json:"state"`
+	Missing     []string            `json:"missing,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
 }
 
 type Metadata struct {
 	Title     string     `json:"title"`
-	Authors   []string   `json:"authors"`
+	Authors   []Author   `json:"authors"`
 	Keywords  []string   `json:"keywords"`
 	Abstract  string     `json:"abstract"`
 	Citations []Citation `json:"citations"`
+	Tables    []Table    `json:"tables,omitempty"`
+	Figures   []Figure   `json:"figures,omitempty"`
+	// Language is the ISO 639-1 code of the document's primary language,
+	// or "und" if it could not be determined.
+	Language string `json:"language,omitempty"`
+	// Year is the year the document was published, or 0 if unknown.
+	Year int `json:"year,omitempty"`
+	// FileType is the extension of the file the document was parsed from,
+	// such as "pdf". It is set on creation.
+	FileType string `json:"file_type,omitempty"`
+	// Collection groups documents, such as the notes for one course.
+	Collection string `json:"collection,omitempty"`
 }
 
+// Table is a table found in a document, as rows of cell text.
+type Table struct {
+	Page int        `json:"page,omitempty"`
+	Data [][]string `json:"data"`
+}
+
+// Citation is a reference made by a document. EtAl records that the
+// reference lists only its first authors.
 type Citation struct {
-	Text   string `json:"text"`
-	Author string `json:"author"`
-	Year   int    `json:"year"`
-	Title  string `json:"title"`
+	Text    string   `json:"text"`
+	Authors []Author `json:"authors"`
+	EtAl    bool     `json:"et_al,omitempty"`
+	Year    int      `json:"year"`
+	Title   string   `json:"title"`
+}
+
+// UnmarshalJSON also accepts citations stored before authors were
+// structured, whose single "author" string is parsed with ParseAuthors.
+func (c *Citation) UnmarshalJSON(data []byte) error {
+	type citation Citation
+	var aux struct {
+		citation
+		Author string `json:"author"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*c = Citation(aux.citation)
+	if len(c.Authors) == 0 && aux.Author != "" {
+		c.Authors, c.EtAl = ParseAuthors(aux.Author)
+	}
+	return nil
+}
+
+// Section is a node in a document's table of contents. Page is 1-based and
+// Top is the vertical position of the heading on that page, in PDF units
+// from the bottom edge.
+type Section struct {
+	Title    string    `json:"title"`
+	Level    int       `json:"level"`
+	Page     int       `json:"page"`
+	Top      float64   `json:"top"`
+	Language string    `json:"language,omitempty"`
+	Children []Section `json:"children,omitempty"`
+}
+
+// BoundingBox is a rectangle on a page in PDF units, with the origin at the
+// bottom-left corner.
+type BoundingBox struct {
+	X0 float64 `json:"x0"`
+	Y0 float64 `json:"y0"`
+	X1 float64 `json:"x1"`
+	Y1 float64 `json:"y1"`
+}
+
+// Intersects reports whether b and other overlap.
+func (b BoundingBox) Intersects(other BoundingBox) bool {
+	return b.X0 < other.X1 && other.X0 < b.X1 && b.Y0 < other.Y1 && other.Y0 < b.Y1
+}
+
+// Annotation types recognised when importing a student's existing marks.
+const (
+	AnnotationHighlight = "highlight"
+	AnnotationUnderline = "underline"
+	AnnotationStrikeOut = "strikeout"
+	AnnotationSquiggly  = "squiggly"
+	AnnotationNote      = "note"
+	AnnotationFreeText  = "free_text"
+)
+
+// Annotation is a user's mark on a page. Text is the page text the mark
+// covers and Contents is any comment attached to it.
+type Annotation struct {
+	Type     string        `json:"type"`
+	Page     int           `json:"page"`
+	Text     string        `json:"text,omitempty"`
+	Contents string        `json:"contents,omitempty"`
+	Author   string        `json:"author,omitempty"`
+	Color    []float64     `json:"color,omitempty"`
+	Regions  []BoundingBox `json:"regions"`
+}
+
+// Hyperlink is a link annotation on a page pointing at an external URI.
+type Hyperlink struct {
+	Page int         `json:"page"`
+	URI  string      `json:"uri"`
+	Text string      `json:"text,omitempty"`
+	Rect BoundingBox `json:"rect"`
+}
+
+// Text extractors recorded in PageExtraction.Extractor.
+const (
+	ExtractorUnidoc   = "unidoc"
+	ExtractorPdfminer = "pdfminer"
+	ExtractorOCR      = "ocr"
+)
+
+// Thresholds above or below which a page is flagged for reprocessing.
+const (
+	MaxGarbledScore  = 0.3
+	MinOCRConfidence = 60.0
+	MinCharsPerPage  = 20
+)
+
+// PageExtraction records how the text of one page was obtained. Extractor
+// is empty when every extractor failed. ScannedScore and GarbledScore range
+// from 0 (clean born-digital text) to 1 (image-only or unreadable).
+type PageExtraction struct {
+	Page          int                `json:"page"`
+	Extractor     string             `json:"extractor"`
+	Chars         int                `json:"chars"`
+	OCRConfidence float64            `json:"ocr_confidence,omitempty"`
+	Failures      []ExtractorFailure `json:"failures,omitempty"`
+	ScannedScore  float64            `json:"scanned_score"`
+	GarbledScore  float64            `json:"garbled_score"`
+}
+
+// ExtractorFailure explains why an extractor's output was not used.
+type ExtractorFailure struct {
+	Extractor string `json:"extractor"`
+	Reason    string `json:"reason"`
+}
+
+// NeedsReview reports whether the page text is missing or likely unusable.
+func (p PageExtraction) NeedsReview() bool {
+	switch {
+	case p.Extractor == "":
+		return true
+	case p.Chars < MinCharsPerPage:
+		return true
+	case p.GarbledScore > MaxGarbledScore:
+		return true
+	case p.Extractor == ExtractorOCR && p.OCRConfidence < MinOCRConfidence:
+		return true
+	}
+	return false
+}
+
+// FlaggedPages returns the pages of d whose extraction needs review.
+func (d *Document) FlaggedPages() []int {
+	var pages []int
+	for _, p := range d.Extraction {
+		if p.NeedsReview() {
+			pages = append(pages, p.Page)
+		}
+	}
+	return pages
+}
+
+// TranscriptSegment is a paragraph-sized run of caption cues from a lecture
+// recording, keeping the time range it was spoken in. Start and End are
+// serialized as seconds.
+type TranscriptSegment struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	Text  string        `json:"text"`
+}
+
+// transcriptSegmentJSON is the serialized form of TranscriptSegment.
+type transcriptSegmentJSON struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+func (s TranscriptSegment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(transcriptSegmentJSON{Start: s.Start.Seconds(), End: s.End.Seconds(), Text: s.Text})
+}
+
+func (s *TranscriptSegment) UnmarshalJSON(data []byte) error {
+	var v transcriptSegmentJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = TranscriptSegment{Start: fromSeconds(v.Start), End: fromSeconds(v.End), Text: v.Text}
+	return nil
+}
+
+// TimeRange is the part of a recording a chunk of its transcript was spoken
+// in. Start and End are serialized as seconds.
+type TimeRange struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+}
+
+// timeRangeJSON is the serialized form of TimeRange.
+type timeRangeJSON struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+func (r TimeRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timeRangeJSON{Start: r.Start.Seconds(), End: r.End.Seconds()})
+}
+
+func (r *TimeRange) UnmarshalJSON(data []byte) error {
+	var v timeRangeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = TimeRange{Start: fromSeconds(v.Start), End: fromSeconds(v.End)}
+	return nil
 }
 
-func NewDocument(fileID int64, text string, metadata Metadata, embeddings []float32) (*Document, error) {
-	if err := validateDocumentInput(text, metadata, embeddings); err != nil {
+// fromSeconds converts seconds to a duration, rounded to the millisecond
+// that caption timestamps are given in.
+func fromSeconds(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds*1000)) * time.Millisecond
+}
+
+// Timestamp formats the segment start as "12:31", or "1:02:03" past the hour.
+func (s TranscriptSegment) Timestamp() string {
+	total := int64(s.Start / time.Second)
+	h, m, sec := total/3600, (total/60)%60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%d:%02d", m, sec)
+}
+
+// DeepLink returns videoURL with a media fragment that starts playback at
+// the beginning of the segment.
+func (s TranscriptSegment) DeepLink(videoURL string) string {
+	return fmt.Sprintf("%s#t=%d", videoURL, int64(s.Start/time.Second))
+}
+
+// Document states. Drafts may have partial metadata; finalized documents
+// have passed full validation.
+const (
+	StateDraft     = "draft"
+	StateFinalized = "finalized"
+)
+
+// NewDocument creates a draft document. Fields a finalized document needs
+// but the input lacks are listed in Missing rather than rejected, since
+// plenty of real files have no title or author. Invalid values, such as
+// malformed keywords, are still rejected with a *ValidationError.
+func NewDocument(fileID int64, text string, metadata Metadata) (*Document, error) {
+	missing, err := validateDocumentInput(StateDraft, text, &metadata)
+	if err != nil {
 		return nil, err
 	}
 
 	now := time.Now()
 	return &Document{
-		FileID:     fileID,
-		Text:       text,
-		Metadata:   metadata,
-		Embeddings: embeddings,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		FileID:    fileID,
+		Text:      text,
+		Metadata:  metadata,
+		State:     StateDraft,
+		Missing:   missing,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}, nil
 }
 
+// Update replaces the text and metadata. A finalized document must stay
+// complete; a draft's Missing list is recomputed.
 func (d *Document) Update(text string, metadata Metadata) error {
-	if err := validateDocumentInput(text, metadata, d.Embeddings); err != nil {
+	missing, err := validateDocumentInput(d.state(), text, &metadata)
+	if err != nil {
 		return err
 	}
 
 	d.Text = text
 	d.Metadata = metadata
+	d.Missing = missing
 	d.UpdatedAt = time.Now()
 	return nil
 }
 
+// Finalize validates a draft in full and marks it finalized. While any
+// required field is missing it returns a *ValidationError listing every
+// problem and leaves the document a draft.
+func (d *Document) Finalize() error {
+	metadata := d.Metadata
+	missing, err := validateDocumentInput(StateFinalized, d.Text, &metadata)
+	if err != nil {
+		d.Missing = missing
+		return err
+	}
+
+	d.Metadata = metadata
+	d.State = StateFinalized
+	d.Missing = nil
+	d.UpdatedAt = time.Now()
+	return nil
+}
+
+// state returns the document's state. Documents stored before states
+// existed are treated as drafts.
+func (d *Document) state() string {
+	if d.State == "" {
+		return StateDraft
+	}
+	return d.State
+}
+
+// AddCitation validates citation and appends it. Problems are reported as
+// a *ValidationError addressed at the index the citation would take.
 func (m *Metadata) AddCitation(citation Citation) error {
-	if err := validateCitation(citation); err != nil {
+	v := &ValidationError{}
+	validateCitation(indexPath("metadata.citations", len(m.Citations)), &citation, v)
+	if err := v.orNil(); err != nil {
 		return err
 	}
 	m.Citations = append(m.Citations, citation)
@@ -80,77 +375,147 @@ func (m *Metadata) AddCitation(citation Citation) error {
 
 func (m *Metadata) RemoveCitation(index int) error {
 	if index < 0 || index >= len(m.Citations) {
-		return errors.New("index out of range")
+		v := &ValidationError{}
+		v.add(indexPath("metadata.citations", index), CodeOutOfRange, "index out of range")
+		return v
 	}
 	m.Citations = append(m.Citations[:index], m.Citations[index+1:]...)
 	return nil
 }
 
-func validateDocumentInput(text string, metadata Metadata, embeddings []float32) error {
-	if len(text) == 0 {
-		return errors.New("text cannot be empty")
-	}
-	if err := validateMetadata(metadata); err != nil {
-		return err
-	}
-	if len(embeddings) == 0 {
-		return errors.New("embeddings cannot be empty")
+// maxKeywordLength is the longest keyword accepted, in bytes.
+const maxKeywordLength = 50
+
+// maxCollectionLength is the longest collection name accepted, in bytes.
+const maxCollectionLength = 100
+
+// validateDocumentInput checks the input for a document in the given state
+// and sanitizes metadata in place. It returns the required fields that are
+// missing, and a *ValidationError listing every problem found. Missing
+// fields are only problems for finalized documents.
+func validateDocumentInput(state string, text string, metadata *Metadata) ([]string, error) {
+	v := &ValidationError{}
+	var missing []string
+	require := func(field string, present bool) {
+		if present {
+			return
+		}
+		missing = append(missing, field)
+		if state == StateFinalized {
+			v.add(field, CodeRequired, "cannot be empty")
+		}
 	}
-	return nil
+
+	require("text", len(text) > 0)
+	require("metadata.title", len(metadata.Title) > 0)
+	require("metadata.authors", len(metadata.Authors) > 0)
+	validateMetadata("metadata", metadata, v)
+
+	return missing, v.orNil()
 }
 
-func validateMetadata(metadata Metadata) error {
-	if len(metadata.Title) == 0 {
-		return errors.New("title cannot be empty")
-	}
-	if len(metadata.Authors) == 0 {
-		return errors.New("authors cannot be empty")
-	}
+// validateMetadata sanitizes metadata in place and records its problems in
+// v. Required fields are checked by validateDocumentInput, which knows the
+// document's state.
+func validateMetadata(field string, metadata *Metadata, v *ValidationError) {
 	for i := range metadata.Authors {
-		if err := sanitizeInput(&metadata.Authors[i]); err != nil {
-			return err
-		}
+		validateAuthor(indexPath(fieldPath(field, "authors"), i), &metadata.Authors[i], v)
 	}
-	if err := sanitizeInput(&metadata.Abstract); err != nil {
-		return err
+	if metadata.Abstract != "" {
+		sanitizeField(fieldPath(field, "abstract"), &metadata.Abstract, v)
 	}
-	for i, keyword := range metadata.Keywords {
-		if err := sanitizeInput(&metadata.Keywords[i]); err != nil {
-			return err
+	for i := range metadata.Keywords {
+		path := indexPath(fieldPath(field, "keywords"), i)
+		if !sanitizeField(path, &metadata.Keywords[i], v) {
+			continue
 		}
-		if len(keyword) > 50 {
-			return errors.New("keyword is too long")
+		keyword := metadata.Keywords[i]
+		if len(keyword) > maxKeywordLength {
+			v.add(path, CodeTooLong, fmt.Sprintf("keyword is too long (%d > %d bytes)", len(keyword), maxKeywordLength))
 		}
 		if !isValidKeyword(keyword) {
-			return errors.New("keyword contains invalid characters")
+			v.add(path, CodeInvalidCharacters, "keyword contains invalid characters")
+		}
+	}
+	for i := range metadata.Citations {
+		validateCitation(indexPath(fieldPath(field, "citations"), i), &metadata.Citations[i], v)
+	}
+	if metadata.Year < 0 {
+		v.add(fieldPath(field, "year"), CodeNegative, "year must not be negative")
+	}
+	if metadata.Collection != "" {
+		path := fieldPath(field, "collection")
+		if sanitizeField(path, &metadata.Collection, v) && len(metadata.Collection) > maxCollectionLength {
+			v.add(path, CodeTooLong, fmt.Sprintf("collection is too long (%d > %d bytes)", len(metadata.Collection), maxCollectionLength))
 		}
 	}
-	return nil
 }
 
-func validateCitation(citation Citation) error {
+// validateCitation sanitizes citation in place and records its problems in
+// v under field.
+func validateCitation(field string, citation *Citation, v *ValidationError) {
 	if len(citation.Text) == 0 {
-		return errors.New("citation text cannot be empty")
+		v.add(fieldPath(field, "text"), CodeRequired, "citation text cannot be empty")
+	} else {
+		sanitizeField(fieldPath(field, "text"), &citation.Text, v)
+	}
+	if len(citation.Authors) == 0 {
+		v.add(fieldPath(field, "authors"), CodeRequired, "citation authors cannot be empty")
 	}
-	if len(citation.Author) == 0 {
-		return errors.New("citation author cannot be empty")
+	for i := range citation.Authors {
+		validateAuthor(indexPath(fieldPath(field, "authors"), i), &citation.Authors[i], v)
 	}
 	if citation.Year < 0 {
-		return errors.New("citation year must not be negative")
+		v.add(fieldPath(field, "year"), CodeNegative, "citation year must not be negative")
 	}
 	if len(citation.Title) == 0 {
-		return errors.New("citation title cannot be empty")
+		v.add(fieldPath(field, "title"), CodeRequired, "citation title cannot be empty")
+	} else {
+		sanitizeField(fieldPath(field, "title"), &citation.Title, v)
 	}
-	if err := sanitizeInput(&citation.Text); err != nil {
-		return err
+}
+
+// validateAuthor sanitizes author in place and records its problems in v
+// under field.
+func validateAuthor(field string, author *Author, v *ValidationError) {
+	if author.IsEmpty() {
+		v.add(fieldPath(field, "family"), CodeRequired, "author name cannot be empty")
 	}
-	if err := sanitizeInput(&citation.Author); err != nil {
-		return err
+	if author.Given != "" {
+		sanitizeName(fieldPath(field, "given"), &author.Given, v)
 	}
-	if err := sanitizeInput(&citation.Title); err != nil {
-		return err
+	if author.Family != "" {
+		sanitizeName(fieldPath(field, "family"), &author.Family, v)
 	}
-	return nil
+	if author.Affiliation != "" {
+		sanitizeName(fieldPath(field, "affiliation"), &author.Affiliation, v)
+	}
+	if author.ORCID != "" && !validORCID(author.ORCID) {
+		v.add(fieldPath(field, "orcid"), CodeInvalidFormat, "ORCID iD is malformed or its check digit is wrong")
+	}
+}
+
+// sanitizeField sanitizes the value at field in place, recording a problem
+// in v and returning false if nothing is left of it.
+func sanitizeField(field string, input *string, v *ValidationError) bool {
+	if err := sanitizeInput(input); err != nil {
+		v.add(field, CodeEmptySanitized, err.Error())
+		return false
+	}
+	return true
+}
+
+// sanitizeName strips markup from a name in place and leaves it as plain
+// text. Names are compared and grouped, so unlike sanitizeField it does not
+// HTML-escape what is left: "D'Souza" must stay "D'Souza", not "D&#39;Souza".
+// Escaping is left to whatever renders the name.
+func sanitizeName(field string, input *string, v *ValidationError) bool {
+	*input = strings.TrimSpace(html.UnescapeString(bluemonday.StrictPolicy().Sanitize(*input)))
+	if len(*input) == 0 {
+		v.add(field, CodeEmptySanitized, "input cannot be empty after sanitization")
+		return false
+	}
+	return true
 }
 
 func sanitizeInput(input *string) error {
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// codes returns the field and code of each problem in err, which must be a
+// *ValidationError or nil.
+func codes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var v *ValidationError
+	if !errors.As(err, &v) {
+		t.Fatalf("err = %v, want a *ValidationError", err)
+	}
+	var got []string
+	for _, fe := range v.Errors {
+		got = append(got, fe.Field+" "+fe.Code)
+	}
+	return got
+}
+
+func TestNewDocument(t *testing.T) {
+	complete := Metadata{Title: "Batteries", Authors: []Author{{Given: "Alessandro", Family: "Volta"}}}
+	tests := []struct {
+		name        string
+		text        string
+		metadata    Metadata
+		wantMissing []string
+		wantErrors  []string
+	}{
+		{name: "complete", text: "Text.", metadata: complete},
+		{
+			name:        "missing fields make a draft, not an error",
+			metadata:    Metadata{},
+			wantMissing: []string{"text", "metadata.title", "metadata.authors"},
+		},
+		{
+			name:       "invalid values are still rejected",
+			text:       "Text.",
+			metadata:   Metadata{Keywords: []string{"ok", "bad;keyword"}, Year: -1},
+			wantErrors: []string{"metadata.keywords[1] invalid_characters", "metadata.year negative"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := NewDocument(7, tt.text, tt.metadata)
+			if got := codes(t, err); !slices.Equal(got, tt.wantErrors) {
+				t.Fatalf("errors = %q, want %q", got, tt.wantErrors)
+			}
+			if err != nil {
+				return
+			}
+			if doc.State != StateDraft || doc.FileID != 7 {
+				t.Errorf("state = %q, file ID = %d, want a draft of file 7", doc.State, doc.FileID)
+			}
+			if !slices.Equal(doc.Missing, tt.wantMissing) {
+				t.Errorf("missing = %q, want %q", doc.Missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestDocumentFinalize(t *testing.T) {
+	doc, err := NewDocument(1, "Text.", Metadata{Title: "Batteries"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Finalizing a draft without authors fails and leaves it a draft
+	err = doc.Finalize()
+	if got, want := codes(t, err), []string{"metadata.authors required"}; !slices.Equal(got, want) {
+		t.Fatalf("errors = %q, want %q", got, want)
+	}
+	if doc.State != StateDraft || !slices.Equal(doc.Missing, []string{"metadata.authors"}) {
+		t.Errorf("after a failed finalize: state %q, missing %q", doc.State, doc.Missing)
+	}
+
+	// A draft may be updated with partial metadata
+	if err := doc.Update("", Metadata{}); err != nil {
+		t.Fatalf("updating a draft: %v", err)
+	}
+	if len(doc.Missing) != 3 {
+		t.Errorf("missing = %q, want text, title and authors", doc.Missing)
+	}
+
+	complete := Metadata{Title: "Batteries", Authors: []Author{{Given: "Alessandro", Family: "Volta"}}}
+	if err := doc.Update("Text.", complete); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	if doc.State != StateFinalized || doc.Missing != nil {
+		t.Errorf("after finalize: state %q, missing %q", doc.State, doc.Missing)
+	}
+
+	// A finalized document must stay complete
+	err = doc.Update("Text.", Metadata{Title: "Batteries"})
+	if got, want := codes(t, err), []string{"metadata.authors required"}; !slices.Equal(got, want) {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+	if doc.State != StateFinalized || len(doc.Metadata.Authors) != 1 {
+		t.Errorf("a rejected update changed the document: %+v", doc)
+	}
+}
+
+func TestDocumentWithoutStateIsDraft(t *testing.T) {
+	// documents stored before states existed have none
+	doc := &Document{Text: "Text."}
+	if err := doc.Update("", Metadata{}); err != nil {
+		t.Fatalf("updating a stateless document: %v", err)
+	}
+	if doc.state() != StateDraft {
+		t.Errorf("state() = %q, want draft", doc.state())
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import "strings"
+
+// Page is one page of a document. Number is 1-based and Width and Height
+// are in PDF units. Pages are also emitted while a file is still being
+// parsed, before their blocks are known; Text is then the raw extracted
+// text of the page.
+type Page struct {
+	Number int     `json:"number"`
+	Width  float64 `json:"width,omitempty"`
+	Height float64 `json:"height,omitempty"`
+	Text   string  `json:"text"`
+	Blocks []Block `json:"blocks,omitempty"`
+}
+
+// Block types.
+const (
+	BlockParagraph = "paragraph"
+	BlockHeading   = "heading"
+	BlockList      = "list"
+	BlockTable     = "table"
+	BlockFigure    = "figure"
+	BlockEquation  = "equation"
+	BlockCode      = "code"
+)
+
+// Block is a unit of page content in reading order. Which fields are set
+// depends on Type: Level for headings, Items for lists, Rows for tables and
+// Caption for figures. The rest carry their content in Text.
+type Block struct {
+	Type    string      `json:"type"`
+	Page    int         `json:"page"`
+	BBox    BoundingBox `json:"bbox"`
+	Text    string      `json:"text,omitempty"`
+	Level   int         `json:"level,omitempty"`
+	Items   []string    `json:"items,omitempty"`
+	Rows    [][]string  `json:"rows,omitempty"`
+	Caption string      `json:"caption,omitempty"`
+}
+
+// Figure is an image found in a document. BBox is zero when the image's
+// position on the page is not known.
+type Figure struct {
+	Page    int         `json:"page,omitempty"`
+	BBox    BoundingBox `json:"bbox"`
+	Caption string      `json:"caption,omitempty"`
+}
+
+// PlainText returns the block as it appears in the flat document text:
+// list items and table rows one per line, with table cells separated by
+// tabs. Figures contribute nothing; their captions are blocks of their own.
+func (b Block) PlainText() string {
+	switch b.Type {
+	case BlockList:
+		return strings.Join(b.Items, "\n")
+	case BlockTable:
+		rows := make([]string, len(b.Rows))
+		for i, row := range b.Rows {
+			rows[i] = strings.Join(row, "\t")
+		}
+		return strings.Join(rows, "\n")
+	case BlockFigure:
+		return ""
+	}
+	return b.Text
+}
+
+// BlocksText joins the plain text of blocks with blank lines between them.
+func BlocksText(blocks []Block) string {
+	var parts []string
+	for _, b := range blocks {
+		if text := strings.TrimSpace(b.PlainText()); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// DeriveText rebuilds the flat text from the structure: each page with
+// blocks gets its text from them, and the document text is the page texts
+// in order. Documents without pages keep their text.
+func (d *Document) DeriveText() {
+	if len(d.Pages) == 0 {
+		return
+	}
+	texts := make([]string, len(d.Pages))
+	for i := range d.Pages {
+		if len(d.Pages[i].Blocks) > 0 {
+			d.Pages[i].Text = BlocksText(d.Pages[i].Blocks)
+		}
+		texts[i] = d.Pages[i].Text
+	}
+	d.Text = strings.Join(texts, "\n\n")
+}
+
+// Blocks returns every block in the document in reading order.
+func (d *Document) Blocks() []Block {
+	var blocks []Block
+	for _, p := range d.Pages {
+		blocks = append(blocks, p.Blocks...)
+	}
+	return blocks
+}
+
+// Chunk is a retrievable piece of a document's text. StartChar and EndChar
+// are character offsets into Document.Text, end exclusive. PageStart and
+// PageEnd are 1-based and zero when the document has no pages. SectionPath
+// holds the titles from the outermost section down to the one the chunk is
+// in. TimeRange is set on chunks of transcripts to the part of the
+// recording the chunk was spoken in.
+type Chunk struct {
+	ID          int64      `json:"id"`
+	DocumentID  int64      `json:"document_id"`
+	Ordinal     int        `json:"ordinal"`
+	Text        string     `json:"text"`
+	StartChar   int        `json:"start_char"`
+	EndChar     int        `json:"end_char"`
+	PageStart   int        `json:"page_start,omitempty"`
+	PageEnd     int        `json:"page_end,omitempty"`
+	SectionPath []string   `json:"section_path,omitempty"`
+	TokenCount  int        `json:"token_count"`
+	Language    string     `json:"language,omitempty"`
+	TimeRange   *TimeRange `json:"time_range,omitempty"`
+}
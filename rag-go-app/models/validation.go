@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validation error codes.
+const (
+	CodeRequired          = "required"
+	CodeTooLong           = "too_long"
+	CodeInvalidCharacters = "invalid_characters"
+	CodeEmptySanitized    = "empty_after_sanitization"
+	CodeNegative          = "negative"
+	CodeOutOfRange        = "out_of_range"
+	CodeInvalidFormat     = "invalid_format"
+)
+
+// FieldError is one validation problem. Field is the JSON path of the
+// offending value, such as "metadata.keywords[3]".
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found while validating a value, so
+// they can all be reported at once.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// add records a problem with field.
+func (e *ValidationError) add(field string, code string, message string) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Code: code, Message: message})
+}
+
+// orNil returns e if it holds any problems and nil otherwise, so callers can
+// return it as an error directly.
+func (e *ValidationError) orNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// fieldPath joins a parent path and a child field name.
+func fieldPath(parent string, field string) string {
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
+
+// indexPath addresses an element of a list field.
+func indexPath(field string, i int) string {
+	return fmt.Sprintf("%s[%d]", field, i)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidationErrorPaths(t *testing.T) {
+	metadata := Metadata{
+		Title:    "Batteries",
+		Authors:  []Author{{Given: "Alessandro", Family: "Volta"}, {ORCID: "0000-0002-1825-0098", Family: "Ohm"}},
+		Abstract: "<script>alert(1)</script>",
+		Keywords: []string{"electricity", strings.Repeat("k", maxKeywordLength+1)},
+		Citations: []Citation{
+			{Text: "Volta, A. (1800).", Title: "On electricity", Authors: []Author{{Family: "Volta"}}},
+			{Text: "", Title: "Untitled", Authors: []Author{{Family: "Galvani"}, {}}, Year: -1},
+		},
+		Collection: strings.Repeat("c", maxCollectionLength+1),
+	}
+	_, err := NewDocument(1, "Text.", metadata)
+	want := []string{
+		"metadata.authors[1].orcid invalid_format",
+		"metadata.abstract empty_after_sanitization",
+		"metadata.keywords[1] too_long",
+		"metadata.citations[1].text required",
+		"metadata.citations[1].authors[1].family required",
+		"metadata.citations[1].year negative",
+		"metadata.collection too_long",
+	}
+	if got := codes(t, err); !slices.Equal(got, want) {
+		t.Errorf("errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidationErrorFormat(t *testing.T) {
+	v := &ValidationError{}
+	if v.orNil() != nil {
+		t.Error("an empty ValidationError is not nil")
+	}
+	v.add("metadata.title", CodeRequired, "cannot be empty")
+	v.add(indexPath(fieldPath("metadata", "keywords"), 2), CodeTooLong, "keyword is too long")
+	if got, want := v.Error(), "validation failed: metadata.title: cannot be empty; metadata.keywords[2]: keyword is too long"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	data, err := json.Marshal(v.orNil())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"errors":[{"field":"metadata.title","code":"required","message":"cannot be empty"},` +
+		`{"field":"metadata.keywords[2]","code":"too_long","message":"keyword is too long"}]}`
+	if string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+}
+
+func TestCitationErrorPaths(t *testing.T) {
+	m := Metadata{Citations: []Citation{{Text: "Volta, A. (1800).", Title: "On electricity", Authors: []Author{{Family: "Volta"}}}}}
+
+	// a rejected citation is addressed at the index it would have taken
+	err := m.AddCitation(Citation{Text: "Ohm (1827).", Authors: []Author{{Family: "Ohm", ORCID: "bad"}}})
+	want := []string{"metadata.citations[1].authors[0].orcid invalid_format", "metadata.citations[1].title required"}
+	if got := codes(t, err); !slices.Equal(got, want) {
+		t.Errorf("AddCitation() errors = %q, want %q", got, want)
+	}
+	if len(m.Citations) != 1 {
+		t.Errorf("a rejected citation was added")
+	}
+
+	if got, want := codes(t, m.RemoveCitation(3)), []string{"metadata.citations[3] out_of_range"}; !slices.Equal(got, want) {
+		t.Errorf("RemoveCitation() errors = %q, want %q", got, want)
+	}
+	if err := m.RemoveCitation(0); err != nil || len(m.Citations) != 0 {
+		t.Errorf("RemoveCitation(0) = %v, %d citations left", err, len(m.Citations))
+	}
+}
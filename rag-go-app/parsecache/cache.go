@@ -0,0 +1,54 @@
+package parsecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"rag-go-app/models"
+)
+
+// Key identifies a parse result: the SHA-256 of the file bytes and the
+// parser, and its version, that produced it. Variant separates results that
+// depend on something besides the bytes, such as the password that opened
+// an encrypted file, so a cached result is never served to a caller who
+// could not have produced it.
+type Key struct {
+	ContentHash string
+	Parser      string
+	Version     string
+	Variant     string
+}
+
+// NewKey hashes data and returns its key for the given parser.
+func NewKey(data []byte, parser string, version string) Key {
+	sum := sha256.Sum256(data)
+	return Key{ContentHash: hex.EncodeToString(sum[:]), Parser: parser, Version: version}
+}
+
+// NewKeyFromReader hashes everything read from r without buffering it.
+func NewKeyFromReader(r io.Reader, parser string, version string) (Key, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return Key{}, err
+	}
+	return Key{ContentHash: hex.EncodeToString(h.Sum(nil)), Parser: parser, Version: version}, nil
+}
+
+// WithSecret returns a copy of k whose Variant is derived from secret. An
+// empty secret leaves the key unchanged.
+func (k Key) WithSecret(secret string) Key {
+	if secret == "" {
+		return k
+	}
+	sum := sha256.Sum256([]byte(k.ContentHash + "\x00" + secret))
+	k.Variant = hex.EncodeToString(sum[:8])
+	return k
+}
+
+// Cache stores parse results by Key. Get returns a fresh copy the caller may
+// modify. Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key Key) (*models.Document, bool, error)
+	Put(key Key, doc *models.Document) error
+}
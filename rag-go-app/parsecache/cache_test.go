@@ -0,0 +1,46 @@
+package parsecache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewKey(t *testing.T) {
+	data := []byte("file bytes")
+	key := NewKey(data, "pdf", "1.0.0")
+	fromReader, err := NewKeyFromReader(bytes.NewReader(data), "pdf", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != fromReader {
+		t.Errorf("NewKeyFromReader() = %+v, want %+v", fromReader, key)
+	}
+	if len(key.ContentHash) != 64 || key.Variant != "" {
+		t.Errorf("key = %+v, want a SHA-256 hash and no variant", key)
+	}
+	if other := NewKey([]byte("other bytes"), "pdf", "1.0.0"); other.ContentHash == key.ContentHash {
+		t.Error("different files have the same hash")
+	}
+}
+
+func TestKeyWithSecret(t *testing.T) {
+	key := NewKey([]byte("file bytes"), "pdf", "1.0.0")
+	if key.WithSecret("") != key {
+		t.Error("an empty secret changed the key")
+	}
+	a, b := key.WithSecret("secret"), key.WithSecret("other")
+	if a.Variant == "" || a.Variant == b.Variant {
+		t.Errorf("variants = %q and %q, want distinct and set", a.Variant, b.Variant)
+	}
+	if a != key.WithSecret("secret") {
+		t.Error("the same secret gave a different variant")
+	}
+	if bytes.Contains([]byte(a.Variant), []byte("secret")) {
+		t.Errorf("variant %q holds the secret", a.Variant)
+	}
+	// the same password on another file gives another variant, so the
+	// variant does not reveal that two files share a password
+	if other := NewKey([]byte("other bytes"), "pdf", "1.0.0").WithSecret("secret"); other.Variant == a.Variant {
+		t.Error("the variant does not depend on the file")
+	}
+}
@@ -0,0 +1,139 @@
+package parsecache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rag-go-app/models"
+)
+
+// DiskCache stores parse results as JSON files under a directory:
+//
+//	<dir>/<hash[:2]>/<hash>/<parser>@<version>[.<variant>].json
+//
+// Storing a result removes results for the same file and parser made by
+// other parser versions, so upgrading a parser invalidates its old entries
+// as files are seen again.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache creates a cache rooted at dir, creating it if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory failed: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get returns the cached document for key, if any.
+func (c *DiskCache) Get(key Key) (*models.Document, bool, error) {
+	path, err := c.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var doc models.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		// A corrupt entry is a miss; it is overwritten by the next Put.
+		return nil, false, nil
+	}
+	return &doc, true, nil
+}
+
+// Put stores doc under key, replacing entries from other parser versions.
+// The entry is written to a temp file and renamed into place so readers
+// never see a partial file.
+func (c *DiskCache) Put(key Key, doc *models.Document) error {
+	path, err := c.path(key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating cache directory failed: %w", err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encoding cache entry failed: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("creating cache entry failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache entry failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing cache entry failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("storing cache entry failed: %w", err)
+	}
+
+	c.removeOtherVersions(dir, key)
+	return nil
+}
+
+// removeOtherVersions deletes entries in dir made by key.Parser at a
+// version other than key.Version.
+func (c *DiskCache) removeOtherVersions(dir string, key Key) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	prefix := safeName(key.Parser) + "@"
+	current := prefix + safeName(key.Version)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		version := strings.TrimSuffix(name, ".json")
+		if i := strings.IndexByte(version[len(prefix):], '.'); i >= 0 {
+			version = version[:len(prefix)+i]
+		}
+		if version != current {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+func (c *DiskCache) path(key Key) (string, error) {
+	if len(key.ContentHash) < 2 || key.Parser == "" {
+		return "", errors.New("cache key needs a content hash and parser")
+	}
+	name := safeName(key.Parser) + "@" + safeName(key.Version)
+	if key.Variant != "" {
+		name += "." + safeName(key.Variant)
+	}
+	hash := safeName(key.ContentHash)
+	return filepath.Join(c.dir, hash[:2], hash, name+".json"), nil
+}
+
+// safeName replaces characters that are not safe in file names. Dots are
+// replaced too, since they separate the version from the variant.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '+', r == ',':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,160 @@
+package parsecache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rag-go-app/models"
+)
+
+func newTestCache(t *testing.T) *DiskCache {
+	t.Helper()
+	c, err := NewDiskCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// get returns the text cached under key, or "" for a miss.
+func get(t *testing.T, c *DiskCache, key Key) string {
+	t.Helper()
+	doc, ok, err := c.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		return ""
+	}
+	return doc.Text
+}
+
+func put(t *testing.T, c *DiskCache, key Key, text string) {
+	t.Helper()
+	if err := c.Put(key, &models.Document{Text: text}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// entries lists the file names in the directory of key's entries.
+func entries(t *testing.T, c *DiskCache, key Key) []string {
+	t.Helper()
+	path, err := c.path(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range list {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	c := newTestCache(t)
+	key := NewKey([]byte("file bytes"), "pdf", "1.0.0")
+	if got := get(t, c, key); got != "" {
+		t.Fatalf("empty cache returned %q", got)
+	}
+	put(t, c, key, "parsed text")
+	if got := get(t, c, key); got != "parsed text" {
+		t.Errorf("Get() = %q, want the stored text", got)
+	}
+	// overwriting replaces the entry, and no temp files are left behind
+	put(t, c, key, "parsed again")
+	if got := get(t, c, key); got != "parsed again" {
+		t.Errorf("Get() after overwrite = %q", got)
+	}
+	if names := entries(t, c, key); len(names) != 1 || names[0] != "pdf@1_0_0.json" {
+		t.Errorf("entries = %v, want only pdf@1_0_0.json", names)
+	}
+
+	// Get returns a copy the caller may change
+	doc, _, _ := c.Get(key)
+	doc.Text = "changed"
+	if got := get(t, c, key); got != "parsed again" {
+		t.Errorf("changing a result changed the cache: %q", got)
+	}
+}
+
+func TestDiskCacheRemovesOtherVersions(t *testing.T) {
+	c := newTestCache(t)
+	data := []byte("file bytes")
+	old := NewKey(data, "pdf", "1.0.0")
+	oldLocked := old.WithSecret("secret")
+	other := NewKey(data, "docx", "1.0.0")
+	similar := NewKey(data, "pdf-ocr", "1.0.0")
+	for _, key := range []Key{old, oldLocked, other, similar} {
+		put(t, c, key, key.Parser)
+	}
+
+	current := NewKey(data, "pdf", "2.0.0")
+	put(t, c, current, "new")
+	for _, tt := range []struct {
+		key  Key
+		want string
+	}{
+		{old, ""},
+		{oldLocked, ""},
+		{current, "new"},
+		{other, "docx"},
+		{similar, "pdf-ocr"},
+	} {
+		if got := get(t, c, tt.key); got != tt.want {
+			t.Errorf("Get(%s@%s.%s) = %q, want %q", tt.key.Parser, tt.key.Version, tt.key.Variant, got, tt.want)
+		}
+	}
+
+	// a variant of the current version is kept
+	currentLocked := current.WithSecret("secret")
+	put(t, c, currentLocked, "locked")
+	if get(t, c, current) != "new" || get(t, c, currentLocked) != "locked" {
+		t.Error("storing a variant removed another of the same version")
+	}
+}
+
+func TestDiskCacheCorruptEntry(t *testing.T) {
+	c := newTestCache(t)
+	key := NewKey([]byte("file bytes"), "pdf", "1.0.0")
+	put(t, c, key, "parsed text")
+	path, _ := c.path(key)
+	if err := os.WriteFile(path, []byte(`{"text": "trunc`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, c, key); got != "" {
+		t.Errorf("corrupt entry returned %q, want a miss", got)
+	}
+	put(t, c, key, "parsed again")
+	if got := get(t, c, key); got != "parsed again" {
+		t.Errorf("Get() after rewriting = %q", got)
+	}
+}
+
+func TestDiskCachePath(t *testing.T) {
+	c := newTestCache(t)
+	if _, _, err := c.Get(Key{Parser: "pdf"}); err == nil {
+		t.Error("a key without a hash: expected an error")
+	}
+	if err := c.Put(Key{ContentHash: "abcd"}, &models.Document{}); err == nil {
+		t.Error("a key without a parser: expected an error")
+	}
+
+	// names cannot escape the cache directory or forge a variant
+	path, err := c.path(Key{ContentHash: "../../etc", Parser: "../pdf", Version: "1.0/x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(c.dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		t.Errorf("path %s is outside the cache", path)
+	}
+	if base := filepath.Base(path); base != "___pdf@1_0_x.json" {
+		t.Errorf("entry name = %q", base)
+	}
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"strings"
+
+	"rag-go-app/langdetect"
+	"rag-go-app/models"
+)
+
+// tagLanguages sets the document's primary language and the language of each
+// section, keeping any a parser already set. A section is tagged from its
+// title and the pages from its start up to where the next section outside
+// it starts.
+// Documents without pages have their sections tagged from titles alone.
+func tagLanguages(doc *models.Document) {
+	if doc == nil {
+		return
+	}
+	if doc.Metadata.Language == "" {
+		doc.Metadata.Language = langdetect.Detect(doc.Text).Language
+	}
+
+	pageTexts := make([]string, len(doc.Pages))
+	for i, page := range doc.Pages {
+		pageTexts[i] = page.Text
+	}
+
+	// Sections in document order, with their depth, to find where each
+	// one ends
+	type flatSection struct {
+		section *models.Section
+		depth   int
+	}
+	var flat []flatSection
+	var walk func(sections []models.Section, depth int)
+	walk = func(sections []models.Section, depth int) {
+		for i := range sections {
+			flat = append(flat, flatSection{section: &sections[i], depth: depth})
+			walk(sections[i].Children, depth+1)
+		}
+	}
+	walk(doc.Sections, 0)
+
+	for i, f := range flat {
+		section := f.section
+		if section.Language != "" {
+			continue
+		}
+		// A section spans its subsections, so it ends where the next
+		// section that is not one of them starts
+		end := len(pageTexts)
+		for _, next := range flat[i+1:] {
+			if next.depth <= f.depth {
+				end = next.section.Page
+				break
+			}
+		}
+		text := section.Title + "\n" + pageRange(pageTexts, section.Page, end)
+		result := langdetect.Detect(text)
+		if result.Language == langdetect.Unknown {
+			// Headings alone are often too short to tell
+			result.Language = doc.Metadata.Language
+		}
+		section.Language = result.Language
+	}
+}
+
+// pageRange joins the text of pages first through last, 1-based and
+// inclusive, clamped to the pages available.
+func pageRange(pageTexts []string, first, last int) string {
+	first = max(first, 1)
+	last = min(last, len(pageTexts))
+	if first > last {
+		return ""
+	}
+	return strings.Join(pageTexts[first-1:last], "\n")
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"rag-go-app/langdetect"
+	"rag-go-app/models"
+)
+
+func TestTagLanguages(t *testing.T) {
+	const (
+		english = "Photosynthesis converts light energy into chemical energy stored in glucose molecules within chloroplasts. The results show that regular practice improves the ability of students to learn."
+		hindi   = "प्रकाश संश्लेषण में पौधे सूर्य के प्रकाश से भोजन बनाते हैं। इस अध्ययन में हमने कई विद्यालयों के छात्रों के प्रदर्शन का विश्लेषण किया है।"
+	)
+	doc := &models.Document{
+		Text: english + "\n\n" + english,
+		Pages: []models.Page{
+			{Number: 1, Text: strings.Repeat(hindi+" ", 3)},
+			{Number: 2, Text: english},
+			{Number: 3, Text: english},
+			{Number: 4, Text: english},
+			{Number: 5, Text: hindi},
+		},
+		Sections: []models.Section{
+			{Title: "Chapter 1", Page: 1, Children: []models.Section{
+				{Title: "Foreword", Page: 1},
+				{Title: "Photosynthesis", Page: 2, Children: []models.Section{
+					{Title: "Light", Page: 3},
+				}},
+			}},
+			{Title: "अध्याय 2", Page: 5, Children: []models.Section{
+				{Title: "Glossary", Page: 5, Language: langdetect.Marathi},
+			}},
+		},
+	}
+	tagLanguages(doc)
+
+	if doc.Metadata.Language != langdetect.English {
+		t.Errorf("document language = %q, want en", doc.Metadata.Language)
+	}
+	want := map[string]string{
+		// spans its subsections up to chapter 2, not only the page of
+		// Hindi before the first of them
+		"Chapter 1": langdetect.English,
+		// ends on the page Photosynthesis starts on
+		"Foreword": langdetect.Hindi,
+		// ends where chapter 2 starts, not where its subsection does
+		"Photosynthesis": langdetect.English,
+		"Light":          langdetect.English,
+		"अध्याय 2":       langdetect.Hindi,
+		// set by the parser, so kept
+		"Glossary": langdetect.Marathi,
+	}
+	var check func(sections []models.Section)
+	check = func(sections []models.Section) {
+		for _, s := range sections {
+			if s.Language != want[s.Title] {
+				t.Errorf("section %q language = %q, want %q", s.Title, s.Language, want[s.Title])
+			}
+			check(s.Children)
+		}
+	}
+	check(doc.Sections)
+}
+
+func TestTagLanguagesWithoutPages(t *testing.T) {
+	doc := &models.Document{
+		Text: "प्रकाश संश्लेषण में पौधे सूर्य के प्रकाश से भोजन बनाते हैं। इस अध्ययन में हमने कई विद्यालयों के छात्रों के प्रदर्शन का विश्लेषण किया है।",
+		Sections: []models.Section{
+			{Title: "Photosynthesis converts light energy into chemical energy", Page: 1},
+			// too short to tell, so it takes the document's language
+			{Title: "1.2", Page: 1},
+		},
+	}
+	tagLanguages(doc)
+
+	if doc.Metadata.Language != langdetect.Hindi {
+		t.Errorf("document language = %q, want hi", doc.Metadata.Language)
+	}
+	if got := doc.Sections[0].Language; got != langdetect.English {
+		t.Errorf("section language from its title = %q, want en", got)
+	}
+	if got := doc.Sections[1].Language; got != langdetect.Hindi {
+		t.Errorf("untitled section language = %q, want the document's hi", got)
+	}
+}
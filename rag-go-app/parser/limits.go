@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Limits bounds the resources a single parse of an untrusted file may use.
+// A zero field means no limit.
+type Limits struct {
+	// MaxInputBytes caps the size of the uploaded file.
+	MaxInputBytes int64
+	// MaxPages caps the number of pages in paged formats such as PDF.
+	MaxPages int
+	// MaxDecompressedBytes caps the expanded size of archive-based formats
+	// (DOCX, PPTX) and the output read back from child processes.
+	MaxDecompressedBytes int64
+	// Timeout caps the wall-clock time of the whole parse.
+	Timeout time.Duration
+	// MaxChildProcesses caps how many external processes one parse may start.
+	MaxChildProcesses int
+}
+
+// DefaultLimits returns limits suitable for student uploads.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxInputBytes:        200 << 20,
+		MaxPages:             2000,
+		MaxDecompressedBytes: 1 << 30,
+		Timeout:              5 * time.Minute,
+		MaxChildProcesses:    8,
+	}
+}
+
+// Names of the limits reported in LimitError.
+const (
+	LimitInputBytes        = "max_input_bytes"
+	LimitPages             = "max_pages"
+	LimitDecompressedBytes = "max_decompressed_bytes"
+	LimitTimeout           = "timeout"
+	LimitChildProcesses    = "max_child_processes"
+)
+
+// LimitError is returned when a parse breaches one of its Limits.
+type LimitError struct {
+	Limit string
+	Value int64
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == LimitTimeout {
+		return fmt.Sprintf("parse exceeded %s of %s", e.Limit, time.Duration(e.Max))
+	}
+	return fmt.Sprintf("parse exceeded %s: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+// LimitsFromEnv returns DefaultLimits overridden by the PARSE_MAX_INPUT_BYTES,
+// PARSE_MAX_PAGES, PARSE_MAX_DECOMPRESSED_BYTES, PARSE_TIMEOUT and
+// PARSE_MAX_CHILD_PROCESSES environment variables.
+func LimitsFromEnv() Limits {
+	limits := DefaultLimits()
+	if v, ok := envInt("PARSE_MAX_INPUT_BYTES"); ok {
+		limits.MaxInputBytes = v
+	}
+	if v, ok := envInt("PARSE_MAX_PAGES"); ok {
+		limits.MaxPages = int(v)
+	}
+	if v, ok := envInt("PARSE_MAX_DECOMPRESSED_BYTES"); ok {
+		limits.MaxDecompressedBytes = v
+	}
+	if v, ok := envInt("PARSE_MAX_CHILD_PROCESSES"); ok {
+		limits.MaxChildProcesses = int(v)
+	}
+	if value := os.Getenv("PARSE_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("Invalid PARSE_TIMEOUT %q, using default: %v", value, limits.Timeout)
+		} else {
+			limits.Timeout = d
+		}
+	}
+	return limits
+}
+
+func envInt(name string) (int64, bool) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || v < 0 {
+		log.Printf("Invalid %s %q, using default", name, value)
+		return 0, false
+	}
+	return v, true
+}
+
+// checkInputSize returns a LimitError if size exceeds MaxInputBytes.
+func (l Limits) checkInputSize(size int64) error {
+	if l.MaxInputBytes > 0 && size > l.MaxInputBytes {
+		return &LimitError{Limit: LimitInputBytes, Value: size, Max: l.MaxInputBytes}
+	}
+	return nil
+}
+
+// checkPages returns a LimitError if pages exceeds MaxPages.
+func (l Limits) checkPages(pages int) error {
+	if l.MaxPages > 0 && pages > l.MaxPages {
+		return &LimitError{Limit: LimitPages, Value: int64(pages), Max: int64(l.MaxPages)}
+	}
+	return nil
+}
+
+// checkZipArchive guards against zip bombs in DOCX and PPTX files. The sizes
+// declared in the archive can be forged, so every entry is also decompressed
+// and counted, stopping as soon as the total passes MaxDecompressedBytes.
+func (l Limits) checkZipArchive(data []byte) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("reading archive failed: %w", err)
+	}
+	if l.MaxDecompressedBytes <= 0 {
+		return nil
+	}
+
+	var declared uint64
+	for _, f := range archive.File {
+		declared += f.UncompressedSize64
+	}
+	if declared > uint64(l.MaxDecompressedBytes) {
+		return &LimitError{Limit: LimitDecompressedBytes, Value: int64(min(declared, 1<<62)), Max: l.MaxDecompressedBytes}
+	}
+
+	var total int64
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("opening archive entry %s failed: %w", f.Name, err)
+		}
+		remaining := l.MaxDecompressedBytes - total
+		n, err := io.Copy(io.Discard, io.LimitReader(rc, remaining+1))
+		rc.Close()
+		total += n
+		if total > l.MaxDecompressedBytes {
+			return &LimitError{Limit: LimitDecompressedBytes, Value: total, Max: l.MaxDecompressedBytes}
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive entry %s failed: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// cappedWriter passes child process output on to w up to max bytes and
+// fails the write after that, which stops the copy from the child's stdout.
+// The writer is not embedded so io.Copy cannot bypass Write through
+// ReadFrom.
+type cappedWriter struct {
+	w         io.Writer
+	max       int64
+	attempted int64
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	c.attempted += int64(len(p))
+	if c.exceeded() {
+		return 0, &LimitError{Limit: LimitDecompressedBytes, Value: c.attempted, Max: c.max}
+	}
+	return c.w.Write(p)
+}
+
+func (c *cappedWriter) exceeded() bool {
+	return c.max > 0 && c.attempted > c.max
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsChecks(t *testing.T) {
+	limits := Limits{MaxInputBytes: 100, MaxPages: 10}
+	tests := []struct {
+		name      string
+		err       error
+		wantLimit string
+	}{
+		{"input at the limit", limits.checkInputSize(100), ""},
+		{"input over the limit", limits.checkInputSize(101), LimitInputBytes},
+		{"pages at the limit", limits.checkPages(10), ""},
+		{"pages over the limit", limits.checkPages(11), LimitPages},
+		{"zero input limit", Limits{}.checkInputSize(1 << 40), ""},
+		{"zero pages limit", Limits{}.checkPages(1 << 20), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLimitError(t, tt.err, tt.wantLimit)
+		})
+	}
+}
+
+// checkLimitError fails t unless err is a LimitError for limit, or nil
+// when limit is empty.
+func checkLimitError(t *testing.T, err error, limit string) {
+	t.Helper()
+	if limit == "" {
+		if err != nil {
+			t.Errorf("unexpected err %v", err)
+		}
+		return
+	}
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != limit {
+		t.Errorf("err = %v, want a %s LimitError", err, limit)
+	}
+}
+
+// testZip returns a zip archive with an entry of size bytes per size.
+func testZip(t *testing.T, sizes ...int) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for i, size := range sizes {
+		f, err := w.Create(strings.Repeat("x", i+1) + ".xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(bytes.Repeat([]byte("a"), size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestCheckZipArchive(t *testing.T) {
+	archive := testZip(t, 600, 600)
+	tests := []struct {
+		name      string
+		limits    Limits
+		data      []byte
+		wantLimit string
+		wantErr   bool
+	}{
+		{name: "within the limit", limits: Limits{MaxDecompressedBytes: 1200}, data: archive},
+		{name: "expands past the limit", limits: Limits{MaxDecompressedBytes: 1000}, data: archive, wantLimit: LimitDecompressedBytes},
+		{name: "no limit", data: archive},
+		{name: "not an archive", limits: Limits{MaxDecompressedBytes: 1000}, data: []byte("plain text"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.checkZipArchive(tt.data)
+			if tt.wantErr {
+				var limitErr *LimitError
+				if err == nil || errors.As(err, &limitErr) {
+					t.Errorf("err = %v, want a read error", err)
+				}
+				return
+			}
+			checkLimitError(t, err, tt.wantLimit)
+		})
+	}
+}
+
+func TestCappedWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &cappedWriter{w: &out, max: 10}
+	if n, err := w.Write([]byte("12345")); n != 5 || err != nil {
+		t.Fatalf("Write() = %d, %v", n, err)
+	}
+	if n, err := w.Write([]byte("67890")); n != 5 || err != nil {
+		t.Fatalf("Write() up to the cap = %d, %v", n, err)
+	}
+	n, err := w.Write([]byte("!"))
+	checkLimitError(t, err, LimitDecompressedBytes)
+	if n != 0 || out.String() != "1234567890" || !w.exceeded() {
+		t.Errorf("after the cap: wrote %d, output %q, exceeded = %v", n, out.String(), w.exceeded())
+	}
+
+	unlimited := &cappedWriter{w: &out}
+	if _, err := unlimited.Write(make([]byte, 1<<20)); err != nil || unlimited.exceeded() {
+		t.Errorf("with no cap: err = %v, exceeded = %v", err, unlimited.exceeded())
+	}
+}
+
+func TestParseSessionRun(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo is not available")
+	}
+
+	s := newParseSession(context.Background(), nil, 0, ParseOptions{Limits: Limits{MaxChildProcesses: 2, MaxDecompressedBytes: 20}})
+	defer s.cleanup()
+	out, err := s.run(nil, "echo", "hello")
+	if err != nil || string(out) != "hello\n" {
+		t.Fatalf("run() = %q, %v", out, err)
+	}
+	// output past MaxDecompressedBytes stops the child
+	_, err = s.run(nil, "echo", strings.Repeat("x", 100))
+	checkLimitError(t, err, LimitDecompressedBytes)
+	// and a third child is one too many
+	_, err = s.run(nil, "echo", "hello")
+	checkLimitError(t, err, LimitChildProcesses)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	expired := newParseSession(ctx, nil, 0, ParseOptions{Limits: Limits{Timeout: time.Nanosecond}})
+	_, err = expired.run(nil, "echo", "hello")
+	checkLimitError(t, err, LimitTimeout)
+	if expired.children != 0 {
+		t.Errorf("started %d children after the deadline", expired.children)
+	}
+}
+
+func TestParseSessionCleanup(t *testing.T) {
+	data := []byte("%PDF-1.4 source")
+	s := newParseSession(context.Background(), bytes.NewReader(data), int64(len(data)), ParseOptions{})
+	path, err := s.sourcePath("source-*.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("source copy = %q, %v", got, err)
+	}
+	if again, _ := s.sourcePath("source-*.pdf"); again != path {
+		t.Errorf("source copied again to %s", again)
+	}
+	s.cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("temp file %s left behind: %v", path, err)
+	}
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"rag-go-app/models"
+
+	"github.com/unidoc/unidoc/v3/pdf/core"
+	"github.com/unidoc/unidoc/v3/pdf/model"
+)
+
+// extractAnnotations reads the markup and link annotations on a page. The
+// covered text is filled in afterwards by fillAnnotatedText, which needs
+// pdfminer's layout of the page.
+func extractAnnotations(page *model.PdfPage, pageNum int) ([]models.Annotation, []models.Hyperlink, error) {
+	annots, err := page.GetAnnotations()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting annotations failed: %w", err)
+	}
+
+	var annotations []models.Annotation
+	var hyperlinks []models.Hyperlink
+	for _, annot := range annots {
+		if annot == nil {
+			continue
+		}
+		rect := annotationRect(annot.Rect)
+
+		switch t := annot.GetContext().(type) {
+		case *model.PdfAnnotationHighlight:
+			annotations = append(annotations, newMarkupAnnotation(models.AnnotationHighlight, pageNum, annot, t.PdfAnnotationMarkup, t.QuadPoints, rect))
+		case *model.PdfAnnotationUnderline:
+			annotations = append(annotations, newMarkupAnnotation(models.AnnotationUnderline, pageNum, annot, t.PdfAnnotationMarkup, t.QuadPoints, rect))
+		case *model.PdfAnnotationStrikeOut:
+			annotations = append(annotations, newMarkupAnnotation(models.AnnotationStrikeOut, pageNum, annot, t.PdfAnnotationMarkup, t.QuadPoints, rect))
+		case *model.PdfAnnotationSquiggly:
+			annotations = append(annotations, newMarkupAnnotation(models.AnnotationSquiggly, pageNum, annot, t.PdfAnnotationMarkup, t.QuadPoints, rect))
+		case *model.PdfAnnotationText:
+			annotations = append(annotations, newMarkupAnnotation(models.AnnotationNote, pageNum, annot, t.PdfAnnotationMarkup, nil, rect))
+		case *model.PdfAnnotationFreeText:
+			annotations = append(annotations, newMarkupAnnotation(models.AnnotationFreeText, pageNum, annot, t.PdfAnnotationMarkup, nil, rect))
+		case *model.PdfAnnotationLink:
+			uri, err := linkURI(t)
+			if err != nil {
+				return annotations, hyperlinks, fmt.Errorf("reading link action failed: %w", err)
+			}
+			// Links to other pages in the same document are navigation, not
+			// hyperlinks, and are skipped.
+			if uri == "" {
+				continue
+			}
+			hyperlinks = append(hyperlinks, models.Hyperlink{Page: pageNum, URI: uri, Rect: rect})
+		}
+	}
+	return annotations, hyperlinks, nil
+}
+
+func newMarkupAnnotation(kind string, pageNum int, annot *model.PdfAnnotation, markup *model.PdfAnnotationMarkup, quadPoints core.PdfObject, rect models.BoundingBox) models.Annotation {
+	a := models.Annotation{
+		Type:     kind,
+		Page:     pageNum,
+		Contents: pdfString(annot.Contents),
+		Color:    pdfNumbers(annot.C),
+	}
+	if markup != nil {
+		a.Author = pdfString(markup.T)
+	}
+
+	// Text markup annotations mark each covered line with a quadrilateral;
+	// the annotation rectangle is only their union.
+	a.Regions = quadPointRegions(quadPoints)
+	if len(a.Regions) == 0 {
+		a.Regions = []models.BoundingBox{rect}
+	}
+	return a
+}
+
+func linkURI(link *model.PdfAnnotationLink) (string, error) {
+	if link.A == nil {
+		return "", nil
+	}
+	action, err := link.GetAction()
+	if err != nil || action == nil {
+		return "", err
+	}
+	if uriAction, ok := action.GetContext().(*model.PdfActionURI); ok {
+		return strings.TrimSpace(pdfString(uriAction.URI)), nil
+	}
+	return "", nil
+}
+
+// fillAnnotatedText sets the text under each annotation and link on the
+// page of layout from its pdfminer text boxes.
+func fillAnnotatedText(layout pdfminerPage, annotations []models.Annotation, hyperlinks []models.Hyperlink) {
+	for i := range annotations {
+		// Notes are anchored to a point and cover no text.
+		if annotations[i].Page != layout.ID || annotations[i].Type == models.AnnotationNote {
+			continue
+		}
+		annotations[i].Text = textInRegions(layout.TextBoxes, annotations[i].Regions)
+	}
+	for i := range hyperlinks {
+		if hyperlinks[i].Page == layout.ID {
+			hyperlinks[i].Text = textInRegions(layout.TextBoxes, []models.BoundingBox{hyperlinks[i].Rect})
+		}
+	}
+}
+
+func textInRegions(boxes []pdfminerTextBox, regions []models.BoundingBox) string {
+	var parts []string
+	for _, box := range boxes {
+		bbox := models.BoundingBox{X0: box.X0, Y0: box.Y0, X1: box.X1, Y1: box.Y1}
+		for _, region := range regions {
+			if bbox.Intersects(region) {
+				parts = append(parts, strings.TrimSpace(box.Text))
+				break
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func annotationRect(obj core.PdfObject) models.BoundingBox {
+	nums := pdfNumbers(obj)
+	if len(nums) != 4 {
+		return models.BoundingBox{}
+	}
+	return normalizedBox(nums[0], nums[1], nums[2], nums[3])
+}
+
+// quadPointRegions converts a QuadPoints array, eight numbers per
+// quadrilateral, into axis-aligned boxes.
+func quadPointRegions(obj core.PdfObject) []models.BoundingBox {
+	nums := pdfNumbers(obj)
+	var regions []models.BoundingBox
+	for i := 0; i+8 <= len(nums); i += 8 {
+		q := nums[i : i+8]
+		box := normalizedBox(q[0], q[1], q[0], q[1])
+		for j := 2; j < 8; j += 2 {
+			box.X0 = min(box.X0, q[j])
+			box.X1 = max(box.X1, q[j])
+			box.Y0 = min(box.Y0, q[j+1])
+			box.Y1 = max(box.Y1, q[j+1])
+		}
+		regions = append(regions, box)
+	}
+	return regions
+}
+
+func normalizedBox(x0, y0, x1, y1 float64) models.BoundingBox {
+	return models.BoundingBox{X0: min(x0, x1), Y0: min(y0, y1), X1: max(x0, x1), Y1: max(y0, y1)}
+}
+
+func pdfString(obj core.PdfObject) string {
+	if obj == nil {
+		return ""
+	}
+	if s, ok := core.GetString(obj); ok {
+		return s.Decoded()
+	}
+	return ""
+}
+
+func pdfNumbers(obj core.PdfObject) []float64 {
+	if obj == nil {
+		return nil
+	}
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return nil
+	}
+	nums, err := core.GetNumbersAsFloat(arr.Elements())
+	if err != nil {
+		return nil
+	}
+	return nums
+}
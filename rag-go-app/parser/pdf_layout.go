@@ -0,0 +1,511 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"rag-go-app/models"
+)
+
+// Layout heuristics. Font sizes are compared with the page's body size, the
+// size most of its characters are set in.
+const (
+	// headingSizeRatio is how much larger than body text a heading is set.
+	headingSizeRatio = 1.15
+	// maxHeadingChars is the longest text treated as a heading.
+	maxHeadingChars = 200
+	// rowTolerance is how far apart, in PDF units, the baselines of text
+	// boxes in the same table row may be.
+	rowTolerance = 3.0
+	// minMonospaceShare is the share of monospace characters that makes a
+	// box a code block.
+	minMonospaceShare = 0.6
+	// minMathShare is the share of math characters that makes a box an
+	// equation.
+	minMathShare = 0.3
+	// maxEquationChars is the longest text treated as an equation.
+	maxEquationChars = 300
+)
+
+var (
+	listMarkerPattern = regexp.MustCompile(`^\s*([•◦▪▫●○‣∙·\-–—*]|\(?\d{1,3}[.)]|\(?[a-zA-Z][.)]|\(?[ivxIVX]{1,4}[.)])\s+`)
+	captionPattern    = regexp.MustCompile(`^(?i)(figure|fig\.)\s*\d`)
+	monospaceFonts    = []string{"mono", "courier", "consolas", "menlo", "inconsolata", "cmtt", "code"}
+	mathFonts         = []string{"cmmi", "cmsy", "cmex", "msbm", "math", "symbol"}
+)
+
+// layoutReader decodes pdfminer's XML layout from the file pdf2txt wrote
+// it to, one <page> element at a time, so only the page being worked on is
+// held in memory. Pages are read forward; asking for a page before the last
+// one read starts again from the top of the file.
+type layoutReader struct {
+	path string
+	f    *os.File
+	dec  *xml.Decoder
+	seq  int
+
+	last    pdfminerPage
+	hasLast bool
+	done    bool
+}
+
+// page returns page pageNum of the layout. ok is false when the layout has
+// no such page.
+func (r *layoutReader) page(pageNum int) (pdfminerPage, bool, error) {
+	if r.hasLast && r.last.ID == pageNum {
+		return r.last, true, nil
+	}
+	if r.done && r.hasLast && pageNum > r.last.ID {
+		return pdfminerPage{}, false, nil
+	}
+	if r.dec == nil || (r.hasLast && r.last.ID > pageNum) || r.done {
+		if err := r.rewind(); err != nil {
+			return pdfminerPage{}, false, err
+		}
+	}
+
+	for {
+		tok, err := r.dec.Token()
+		if err == io.EOF {
+			r.done = true
+			return pdfminerPage{}, false, nil
+		}
+		if err != nil {
+			return pdfminerPage{}, false, fmt.Errorf("XML parsing failed: %w", err)
+		}
+		start, isStart := tok.(xml.StartElement)
+		if !isStart || start.Name.Local != "page" {
+			continue
+		}
+
+		var page pdfminerPage
+		if err := r.dec.DecodeElement(&page, &start); err != nil {
+			return pdfminerPage{}, false, fmt.Errorf("XML parsing failed: %w", err)
+		}
+		r.seq++
+		if page.ID == 0 {
+			page.ID = r.seq
+		}
+		fillTextBoxes(&page)
+		r.last, r.hasLast = page, true
+		if page.ID == pageNum {
+			return page, true, nil
+		}
+		if page.ID > pageNum {
+			return pdfminerPage{}, false, nil
+		}
+	}
+}
+
+// rewind starts decoding again from the top of the layout file.
+func (r *layoutReader) rewind() error {
+	r.close()
+	f, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("opening layout failed: %w", err)
+	}
+	r.f = f
+	r.dec = xml.NewDecoder(bufio.NewReader(f))
+	r.seq = 0
+	r.last, r.hasLast, r.done = pdfminerPage{}, false, false
+	return nil
+}
+
+func (r *layoutReader) close() {
+	if r.f != nil {
+		r.f.Close()
+		r.f, r.dec = nil, nil
+	}
+}
+
+// applyLayout sets the size and blocks of page from its pdfminer layout.
+// A page whose text came from OCR has no text layout, so its OCR text
+// becomes a single paragraph covering the page.
+func applyLayout(page *models.Page, layout pdfminerPage, extractor string) {
+	pageBox, _ := parseBBox(layout.BBox)
+	page.Width = pageBox.X1 - pageBox.X0
+	page.Height = pageBox.Y1 - pageBox.Y0
+
+	if extractor == models.ExtractorOCR {
+		if strings.TrimSpace(page.Text) != "" {
+			page.Blocks = []models.Block{{Type: models.BlockParagraph, Page: page.Number, BBox: pageBox, Text: page.Text}}
+		}
+		return
+	}
+	page.Blocks = pageBlocks(layout, page.Number)
+}
+
+// pageBlocks classifies the text boxes of a page into typed blocks, keeping
+// pdfminer's reading order. Runs of boxes that line up in rows become
+// tables, and figures are placed before the first text below their top.
+func pageBlocks(layout pdfminerPage, pageNum int) []models.Block {
+	body := bodyFontSize(layout.TextBoxes)
+
+	var blocks []models.Block
+	boxes := layout.TextBoxes
+	for i := 0; i < len(boxes); {
+		if rows, n := tableRows(boxes[i:]); n > 0 {
+			bbox := boxBounds(boxes[i])
+			for _, box := range boxes[i+1 : i+n] {
+				bbox = unionBox(bbox, boxBounds(box))
+			}
+			blocks = append(blocks, models.Block{Type: models.BlockTable, Page: pageNum, BBox: bbox, Rows: rows})
+			i += n
+			continue
+		}
+		if block, ok := classifyBox(boxes[i], pageNum, body); ok {
+			blocks = append(blocks, block)
+		}
+		i++
+	}
+
+	for _, fig := range layout.Figures {
+		bbox, ok := parseBBox(fig.BBox)
+		if !ok {
+			continue
+		}
+		block := models.Block{Type: models.BlockFigure, Page: pageNum, BBox: bbox}
+		at := len(blocks)
+		for j, b := range blocks {
+			if b.Type != models.BlockFigure && b.BBox.Y1 < bbox.Y1 {
+				at = j
+				break
+			}
+		}
+		blocks = append(blocks[:at], append([]models.Block{block}, blocks[at:]...)...)
+	}
+
+	// A caption follows its figure
+	for j := range blocks {
+		if blocks[j].Type != models.BlockFigure {
+			continue
+		}
+		for k := j + 1; k < len(blocks); k++ {
+			if blocks[k].Type == models.BlockFigure {
+				break
+			}
+			if captionPattern.MatchString(blocks[k].Text) {
+				blocks[j].Caption = blocks[k].Text
+				break
+			}
+		}
+	}
+	return blocks
+}
+
+// classifyBox turns one text box into a block. Boxes with no text are
+// dropped.
+func classifyBox(box pdfminerTextBox, pageNum int, bodySize float64) (models.Block, bool) {
+	text := strings.TrimSpace(box.Text)
+	if text == "" {
+		return models.Block{}, false
+	}
+	block := models.Block{Type: models.BlockParagraph, Page: pageNum, BBox: boxBounds(box), Text: text}
+
+	size, monospace, mathFont := box.fontStats()
+	lines := box.lineTexts()
+	if len(lines) == 0 {
+		lines = strings.Split(text, "\n")
+	}
+
+	switch {
+	case monospace >= minMonospaceShare:
+		block.Type = models.BlockCode
+		// Keep indentation, which matters in code
+		block.Text = strings.Trim(box.Text, "\n")
+	case len(text) <= maxEquationChars && (mathFont >= minMathShare || mathShare(text) >= minMathShare):
+		block.Type = models.BlockEquation
+	case bodySize > 0 && size >= bodySize*headingSizeRatio && len(text) <= maxHeadingChars && len(lines) <= 3:
+		block.Type = models.BlockHeading
+		block.Text = strings.Join(strings.Fields(text), " ")
+		block.Level = headingLevel(size / bodySize)
+	case listMarkerPattern.MatchString(lines[0]) && (len(lines) == 1 || countListMarkers(lines) >= 2):
+		block.Type = models.BlockList
+		block.Items = listItems(lines)
+	}
+	return block, true
+}
+
+func headingLevel(ratio float64) int {
+	switch {
+	case ratio >= 1.6:
+		return 1
+	case ratio >= 1.3:
+		return 2
+	}
+	return 3
+}
+
+func countListMarkers(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if listMarkerPattern.MatchString(line) {
+			n++
+		}
+	}
+	return n
+}
+
+// listItems splits lines into items at each list marker, joining wrapped
+// lines onto the item they continue.
+func listItems(lines []string) []string {
+	var items []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if listMarkerPattern.MatchString(line) || len(items) == 0 {
+			items = append(items, line)
+			continue
+		}
+		items[len(items)-1] += " " + line
+	}
+	return items
+}
+
+// tableRows reports whether boxes start with two or more rows of at least
+// two short boxes sharing a baseline, and returns the rows and how many
+// boxes they span. Boxes of more than two lines are running text, not
+// cells, as in two-column layouts whose columns happen to align.
+func tableRows(boxes []pdfminerTextBox) ([][]string, int) {
+	var rows [][]string
+	n := 0
+	for n < len(boxes) {
+		end := n + 1
+		for end < len(boxes) && abs(boxes[end].Y0-boxes[n].Y0) <= rowTolerance {
+			end++
+		}
+		for _, box := range boxes[n:end] {
+			if len(box.Lines) > 2 {
+				end = n
+				break
+			}
+		}
+		if end-n < 2 {
+			break
+		}
+		row := make([]string, 0, end-n)
+		for _, box := range boxes[n:end] {
+			row = append(row, strings.Join(strings.Fields(box.Text), " "))
+		}
+		rows = append(rows, row)
+		n = end
+	}
+	if len(rows) < 2 {
+		return nil, 0
+	}
+	return rows, n
+}
+
+// bodyFontSize is the font size most characters on the page are set in.
+func bodyFontSize(boxes []pdfminerTextBox) float64 {
+	counts := make(map[float64]int)
+	for _, box := range boxes {
+		for _, line := range box.Lines {
+			for _, c := range line.Chars {
+				if c.Size > 0 {
+					counts[roundSize(c.Size)]++
+				}
+			}
+		}
+	}
+	body, best := 0.0, 0
+	for size, n := range counts {
+		if n > best || (n == best && size < body) {
+			body, best = size, n
+		}
+	}
+	return body
+}
+
+// fontStats returns the mean font size of the box and the shares of its
+// characters set in monospace and math fonts.
+func (b pdfminerTextBox) fontStats() (size, monospace, math float64) {
+	var total, mono, mathChars int
+	var sum float64
+	for _, line := range b.Lines {
+		for _, c := range line.Chars {
+			if c.Size <= 0 || strings.TrimSpace(c.Text) == "" {
+				continue
+			}
+			total++
+			sum += c.Size
+			font := strings.ToLower(c.Font)
+			if containsAny(font, monospaceFonts) {
+				mono++
+			}
+			if containsAny(font, mathFonts) {
+				mathChars++
+			}
+		}
+	}
+	if total == 0 {
+		return 0, 0, 0
+	}
+	return roundSize(sum / float64(total)), float64(mono) / float64(total), float64(mathChars) / float64(total)
+}
+
+// lineTexts returns the text of each line in the box.
+func (b pdfminerTextBox) lineTexts() []string {
+	lines := make([]string, 0, len(b.Lines))
+	for _, line := range b.Lines {
+		var sb strings.Builder
+		for _, c := range line.Chars {
+			sb.WriteString(c.Text)
+		}
+		lines = append(lines, strings.TrimRight(sb.String(), " \n"))
+	}
+	return lines
+}
+
+// mathShare is the share of non-space characters that are math symbols or
+// Greek letters.
+func mathShare(text string) float64 {
+	var total, mathChars int
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.Is(unicode.Sm, r) || unicode.Is(unicode.Greek, r) {
+			mathChars++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(mathChars) / float64(total)
+}
+
+// sectionsFromHeadings builds a section tree from heading blocks, for
+// documents without an outline.
+func sectionsFromHeadings(blocks []models.Block) []models.Section {
+	var headings []models.Section
+	for _, b := range blocks {
+		if b.Type == models.BlockHeading {
+			headings = append(headings, models.Section{Title: b.Text, Level: b.Level, Page: b.Page, Top: b.BBox.Y1})
+		}
+	}
+	sections, _ := nestSections(headings, 0, 0)
+	renumberLevels(sections, 1)
+	return sections
+}
+
+// nestSections nests the headings from i on that are deeper than
+// parentLevel, and returns them with the index of the first heading left.
+func nestSections(headings []models.Section, i int, parentLevel int) ([]models.Section, int) {
+	var sections []models.Section
+	for i < len(headings) && headings[i].Level > parentLevel {
+		section := headings[i]
+		section.Children, i = nestSections(headings, i+1, section.Level)
+		sections = append(sections, section)
+	}
+	return sections, i
+}
+
+// renumberLevels makes levels follow nesting depth, so a document whose
+// top headings are all level 2 still starts at level 1.
+func renumberLevels(sections []models.Section, level int) {
+	for i := range sections {
+		sections[i].Level = level
+		renumberLevels(sections[i].Children, level+1)
+	}
+}
+
+// tablesFromBlocks lists the table blocks as tables.
+func tablesFromBlocks(blocks []models.Block) []models.Table {
+	var tables []models.Table
+	for _, b := range blocks {
+		if b.Type == models.BlockTable {
+			tables = append(tables, models.Table{Page: b.Page, Data: b.Rows})
+		}
+	}
+	return tables
+}
+
+// placeFigures copies positions and captions from the figure blocks onto
+// the extracted images. Images are matched to figure blocks in order on
+// each page, and only on pages where their counts agree.
+func placeFigures(figures []models.Figure, blocks []models.Block) {
+	byPage := make(map[int][]models.Block)
+	for _, b := range blocks {
+		if b.Type == models.BlockFigure {
+			byPage[b.Page] = append(byPage[b.Page], b)
+		}
+	}
+	images := make(map[int][]int)
+	for i, f := range figures {
+		images[f.Page] = append(images[f.Page], i)
+	}
+
+	pages := make([]int, 0, len(images))
+	for page := range images {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+	for _, page := range pages {
+		if len(images[page]) != len(byPage[page]) {
+			continue
+		}
+		for j, i := range images[page] {
+			figures[i].BBox = byPage[page][j].BBox
+			figures[i].Caption = byPage[page][j].Caption
+		}
+	}
+}
+
+// parseBBox parses a pdfminer bbox attribute, "x0,y0,x1,y1".
+func parseBBox(s string) (models.BoundingBox, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return models.BoundingBox{}, false
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return models.BoundingBox{}, false
+		}
+		v[i] = f
+	}
+	return normalizedBox(v[0], v[1], v[2], v[3]), true
+}
+
+func boxBounds(box pdfminerTextBox) models.BoundingBox {
+	return normalizedBox(box.X0, box.Y0, box.X1, box.Y1)
+}
+
+func unionBox(a, b models.BoundingBox) models.BoundingBox {
+	return models.BoundingBox{X0: min(a.X0, b.X0), Y0: min(a.Y0, b.Y0), X1: max(a.X1, b.X1), Y1: max(a.Y1, b.Y1)}
+}
+
+// roundSize rounds a font size to a tenth of a point so sizes that differ
+// only by rounding in the PDF compare equal.
+func roundSize(size float64) float64 {
+	return float64(int(size*10+0.5)) / 10
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
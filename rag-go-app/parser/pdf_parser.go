@@ -2,19 +2,21 @@ package parser
 
 import (
 	"bytes"
-	"encoding/xml"
+	"context"
+	"errors"
 	"fmt"
+	"image"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"log"
-	"math"
-	"os"
-	"os/exec"
 	"strings"
+	"unicode/utf8"
 
 	"rag-go-app/models"
 
 	"github.com/otiai10/gosseract/v2"
+	"github.com/unidoc/unidoc/v3/pdf/extractor"
 	"github.com/unidoc/unidoc/v3/pdf/model"
 )
 
@@ -22,21 +24,71 @@ type PDFParser struct {
 	OCRLanguage string
 }
 
+func (p *PDFParser) Name() string    { return "pdf" }
+func (p *PDFParser) Version() string { return "1.3.0" }
+
 func (p *PDFParser) SupportedContentTypes() []string {
 	return []string{"application/pdf"}
 }
 
+// PasswordRequiredError is returned when a PDF is encrypted and cannot be
+// opened with the empty user password or the password supplied with it.
+type PasswordRequiredError struct {
+	// PasswordSupplied reports whether a password was given and rejected.
+	PasswordSupplied bool
+}
+
+func (e *PasswordRequiredError) Error() string {
+	if e.PasswordSupplied {
+		return "PDF is encrypted and the supplied password is incorrect"
+	}
+	return "PDF is encrypted and requires a password"
+}
+
 func (p *PDFParser) Parse(data []byte) (*models.Document, error) {
+	return p.ParseWithPassword(data, "")
+}
+
+// ParseWithPassword parses a PDF that may be encrypted under DefaultLimits.
+// The empty user password is always tried first, so password may be empty.
+func (p *PDFParser) ParseWithPassword(data []byte, password string) (*models.Document, error) {
+	limits := DefaultLimits()
+	ctx, cancel := context.WithTimeout(context.Background(), limits.Timeout)
+	defer cancel()
+	return p.ParseWithOptions(ctx, data, ParseOptions{Password: password, Limits: limits})
+}
+
+// ParseWithOptions parses a PDF within opts.Limits, stopping between pages
+// once ctx is done. Temp files are removed before it returns.
+func (p *PDFParser) ParseWithOptions(ctx context.Context, data []byte, opts ParseOptions) (*models.Document, error) {
+	return p.ParseStream(ctx, bytes.NewReader(data), int64(len(data)), opts, nil)
+}
+
+// ParseStream parses a PDF read in place from r, calling emit with each page
+// as soon as its text is extracted. The file is never loaded into memory as
+// a whole; when r is an *os.File, pdfminer reads the file directly instead
+// of a temp copy. The returned document holds everything emitted plus the
+// document-level metadata, outline, tables and annotations.
+func (p *PDFParser) ParseStream(ctx context.Context, r io.ReaderAt, size int64, opts ParseOptions, emit func(models.Page) error) (*models.Document, error) {
+	if err := opts.Limits.checkInputSize(size); err != nil {
+		return nil, err
+	}
+	session := newParseSession(ctx, r, size, opts)
+	defer session.cleanup()
+
 	doc := &models.Document{}
-	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	reader, err := openPDF(io.NewSectionReader(r, 0, size), opts.Password)
 	if err != nil {
-		return nil, fmt.Errorf("creating PDF reader failed: %w", err)
+		return nil, err
 	}
 
 	numPages, err := reader.GetNumPages()
 	if err != nil {
 		return nil, fmt.Errorf("getting number of pages failed: %w", err)
 	}
+	if err := opts.Limits.checkPages(numPages); err != nil {
+		return nil, err
+	}
 
 	// Extract metadata
 	metadata, err := extractMetadata(reader)
@@ -45,104 +97,229 @@ func (p *PDFParser) Parse(data []byte) (*models.Document, error) {
 	}
 	doc.Metadata = metadata
 
+	// Build the section tree from the bookmarks, if any
+	sections, err := extractOutline(reader, numPages)
+	if err != nil {
+		log.Printf("Outline extraction failed: %v", err)
+	}
+
 	// Process each page
+	var pages []models.Page
+	var extraction []models.PageExtraction
+	var annotations []models.Annotation
+	var hyperlinks []models.Hyperlink
 	for i := 1; i <= numPages; i++ {
+		if err := session.err(); err != nil {
+			return nil, err
+		}
 		page, err := reader.GetPage(i)
 		if err != nil {
 			return nil, fmt.Errorf("getting page %d failed: %w", i, err)
 		}
 
-		text, err := extractTextFromPage(page, p.OCRLanguage, data)
+		text, report, err := extractTextFromPage(session, page, i, p.OCRLanguage)
 		if err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				return nil, err
+			}
 			log.Printf("Text extraction failed for page %d: %v", i, err)
 		}
-		doc.Text += text
-
-		tables, err := extractTables(data)
-		if err != nil {
-			log.Printf("Table extraction failed: %v", err)
+		pages = append(pages, models.Page{Number: i, Text: text})
+		extraction = append(extraction, report)
+		if emit != nil {
+			if err := emit(pages[len(pages)-1]); err != nil {
+				return nil, err
+			}
 		}
-		doc.Metadata.Tables = append(doc.Metadata.Tables, tables...)
 
 		figures, err := extractFigures(page)
 		if err != nil {
 			log.Printf("Figure extraction failed: %v", err)
 		}
+		for j := range figures {
+			figures[j].Page = i
+		}
 		doc.Metadata.Figures = append(doc.Metadata.Figures, figures...)
+
+		pageAnnotations, pageLinks, err := extractAnnotations(page, i)
+		if err != nil {
+			log.Printf("Annotation extraction failed for page %d: %v", i, err)
+		}
+		annotations = append(annotations, pageAnnotations...)
+		hyperlinks = append(hyperlinks, pageLinks...)
+	}
+
+	// Lay out each page into typed blocks and resolve the text under its
+	// annotations. This needs pdfminer's layout of the whole file, so pages
+	// already emitted carry only their text.
+	for i := range pages {
+		layout, ok, err := session.layoutPage(pages[i].Number)
+		if err != nil {
+			log.Printf("Layout analysis failed: %v", err)
+			break
+		}
+		if ok {
+			applyLayout(&pages[i], layout, extraction[i].Extractor)
+			fillAnnotatedText(layout, annotations, hyperlinks)
+		}
+	}
+	doc.Pages = pages
+	doc.DeriveText()
+
+	blocks := doc.Blocks()
+	doc.Metadata.Tables = append(doc.Metadata.Tables, tablesFromBlocks(blocks)...)
+	placeFigures(doc.Metadata.Figures, blocks)
+	if len(sections) == 0 {
+		sections = sectionsFromHeadings(blocks)
+	}
+
+	if err := session.err(); err != nil {
+		return nil, err
 	}
 
-	newDoc, err := models.NewDocument(0, doc.Text, doc.Metadata, nil)
+	newDoc, err := models.NewDocument(0, doc.Text, doc.Metadata)
 	if err != nil {
 		return nil, err
 	}
+	newDoc.Pages = doc.Pages
+	newDoc.Sections = sections
+	newDoc.Extraction = extraction
+	newDoc.Annotations = annotations
+	newDoc.Hyperlinks = hyperlinks
+	tagLanguages(newDoc)
 	return newDoc, nil
 }
 
-func extractTextFromPage(page *model.PdfPage, language string, pdfData []byte) (string, error) {
+// openPDF creates a reader for rs, decrypting it first if needed.
+func openPDF(rs io.ReadSeeker, password string) (*model.PdfReader, error) {
+	reader, err := model.NewPdfReader(rs)
+	if err != nil {
+		return nil, fmt.Errorf("creating PDF reader failed: %w", err)
+	}
+
+	encrypted, err := reader.IsEncrypted()
+	if err != nil {
+		return nil, fmt.Errorf("checking PDF encryption failed: %w", err)
+	}
+	if !encrypted {
+		return reader, nil
+	}
+
+	// Many library downloads are encrypted only to restrict printing and
+	// open with an empty user password.
+	candidates := [][]byte{[]byte("")}
+	if password != "" {
+		candidates = append(candidates, []byte(password))
+	}
+	for _, candidate := range candidates {
+		ok, err := reader.Decrypt(candidate)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting PDF failed: %w", err)
+		}
+		if ok {
+			return reader, nil
+		}
+	}
+	return nil, &PasswordRequiredError{PasswordSupplied: password != ""}
+}
+
+// extractTextFromPage tries UniDoc, then pdfminer, then OCR, and records
+// which one produced the page text and why the others were skipped.
+func extractTextFromPage(session *parseSession, page *model.PdfPage, pageNum int, language string) (string, models.PageExtraction, error) {
+	report := models.PageExtraction{Page: pageNum}
+	images, imagesErr := page.GetImages()
+	nativeChars := 0
+
+	accept := func(extractor, text string) (string, models.PageExtraction, error) {
+		report.Extractor = extractor
+		report.Chars = utf8.RuneCountInString(text)
+		report.ScannedScore = scannedScore(nativeChars, len(images))
+		report.GarbledScore = garbledScore(text)
+		return text, report, nil
+	}
+	reject := func(extractor string, text string, err error) {
+		report.Failures = append(report.Failures, models.ExtractorFailure{Extractor: extractor, Reason: err.Error()})
+		if !errors.Is(err, errContentStream) {
+			nativeChars = max(nativeChars, utf8.RuneCountInString(text))
+		}
+	}
+
 	// Extract text using UniDoc
 	text, err := extractTextUnidoc(page)
-	if err == nil && len(text) > 10 {
-		return text, nil
+	if err == nil {
+		err = checkExtractedText(text)
+	}
+	if err == nil {
+		nativeChars = utf8.RuneCountInString(text)
+		return accept(models.ExtractorUnidoc, text)
 	}
+	reject(models.ExtractorUnidoc, text, err)
 
 	// Fallback to pdfminer.six
-	text, err = extractTextPdfminer(pdfData)
-	if err == nil && len(text) > 10 {
-		return text, nil
+	text, err = extractTextPdfminer(session, pageNum)
+	if err == nil {
+		err = checkExtractedText(text)
 	}
+	if err == nil {
+		nativeChars = utf8.RuneCountInString(text)
+		return accept(models.ExtractorPdfminer, text)
+	}
+	reject(models.ExtractorPdfminer, text, err)
 
 	// Fallback to OCR
-	images, err := page.GetImages()
-	if err != nil {
-		return "", fmt.Errorf("getting images for OCR failed: %w", err)
+	report.ScannedScore = scannedScore(nativeChars, len(images))
+	if imagesErr != nil {
+		err := fmt.Errorf("getting images for OCR failed: %w", imagesErr)
+		report.Failures = append(report.Failures, models.ExtractorFailure{Extractor: models.ExtractorOCR, Reason: err.Error()})
+		return "", report, err
 	}
-	text, err = extractTextOCR(images, language)
+	text, confidence, err := extractTextOCR(session, images, language)
 	if err != nil {
-		return "", fmt.Errorf("OCR failed: %w", err)
+		err = fmt.Errorf("OCR failed: %w", err)
+		report.Failures = append(report.Failures, models.ExtractorFailure{Extractor: models.ExtractorOCR, Reason: err.Error()})
+		return "", report, err
 	}
-	return text, nil
+	report.OCRConfidence = confidence
+	return accept(models.ExtractorOCR, text)
 }
 
+// extractTextUnidoc returns the text UniDoc decodes from the page's content
+// streams.
 func extractTextUnidoc(page *model.PdfPage) (string, error) {
-	contentStreams, err := page.GetContentStreams()
+	ex, err := extractor.New(page)
 	if err != nil {
-		return "", fmt.Errorf("failed to get content streams: %w", err)
+		return "", fmt.Errorf("creating text extractor failed: %w", err)
 	}
-	var textContent strings.Builder
-	for _, stream := range contentStreams {
-		textContent.WriteString(stream) // Custom parsing logic may be required
+	text, err := ex.ExtractText()
+	if err != nil {
+		return "", fmt.Errorf("extracting text failed: %w", err)
 	}
-	return textContent.String(), nil
+	return text, nil
 }
 
-func extractTextPdfminer(pdfData []byte) (string, error) {
-	tmpFile, err := os.CreateTemp("", "temp.pdf")
+// extractTextPdfminer returns the text of page pageNum from pdfminer's
+// layout of the file, with a blank line between text boxes.
+func extractTextPdfminer(session *parseSession, pageNum int) (string, error) {
+	page, ok, err := session.layoutPage(pageNum)
 	if err != nil {
-		return "", fmt.Errorf("creating temp file: %w", err)
-	}
-	defer os.Remove(tmpFile.Name())
-
-	if _, err := tmpFile.Write(pdfData); err != nil {
-		return "", fmt.Errorf("writing to temp file: %w", err)
+		return "", err
 	}
-	if err := tmpFile.Close(); err != nil {
-		return "", fmt.Errorf("closing temp file failed: %w", err)
+	if !ok {
+		return "", fmt.Errorf("pdfminer layout has no page %d", pageNum)
 	}
-
-	cmd := exec.Command("pdf2txt.py", "-t", "xml", tmpFile.Name())
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-	err = cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("pdf2txt.py execution error: %w, stderr: %s", err, stderr.String())
+	var texts []string
+	for _, box := range page.TextBoxes {
+		if text := strings.TrimSpace(box.Text); text != "" {
+			texts = append(texts, text)
+		}
 	}
-
-	return out.String(), nil
+	return strings.Join(texts, "\n\n"), nil
 }
 
-func extractTextOCR(images []*model.PdfImage, language string) (string, error) {
+// extractTextOCR runs Tesseract over the page images and returns the text with
+// the mean word confidence (0-100) across all images.
+func extractTextOCR(session *parseSession, images []*model.PdfImage, language string) (string, float64, error) {
 	client := gosseract.NewClient()
 	defer client.Close()
 
@@ -154,54 +331,73 @@ func extractTextOCR(images []*model.PdfImage, language string) (string, error) {
 	client.SetLanguage(language)
 
 	var allText strings.Builder
+	var confidenceSum float64
+	var words int
 	for _, img := range images {
 		imgData, err := img.GetData()
 		if err != nil {
-			return "", fmt.Errorf("error getting image data: %w", err)
+			return "", 0, fmt.Errorf("error getting image data: %w", err)
 		}
-		tmpFile, err := createTempImageFile(imgData, img.Fmt)
+		imagePath, err := createTempImageFile(session, imgData, img.Fmt)
 		if err != nil {
-			return "", fmt.Errorf("error creating temp image file: %w", err)
+			return "", 0, fmt.Errorf("error creating temp image file: %w", err)
 		}
-		defer os.Remove(tmpFile.Name())
 
-		client.SetImage(tmpFile.Name())
+		client.SetImage(imagePath)
 		text, err := client.Text()
 		if err != nil {
-			return "", fmt.Errorf("OCR processing failed: %w", err)
+			return "", 0, fmt.Errorf("OCR processing failed: %w", err)
 		}
 		allText.WriteString(text + "\n")
+
+		boxes, err := client.GetBoundingBoxes(gosseract.RIL_WORD)
+		if err != nil {
+			log.Printf("Reading OCR word confidence failed: %v", err)
+			continue
+		}
+		for _, box := range boxes {
+			confidenceSum += box.Confidence
+			words++
+		}
 	}
-	return allText.String(), nil
-}
 
-func createTempImageFile(imgData []byte, imgFmt model.ImageFormat) (*os.File, error) {
-	tmpFile, err := os.CreateTemp("", "tempImage.*."+getImageExtension(imgFmt))
-	if err != nil {
-		return nil, err
+	confidence := 0.0
+	if words > 0 {
+		confidence = confidenceSum / float64(words)
 	}
-	defer os.Remove(tmpFile.Name())
+	return allText.String(), confidence, nil
+}
 
+// createTempImageFile validates an embedded image and writes it to a temp
+// file owned by the session. Image dimensions are checked before decoding so
+// a tiny image claiming huge dimensions cannot exhaust memory.
+func createTempImageFile(session *parseSession, imgData []byte, imgFmt model.ImageFormat) (string, error) {
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decode func(io.Reader) (image.Image, error)
 	switch imgFmt {
 	case model.ImageFormatJPEG:
-		if _, err := jpeg.Decode(bytes.NewReader(imgData)); err != nil {
-			return nil, fmt.Errorf("decoding JPEG image failed: %v", err)
-		}
+		decodeConfig, decode = jpeg.DecodeConfig, jpeg.Decode
 	case model.ImageFormatPNG:
-		if _, err := png.Decode(bytes.NewReader(imgData)); err != nil {
-			return nil, fmt.Errorf("decoding PNG image failed: %v", err)
-		}
+		decodeConfig, decode = png.DecodeConfig, png.Decode
 	default:
-		return nil, fmt.Errorf("unsupported image format: %v", imgFmt)
+		return "", fmt.Errorf("unsupported image format: %v", imgFmt)
 	}
 
-	if _, err := tmpFile.Write(imgData); err != nil {
-		return nil, fmt.Errorf("writing image data to temporary file failed: %v", err)
+	cfg, err := decodeConfig(bytes.NewReader(imgData))
+	if err != nil {
+		return "", fmt.Errorf("decoding image header failed: %v", err)
+	}
+	// Decoded images take up to 4 bytes per pixel.
+	if maxBytes := session.limits.MaxDecompressedBytes; maxBytes > 0 {
+		if pixels := int64(cfg.Width) * int64(cfg.Height) * 4; pixels > maxBytes {
+			return "", &LimitError{Limit: LimitDecompressedBytes, Value: pixels, Max: maxBytes}
+		}
 	}
-	if err := tmpFile.Close(); err != nil {
-		return nil, fmt.Errorf("closing temporary file failed: %v", err)
+	if _, err := decode(bytes.NewReader(imgData)); err != nil {
+		return "", fmt.Errorf("decoding image failed: %v", err)
 	}
-	return tmpFile, nil
+
+	return session.writeTemp("tempImage.*."+getImageExtension(imgFmt), imgData)
 }
 
 func getImageExtension(imgFmt model.ImageFormat) string {
@@ -215,103 +411,63 @@ func getImageExtension(imgFmt model.ImageFormat) string {
 	}
 }
 
-func extractTables(pdfData []byte) ([]models.Table, error) {
-	tmpFile, err := os.CreateTemp("", "temp.pdf")
-	if err != nil {
-		return nil, fmt.Errorf("creating temp file: %w", err)
-	}
-	defer os.Remove(tmpFile.Name())
-
-	if _, err := tmpFile.Write(pdfData); err != nil {
-		return nil, fmt.Errorf("writing to temp file: %w", err)
-	}
-	if err := tmpFile.Close(); err != nil {
-		return nil, fmt.Errorf("closing temp file failed: %w", err)
-	}
-
-	cmd := exec.Command("pdf2txt.py", "-t", "xml", tmpFile.Name())
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-	err = cmd.Run()
-	if err != nil {
-		return nil, fmt.Errorf("pdf2txt.py execution error: %w, stderr: %s", err, stderr.String())
-	}
+// pdfminerTextBox is a text box from pdf2txt.py's XML layout output. The
+// XML gives the box as a bbox attribute and its text as lines of
+// characters; fillTextBoxes fills in the coordinates and Text from them.
+type pdfminerTextBox struct {
+	BBox  string             `xml:"bbox,attr"`
+	Lines []pdfminerTextLine `xml:"textline"`
 
-	tables, err := parseTablesFromXML(out.Bytes())
-	if err != nil {
-		return nil, fmt.Errorf("parsing tables from XML: %w", err)
-	}
-	return tables, nil
+	Text           string  `xml:"-"`
+	X0, Y0, X1, Y1 float64 `xml:"-"`
 }
 
-func parseTablesFromXML(xmlData []byte) ([]models.Table, error) {
-	type LTTextBoxHorizontal struct {
-		Text string  `xml:",chardata"`
-		X0   float64 `xml:"x0,attr"`
-		Y0   float64 `xml:"y0,attr"`
-		X1   float64 `xml:"x1,attr"`
-		Y1   float64 `xml:"y1,attr"`
-	}
-
-	type LTPage struct {
-		TextBoxes []LTTextBoxHorizontal `xml:"textbox"`
-	}
-
-	type XMLOutput struct {
-		Pages []LTPage `xml:"page"`
-	}
+type pdfminerTextLine struct {
+	Chars []pdfminerChar `xml:"text"`
+}
 
-	var output XMLOutput
-	err := xml.Unmarshal(xmlData, &output)
-	if err != nil {
-		return nil, fmt.Errorf("XML parsing failed: %w", err)
-	}
+// pdfminerChar is one character. Spaces and line breaks inserted by the
+// layout analysis have no font or size.
+type pdfminerChar struct {
+	Text string  `xml:",chardata"`
+	Font string  `xml:"font,attr"`
+	Size float64 `xml:"size,attr"`
+}
 
-	var tables []models.Table
-	for _, page := range output.Pages {
-		var currentTable models.Table
-		var currentRow []string
-		prevY := -1000.0 // Initialize with a very small value
+type pdfminerFigure struct {
+	Name string `xml:"name,attr"`
+	BBox string `xml:"bbox,attr"`
+}
 
-		for _, box := range page.TextBoxes {
-			if math.Abs(box.Y0-prevY) > 15 {
-				if len(currentRow) > 0 {
-					currentTable.Data = append(currentTable.Data, currentRow)
-					currentRow = []string{}
-				}
-			}
-			currentRow = append(currentRow, box.Text)
-			prevY = box.Y0
-		}
+type pdfminerPage struct {
+	ID        int               `xml:"id,attr"`
+	BBox      string            `xml:"bbox,attr"`
+	TextBoxes []pdfminerTextBox `xml:"textbox"`
+	Figures   []pdfminerFigure  `xml:"figure"`
+}
 
-		if len(currentRow) > 0 {
-			currentTable.Data = append(currentTable.Data, currentRow)
+// fillTextBoxes sets the coordinates and text of each text box on page from
+// its bbox attribute and lines.
+func fillTextBoxes(page *pdfminerPage) {
+	for j := range page.TextBoxes {
+		box := &page.TextBoxes[j]
+		if bbox, ok := parseBBox(box.BBox); ok {
+			box.X0, box.Y0, box.X1, box.Y1 = bbox.X0, bbox.Y0, bbox.X1, bbox.Y1
 		}
-		if len(currentTable.Data) > 0 {
-			tables = append(tables, currentTable)
+		if len(box.Lines) > 0 {
+			box.Text = strings.Join(box.lineTexts(), "\n")
 		}
 	}
-	return tables, nil
 }
 
+// extractFigures returns a figure for each image on the page. The image
+// data is not kept: only where figures are and what they show is used.
 func extractFigures(page *model.PdfPage) ([]models.Figure, error) {
 	images, err := page.GetImages()
 	if err != nil {
 		return nil, err
 	}
-
-	var figures []models.Figure
-	for _, img := range images {
-		imgData, err := img.GetData()
-		if err != nil {
-			log.Printf("Error getting image data: %v", err)
-			continue // Skip this image
-		}
-		figures = append(figures, models.Figure{ImageData: imgData})
-	}
-	return figures, nil
+	return make([]models.Figure, len(images)), nil
 }
 
 func extractMetadata(reader *model.PdfReader) (models.Metadata, error) {
@@ -320,9 +476,56 @@ func extractMetadata(reader *model.PdfReader) (models.Metadata, error) {
 		return models.Metadata{}, fmt.Errorf("failed to extract metadata: %w", err)
 	}
 
+	// The Author entry often holds the whole author list in one string
+	var authors []models.Author
+	for _, name := range pdfInfo.Authors {
+		parsed, _ := models.ParseAuthors(name)
+		authors = append(authors, parsed...)
+	}
+
 	return models.Metadata{
 		Title:    pdfInfo.Title,
-		Authors:  pdfInfo.Authors,
+		Authors:  authors,
 		Abstract: pdfInfo.Subject,
 	}, nil
 }
+
+// extractOutline converts the PDF bookmark tree into nested sections.
+// Entries whose destination is not a page in the document keep the page of
+// the entry before them so the tree stays in reading order.
+func extractOutline(reader *model.PdfReader, numPages int) ([]models.Section, error) {
+	outline, err := reader.GetOutlines()
+	if err != nil {
+		return nil, fmt.Errorf("reading outline failed: %w", err)
+	}
+	if outline == nil {
+		return nil, nil
+	}
+
+	lastPage := 1
+	var build func(items []*model.OutlineItem, level int) []models.Section
+	build = func(items []*model.OutlineItem, level int) []models.Section {
+		var sections []models.Section
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			// Outline destinations use 0-based page indexes.
+			page := int(item.Dest.Page) + 1
+			if page < 1 || page > numPages {
+				page = lastPage
+			}
+			lastPage = page
+
+			sections = append(sections, models.Section{
+				Title:    strings.TrimSpace(item.Title),
+				Level:    level,
+				Page:     page,
+				Top:      item.Dest.Y,
+				Children: build(item.Entries, level+1),
+			})
+		}
+		return sections
+	}
+	return build(outline.Entries, 1), nil
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"rag-go-app/models"
+)
+
+// testPDF builds an unencrypted PDF with one page of Helvetica text per
+// entry of pages.
+func testPDF(pages ...string) []byte {
+	// objects 1 and 2 are the catalog and page tree, 3 the font, then a
+	// page and its content stream for each page
+	objects := []string{"", "", "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"}
+	var kids []string
+	for _, text := range pages {
+		pageID := len(objects) + 1
+		stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageID+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+	}
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func TestPDFParserParseStream(t *testing.T) {
+	data := testPDF("The first page of the handout.", "The second page of the handout.")
+
+	var emitted []models.Page
+	doc, err := (&PDFParser{}).ParseStream(context.Background(), bytes.NewReader(data), int64(len(data)), ParseOptions{Limits: DefaultLimits()}, func(page models.Page) error {
+		emitted = append(emitted, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first page", "second page"}
+	if len(emitted) != len(want) || len(doc.Pages) != len(want) {
+		t.Fatalf("emitted %d pages and returned %d, want %d", len(emitted), len(doc.Pages), len(want))
+	}
+	for i, page := range emitted {
+		if page.Number != i+1 || !strings.Contains(page.Text, want[i]) {
+			t.Errorf("emitted page %d = %+v, want page %d containing %q", i, page, i+1, want[i])
+		}
+		if !strings.Contains(doc.Pages[i].Text, want[i]) {
+			t.Errorf("returned page %d text = %q, want it to contain %q", i+1, doc.Pages[i].Text, want[i])
+		}
+	}
+	if len(doc.Extraction) != 2 || doc.Extraction[0].Extractor != models.ExtractorUnidoc {
+		t.Errorf("extraction = %+v, want two pages extracted by unidoc", doc.Extraction)
+	}
+}
+
+func TestPDFParserParseStreamStops(t *testing.T) {
+	data := testPDF("The first page of the handout.", "The second page of the handout.")
+
+	tests := []struct {
+		name        string
+		limits      Limits
+		emitErr     error
+		wantEmitted int
+		wantErr     func(error) bool
+	}{
+		{
+			name:    "input too large",
+			limits:  Limits{MaxInputBytes: int64(len(data)) - 1},
+			wantErr: isLimitError,
+		},
+		{
+			name:    "too many pages",
+			limits:  Limits{MaxPages: 1},
+			wantErr: isLimitError,
+		},
+		{
+			name:        "emit error",
+			emitErr:     errParse,
+			wantEmitted: 1,
+			wantErr:     isParseError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emitted := 0
+			_, err := (&PDFParser{}).ParseStream(context.Background(), bytes.NewReader(data), int64(len(data)), ParseOptions{Limits: tt.limits}, func(models.Page) error {
+				emitted++
+				return tt.emitErr
+			})
+			if !tt.wantErr(err) {
+				t.Errorf("unexpected err %v", err)
+			}
+			if emitted != tt.wantEmitted {
+				t.Errorf("emitted %d pages, want %d", emitted, tt.wantEmitted)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rag-go-app/models"
+)
+
+// External parser plugins are executables that speak a small JSON protocol,
+// for formats handled by tools that cannot be linked into Go.
+//
+// Handshake: the service runs `<plugin> handshake` and expects one JSON
+// object on stdout:
+//
+//	{"protocol_version": 1, "name": "mathpix", "version": "2.3.0",
+//	 "content_types": ["application/pdf"], "priority": 50}
+//
+// Parse: the service runs `<plugin> parse <content-type>` with the file
+// bytes on stdin and expects one JSON object on stdout:
+//
+//	{"text": "...", "metadata": {...}, "sections": [...],
+//	 "tables": [{"page": 1, "data": [["a", "b"]]}], "warnings": ["..."]}
+//
+// A plugin reports a failure by exiting non-zero or by setting "error" in
+// the parse output. Anything written to stderr is included in the error.
+
+// PluginProtocolVersion is the protocol version this service speaks.
+const PluginProtocolVersion = 1
+
+// PluginsEnvVar lists plugin executables for LoadConfiguredPlugins,
+// separated by the OS path list separator.
+const PluginsEnvVar = "PARSER_PLUGINS"
+
+// pluginHandshake is the reply to `<plugin> handshake`.
+type pluginHandshake struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	ContentTypes    []string `json:"content_types"`
+	Priority        int      `json:"priority"`
+}
+
+// pluginOutput is the reply to `<plugin> parse`.
+type pluginOutput struct {
+	Text     string           `json:"text"`
+	Metadata models.Metadata  `json:"metadata"`
+	Sections []models.Section `json:"sections"`
+	Tables   []models.Table   `json:"tables"`
+	Warnings []string         `json:"warnings"`
+	Error    string           `json:"error"`
+}
+
+// PluginParser runs an external executable as a Parser.
+type PluginParser struct {
+	path      string
+	handshake pluginHandshake
+}
+
+// pluginHandshakeTimeout bounds how long a plugin may take to answer the
+// handshake.
+const pluginHandshakeTimeout = 10 * time.Second
+
+// NewPluginParser performs the handshake with the executable at path.
+func NewPluginParser(path string) (*PluginParser, error) {
+	limits := Limits{Timeout: pluginHandshakeTimeout, MaxChildProcesses: 1, MaxDecompressedBytes: 1 << 20}
+	ctx, cancel := context.WithTimeout(context.Background(), limits.Timeout)
+	defer cancel()
+	session := newParseSession(ctx, nil, 0, ParseOptions{Limits: limits})
+	defer session.cleanup()
+
+	out, err := session.run(nil, path, "handshake")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s handshake failed: %w", path, err)
+	}
+
+	var hs pluginHandshake
+	if err := json.Unmarshal(out, &hs); err != nil {
+		return nil, fmt.Errorf("plugin %s returned an invalid handshake: %w", path, err)
+	}
+	if hs.ProtocolVersion != PluginProtocolVersion {
+		return nil, fmt.Errorf("plugin %s speaks protocol version %d, want %d", path, hs.ProtocolVersion, PluginProtocolVersion)
+	}
+	if hs.Name == "" || len(hs.ContentTypes) == 0 {
+		return nil, fmt.Errorf("plugin %s handshake must include a name and content types", path)
+	}
+	if hs.Version == "" {
+		hs.Version = "0"
+	}
+	return &PluginParser{path: path, handshake: hs}, nil
+}
+
+func (p *PluginParser) Name() string    { return p.handshake.Name }
+func (p *PluginParser) Version() string { return p.handshake.Version }
+
+// Priority is the registry priority the plugin asked for in its handshake.
+func (p *PluginParser) Priority() int { return p.handshake.Priority }
+
+func (p *PluginParser) SupportedContentTypes() []string {
+	return p.handshake.ContentTypes
+}
+
+// Parse sends data to the plugin under DefaultLimits. Plugins registered
+// for several content types receive the first one.
+func (p *PluginParser) Parse(data []byte) (*models.Document, error) {
+	return p.ParseContentType(p.handshake.ContentTypes[0], data)
+}
+
+// ParseContentType sends data to the plugin under DefaultLimits, telling it
+// the content type.
+func (p *PluginParser) ParseContentType(contentType string, data []byte) (*models.Document, error) {
+	return p.ParseWithOptions(context.Background(), data, ParseOptions{ContentType: contentType, Limits: DefaultLimits()})
+}
+
+// ParseWithOptions sends data to the plugin. The plugin process is killed
+// when ctx is done.
+func (p *PluginParser) ParseWithOptions(ctx context.Context, data []byte, opts ParseOptions) (*models.Document, error) {
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = p.handshake.ContentTypes[0]
+	}
+
+	session := newParseSession(ctx, bytes.NewReader(data), int64(len(data)), opts)
+	defer session.cleanup()
+
+	out, err := session.run(bytes.NewReader(data), p.path, "parse", contentType)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name(), err)
+	}
+
+	var result pluginOutput
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("plugin %s returned invalid output: %w", p.Name(), err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.Name(), result.Error)
+	}
+
+	metadata := result.Metadata
+	metadata.Tables = append(metadata.Tables, result.Tables...)
+	doc, err := models.NewDocument(0, result.Text, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s returned invalid metadata: %w", p.Name(), err)
+	}
+	doc.Sections = result.Sections
+	doc.Warnings = result.Warnings
+	return doc, nil
+}
+
+// LoadPlugins performs the handshake with each plugin executable and
+// registers it at the priority it asked for. Plugins that fail the handshake
+// are skipped and reported together in the returned error.
+func LoadPlugins(r *Registry, paths []string) error {
+	var errs []error
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		p, err := NewPluginParser(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.Register(p, p.Priority())
+	}
+	return errors.Join(errs...)
+}
+
+// PluginPathsFromEnv returns the plugin executables listed in PARSER_PLUGINS.
+func PluginPathsFromEnv() []string {
+	value := os.Getenv(PluginsEnvVar)
+	if value == "" {
+		return nil
+	}
+	return filepath.SplitList(value)
+}
+
+// LoadConfiguredPlugins registers the plugins listed in PARSER_PLUGINS in
+// r. It starts each plugin for its handshake, so it is called once at
+// startup rather than on import.
+func LoadConfiguredPlugins(r *Registry) error {
+	return LoadPlugins(r, PluginPathsFromEnv())
+}
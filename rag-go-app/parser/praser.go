@@ -1,82 +1,113 @@
 package parser
 
 import (
-	"errors"
+	"context"
+	"io"
+
+	"rag-go-app/models"
 )
 
 // Parser interface defines the methods that a parser must implement.
 type Parser interface {
+	// Name identifies the parser in the registry and in parse caches.
+	Name() string
+	// Version changes whenever the parser's output for the same input may
+	// change.
+	Version() string
 	SupportedContentTypes() []string
-	Parse(data []byte) (string, error)
+	Parse(data []byte) (*models.Document, error)
 }
 
-// Global map to hold registered parsers
-var parsers = make(map[string]Parser)
+// PasswordParser is implemented by parsers that can open password-protected
+// files.
+type PasswordParser interface {
+	Parser
+	ParseWithPassword(data []byte, password string) (*models.Document, error)
+}
 
-// RegisterParser registers a parser for the given content types.
-func RegisterParser(p Parser) {
-	for _, contentType := range p.SupportedContentTypes() {
-		parsers[contentType] = p
-	}
+// ContentTypeParser is implemented by parsers that handle several content
+// types and need to know which one they are given.
+type ContentTypeParser interface {
+	Parser
+	ParseContentType(contentType string, data []byte) (*models.Document, error)
 }
 
-// GetParser retrieves a parser for the specified content type.
-func GetParser(contentType string) (Parser, error) {
-	parser, ok := parsers[contentType]
-	if !ok {
-		return nil, errors.New("no parser found for content type: " + contentType)
-	}
-	return parser, nil
+// ParseOptions carries per-parse settings.
+type ParseOptions struct {
+	// ContentType is the content type the parser was selected for.
+	ContentType string
+	// Password opens encrypted files. It may be empty.
+	Password string
+	// Limits bounds the resources the parse may use.
+	Limits Limits
+}
+
+// OptionsParser is implemented by parsers that honour ParseOptions and stop
+// when ctx is done. Parsers that do not implement it are run through
+// PasswordParser, ContentTypeParser or Parse, with the registry enforcing
+// only the input size and timeout.
+type OptionsParser interface {
+	Parser
+	ParseWithOptions(ctx context.Context, data []byte, opts ParseOptions) (*models.Document, error)
+}
+
+// StreamParser is implemented by parsers that read a file in place instead
+// of from a byte slice, emitting pages as they are parsed. emit may be nil;
+// an error from emit aborts the parse and is returned.
+type StreamParser interface {
+	Parser
+	ParseStream(ctx context.Context, r io.ReaderAt, size int64, opts ParseOptions, emit func(models.Page) error) (*models.Document, error)
 }
 
 // ExtractText extracts text from the given file data and content type.
 func ExtractText(contentType string, data []byte) (string, error) {
-	parser, err := GetParser(contentType)
+	doc, err := defaultRegistry.Parse(contentType, data)
 	if err != nil {
 		return "", err
 	}
-	return parser.Parse(data)
-}
-
-// Example of a PDF parser implementation
-type PDFParser struct{}
-
-func (p *PDFParser) SupportedContentTypes() []string {
-	return []string{"application/pdf"}
-}
-
-func (p *PDFParser) Parse(data []byte) (string, error) {
-	// Implement PDF parsing logic here
-	return "Parsed PDF content", nil
+	return doc.Text, nil
 }
 
 // Example of a DOCX parser implementation
 type DOCXParser struct{}
 
+func (d *DOCXParser) Name() string    { return "docx" }
+func (d *DOCXParser) Version() string { return "0.1.0" }
+
 func (d *DOCXParser) SupportedContentTypes() []string {
 	return []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"}
 }
 
-func (d *DOCXParser) Parse(data []byte) (string, error) {
+func (d *DOCXParser) Parse(data []byte) (*models.Document, error) {
+	return d.ParseWithOptions(context.Background(), data, ParseOptions{Limits: DefaultLimits()})
+}
+
+func (d *DOCXParser) ParseWithOptions(ctx context.Context, data []byte, opts ParseOptions) (*models.Document, error) {
+	if err := opts.Limits.checkZipArchive(data); err != nil {
+		return nil, err
+	}
 	// Implement DOCX parsing logic here
-	return "Parsed DOCX content", nil
+	return models.NewDocument(0, "Parsed DOCX content", models.Metadata{})
 }
 
 // Example of a PPTX parser implementation
 type PPTXParser struct{}
 
+func (p *PPTXParser) Name() string    { return "pptx" }
+func (p *PPTXParser) Version() string { return "0.1.0" }
+
 func (p *PPTXParser) SupportedContentTypes() []string {
 	return []string{"application/vnd.openxmlformats-officedocument.presentationml.presentation"}
 }
 
-func (p *PPTXParser) Parse(data []byte) (string, error) {
-	// Implement PPTX parsing logic here
-	return "Parsed PPTX content", nil
+func (p *PPTXParser) Parse(data []byte) (*models.Document, error) {
+	return p.ParseWithOptions(context.Background(), data, ParseOptions{Limits: DefaultLimits()})
 }
 
-// init function to register parsers
-func init() {
-	RegisterParser(&PDFParser{})
-	RegisterParser(&DOCXParser{})
-	RegisterParser(&PPTXParser{})
+func (p *PPTXParser) ParseWithOptions(ctx context.Context, data []byte, opts ParseOptions) (*models.Document, error) {
+	if err := opts.Limits.checkZipArchive(data); err != nil {
+		return nil, err
+	}
+	// Implement PPTX parsing logic here
+	return models.NewDocument(0, "Parsed PPTX content", models.Metadata{})
 }
@@ -1,32 +1,457 @@
 package parser
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"rag-go-app/models"
+)
+
+// Default priorities. Higher priorities are tried first.
+const (
+	PriorityFallback  = -100
+	PriorityDefault   = 0
+	PriorityPreferred = 100
 )
 
-// Global map to hold registered parsers
-var parsers = make(map[string]Parser)
+// maxFlaggedPageRatio is the share of flagged pages above which
+// DefaultQualityCheck rejects a parse.
+const maxFlaggedPageRatio = 0.5
 
-// RegisterParser registers a parser for the given content types.
-func RegisterParser(p Parser) {
+// ErrLowQuality is returned by quality checks for output that should be
+// retried with the next parser.
+var ErrLowQuality = errors.New("low-quality parser output")
+
+// QualityCheck inspects a parse result and returns an error if the next
+// parser in the chain should be tried instead.
+type QualityCheck func(doc *models.Document) error
+
+// DefaultQualityCheck rejects documents with no text and documents where
+// most pages were flagged by the extraction report.
+func DefaultQualityCheck(doc *models.Document) error {
+	if strings.TrimSpace(doc.Text) == "" {
+		return fmt.Errorf("%w: no text extracted", ErrLowQuality)
+	}
+	if len(doc.Extraction) > 0 {
+		flagged := len(doc.FlaggedPages())
+		if float64(flagged)/float64(len(doc.Extraction)) > maxFlaggedPageRatio {
+			return fmt.Errorf("%w: %d of %d pages flagged", ErrLowQuality, flagged, len(doc.Extraction))
+		}
+	}
+	return nil
+}
+
+// ParserInfo describes a registered parser.
+type ParserInfo struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	ContentTypes []string `json:"content_types"`
+	Priority     int      `json:"priority"`
+}
+
+// Attempt records one parser's failure in a fallback chain.
+type Attempt struct {
+	Parser string
+	Err    error
+}
+
+// ChainError is returned when every parser registered for a content type
+// failed.
+type ChainError struct {
+	ContentType string
+	Attempts    []Attempt
+}
+
+func (e *ChainError) Error() string {
+	parts := make([]string, len(e.Attempts))
+	for i, a := range e.Attempts {
+		parts[i] = a.Parser + ": " + a.Err.Error()
+	}
+	return fmt.Sprintf("all parsers failed for content type %s: %s", e.ContentType, strings.Join(parts, "; "))
+}
+
+func (e *ChainError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, a := range e.Attempts {
+		errs[i] = a.Err
+	}
+	return errs
+}
+
+type registration struct {
+	parser   Parser
+	priority int
+	seq      int
+}
+
+// Registry holds the parsers available for each content type, ordered by
+// priority. Parsers with equal priority are tried in registration order.
+// It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string][]registration
+	seq     int
+	quality QualityCheck
+	limits  Limits
+}
+
+// NewRegistry creates an empty registry using DefaultQualityCheck and
+// DefaultLimits.
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string][]registration),
+		quality: DefaultQualityCheck,
+		limits:  DefaultLimits(),
+	}
+}
+
+// SetLimits sets the limits applied to parses that do not pass their own.
+func (r *Registry) SetLimits(limits Limits) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limits = limits
+}
+
+// Limits returns the registry's default parse limits.
+func (r *Registry) Limits() Limits {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.limits
+}
+
+// SetQualityCheck replaces the check used to decide whether to fall back to
+// the next parser. A nil check accepts any successful parse.
+func (r *Registry) SetQualityCheck(check QualityCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quality = check
+}
+
+// Register adds p for each of its content types at the given priority.
+// Registering the same parser name again for a content type replaces it.
+func (r *Registry) Register(p Parser, priority int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
 	for _, contentType := range p.SupportedContentTypes() {
-		parsers[contentType] = p
+		regs := r.entries[contentType]
+		for i := 0; i < len(regs); i++ {
+			if regs[i].parser.Name() == p.Name() {
+				regs = append(regs[:i], regs[i+1:]...)
+				i--
+			}
+		}
+		regs = append(regs, registration{parser: p, priority: priority, seq: r.seq})
+		sort.SliceStable(regs, func(i, j int) bool {
+			if regs[i].priority != regs[j].priority {
+				return regs[i].priority > regs[j].priority
+			}
+			return regs[i].seq < regs[j].seq
+		})
+		r.entries[contentType] = regs
 	}
 }
 
-// GetParser retrieves a parser for the specified content type.
-func GetParser(contentType string) (Parser, error) {
-	parser, ok := parsers[contentType]
-	if !ok {
+// Parsers returns the parsers for contentType in the order they are tried.
+func (r *Registry) Parsers(contentType string) []Parser {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	regs := r.entries[contentType]
+	parsers := make([]Parser, len(regs))
+	for i, reg := range regs {
+		parsers[i] = reg.parser
+	}
+	return parsers
+}
+
+// Get retrieves the highest-priority parser for contentType.
+func (r *Registry) Get(contentType string) (Parser, error) {
+	parsers := r.Parsers(contentType)
+	if len(parsers) == 0 {
+		return nil, errors.New("no parser found for content type: " + contentType)
+	}
+	return parsers[0], nil
+}
+
+// Identity names the parser chain for contentType and its versions, in the
+// order parsers are tried, e.g. "pdf,mathpix" and "1.0.0,2.1.0". Since any
+// parser in the chain may produce the result, caches key parse results on
+// both so that upgrading or adding a parser invalidates them.
+func (r *Registry) Identity(contentType string) (name string, version string) {
+	parsers := r.Parsers(contentType)
+	names := make([]string, len(parsers))
+	versions := make([]string, len(parsers))
+	for i, p := range parsers {
+		names[i] = p.Name()
+		versions[i] = p.Version()
+	}
+	return strings.Join(names, ","), strings.Join(versions, ",")
+}
+
+// List describes every registered parser, sorted by content type and then
+// by the order parsers are tried.
+func (r *Registry) List() []ParserInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contentTypes := make([]string, 0, len(r.entries))
+	for contentType := range r.entries {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+
+	var infos []ParserInfo
+	for _, contentType := range contentTypes {
+		for _, reg := range r.entries[contentType] {
+			infos = append(infos, ParserInfo{
+				Name:         reg.parser.Name(),
+				Version:      reg.parser.Version(),
+				ContentTypes: []string{contentType},
+				Priority:     reg.priority,
+			})
+		}
+	}
+	return infos
+}
+
+// Parse runs the parser chain for contentType.
+func (r *Registry) Parse(contentType string, data []byte) (*models.Document, error) {
+	return r.ParseWithPassword(contentType, data, "")
+}
+
+// ParseWithPassword runs the parser chain for contentType with the
+// registry's limits, passing password to parsers that accept one.
+func (r *Registry) ParseWithPassword(contentType string, data []byte, password string) (*models.Document, error) {
+	return r.ParseWithOptions(context.Background(), contentType, data, ParseOptions{Password: password, Limits: r.Limits()})
+}
+
+// ParseWithOptions runs the parser chain for contentType. A parser that
+// fails or whose output is rejected by the quality check hands over to the
+// next one. If every parser ran but none passed the quality check, the
+// first low-quality result is returned rather than nothing.
+//
+// A *PasswordRequiredError or *LimitError stops the chain, since every other
+// parser would fail the same way. opts.Limits.Timeout covers the whole
+// chain, not each parser.
+func (r *Registry) ParseWithOptions(ctx context.Context, contentType string, data []byte, opts ParseOptions) (*models.Document, error) {
+	r.mu.RLock()
+	quality := r.quality
+	r.mu.RUnlock()
+
+	parsers := r.Parsers(contentType)
+	if len(parsers) == 0 {
 		return nil, errors.New("no parser found for content type: " + contentType)
 	}
-	return parser, nil
+	if err := opts.Limits.checkInputSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	opts.ContentType = contentType
+	if opts.Limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Limits.Timeout)
+		defer cancel()
+	}
+
+	chainErr := &ChainError{ContentType: contentType}
+	var lowQuality *models.Document
+	for _, p := range parsers {
+		doc, err := runParser(ctx, p, data, opts)
+
+		var pwErr *PasswordRequiredError
+		var limitErr *LimitError
+		if errors.As(err, &pwErr) || errors.As(err, &limitErr) {
+			return nil, err
+		}
+		if err == nil && quality != nil {
+			if qErr := quality(doc); qErr != nil {
+				if lowQuality == nil {
+					lowQuality = doc
+				}
+				err = qErr
+			}
+		}
+		if err == nil {
+			tagLanguages(doc)
+			return doc, nil
+		}
+		chainErr.Attempts = append(chainErr.Attempts, Attempt{Parser: p.Name() + "@" + p.Version(), Err: err})
+	}
+
+	if lowQuality != nil {
+		tagLanguages(lowQuality)
+		return lowQuality, nil
+	}
+	return nil, chainErr
+}
+
+// ParseStream parses size bytes read from src, calling emit with each page
+// as soon as it is available.
+//
+// Parsers implementing StreamParser read src in place. Other parsers need the
+// whole file, so it is read into memory and their document is emitted as a
+// single page once parsed. A parser that fails before emitting anything
+// hands over to the next one as in ParseWithOptions, including returning the
+// first low-quality result if nothing better is found; once pages have been
+// emitted they cannot be taken back, so a later failure is returned and the
+// quality check is not applied.
+func (r *Registry) ParseStream(ctx context.Context, contentType string, src io.ReaderAt, size int64, opts ParseOptions, emit func(models.Page) error) (*models.Document, error) {
+	r.mu.RLock()
+	quality := r.quality
+	r.mu.RUnlock()
+
+	parsers := r.Parsers(contentType)
+	if len(parsers) == 0 {
+		return nil, errors.New("no parser found for content type: " + contentType)
+	}
+	if err := opts.Limits.checkInputSize(size); err != nil {
+		return nil, err
+	}
+
+	opts.ContentType = contentType
+	if opts.Limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Limits.Timeout)
+		defer cancel()
+	}
+
+	emitted := false
+	countingEmit := func(page models.Page) error {
+		emitted = true
+		if emit == nil {
+			return nil
+		}
+		return emit(page)
+	}
+
+	chainErr := &ChainError{ContentType: contentType}
+	var lowQuality *models.Document
+	var data []byte
+	for _, p := range parsers {
+		var doc *models.Document
+		var err error
+		if sp, ok := p.(StreamParser); ok {
+			doc, err = sp.ParseStream(ctx, src, size, opts, countingEmit)
+		} else {
+			if data == nil {
+				data, err = io.ReadAll(io.NewSectionReader(src, 0, size))
+				if err != nil {
+					return nil, fmt.Errorf("reading input failed: %w", err)
+				}
+			}
+			doc, err = runParser(ctx, p, data, opts)
+			if err == nil && (quality == nil || quality(doc) == nil) {
+				err = countingEmit(models.Page{Number: 1, Text: doc.Text})
+			}
+		}
+
+		var pwErr *PasswordRequiredError
+		var limitErr *LimitError
+		if errors.As(err, &pwErr) || errors.As(err, &limitErr) || (err != nil && emitted) {
+			return nil, err
+		}
+		if err == nil && !emitted && quality != nil {
+			if qErr := quality(doc); qErr != nil {
+				if lowQuality == nil {
+					lowQuality = doc
+				}
+				err = qErr
+			}
+		}
+		if err == nil {
+			tagLanguages(doc)
+			return doc, nil
+		}
+		chainErr.Attempts = append(chainErr.Attempts, Attempt{Parser: p.Name() + "@" + p.Version(), Err: err})
+	}
+
+	if lowQuality != nil {
+		if err := countingEmit(models.Page{Number: 1, Text: lowQuality.Text}); err != nil {
+			return nil, err
+		}
+		tagLanguages(lowQuality)
+		return lowQuality, nil
+	}
+	return nil, chainErr
+}
+
+// runParser runs one parser under ctx. Parsers that do not implement
+// OptionsParser cannot be interrupted, so they run in their own goroutine
+// and are abandoned if the deadline passes first. They are still given the
+// password or content type when they implement PasswordParser or
+// ContentTypeParser.
+func runParser(ctx context.Context, p Parser, data []byte, opts ParseOptions) (*models.Document, error) {
+	if op, ok := p.(OptionsParser); ok {
+		return op.ParseWithOptions(ctx, data, opts)
+	}
+
+	type result struct {
+		doc *models.Document
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		switch pp := p.(type) {
+		case PasswordParser:
+			res.doc, res.err = pp.ParseWithPassword(data, opts.Password)
+		case ContentTypeParser:
+			res.doc, res.err = pp.ParseContentType(opts.ContentType, data)
+		default:
+			res.doc, res.err = p.Parse(data)
+		}
+		done <- res
+	}()
+
+	select {
+	case res := <-done:
+		return res.doc, res.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, &LimitError{Limit: LimitTimeout, Max: int64(opts.Limits.Timeout)}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// defaultRegistry backs the package-level helpers for callers that do not
+// inject their own Registry.
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the registry populated with the built-in parsers.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// RegisterParser registers a parser in the default registry at the default
+// priority.
+func RegisterParser(p Parser) {
+	defaultRegistry.Register(p, PriorityDefault)
+}
+
+// GetParser retrieves the highest-priority parser for the specified content
+// type from the default registry.
+func GetParser(contentType string) (Parser, error) {
+	return defaultRegistry.Get(contentType)
+}
+
+// RegisterBuiltinParsers registers the parsers shipped with this package.
+func RegisterBuiltinParsers(r *Registry) {
+	r.Register(&PDFParser{}, PriorityDefault)
+	r.Register(&DOCXParser{}, PriorityDefault)
+	r.Register(&PPTXParser{}, PriorityDefault)
+	r.Register(&SRTParser{}, PriorityDefault)
+	r.Register(&VTTParser{}, PriorityDefault)
 }
 
-// Example of initializing parsers
 func init() {
-	// Register your parsers here
-	RegisterParser(&PDFParser{})
-	RegisterParser(&DOCXParser{})
-	RegisterParser(&PPTXParser{})
+	defaultRegistry.SetLimits(LimitsFromEnv())
+	RegisterBuiltinParsers(defaultRegistry)
 }
@@ -0,0 +1,379 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"rag-go-app/models"
+)
+
+// fakeParser returns a document with the given text, or err.
+type fakeParser struct {
+	name  string
+	text  string
+	err   error
+	calls int
+	data  []byte
+}
+
+func (p *fakeParser) Name() string                    { return p.name }
+func (p *fakeParser) Version() string                 { return "1" }
+func (p *fakeParser) SupportedContentTypes() []string { return []string{"text/test"} }
+
+func (p *fakeParser) Parse(data []byte) (*models.Document, error) {
+	p.calls++
+	p.data = data
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &models.Document{Text: p.text}, nil
+}
+
+// fakeStreamParser emits its pages, then fails with err if it is set.
+type fakeStreamParser struct {
+	fakeParser
+	pages []string
+}
+
+func (p *fakeStreamParser) ParseStream(ctx context.Context, r io.ReaderAt, size int64, opts ParseOptions, emit func(models.Page) error) (*models.Document, error) {
+	p.calls++
+	doc := &models.Document{}
+	for i, text := range p.pages {
+		page := models.Page{Number: i + 1, Text: text}
+		doc.Pages = append(doc.Pages, page)
+		if emit != nil {
+			if err := emit(page); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	doc.DeriveText()
+	return doc, nil
+}
+
+// errParse is the failure of a fake parser.
+var errParse = errors.New("parse failed")
+
+func isParseError(err error) bool { return errors.Is(err, errParse) }
+
+func isPasswordError(err error) bool {
+	var pwErr *PasswordRequiredError
+	return errors.As(err, &pwErr)
+}
+
+func isLimitError(err error) bool {
+	var limitErr *LimitError
+	return errors.As(err, &limitErr)
+}
+
+func TestRegistryParseStream(t *testing.T) {
+	input := []byte("input bytes")
+
+	tests := []struct {
+		name      string
+		parsers   []Parser
+		limits    Limits
+		emitErr   error
+		wantText  string
+		wantPages []string
+		wantErr   func(error) bool
+		wantCalls []int
+	}{
+		{
+			name:      "whole-file parser is emitted as one page",
+			parsers:   []Parser{&fakeParser{name: "a", text: "whole text"}},
+			wantText:  "whole text",
+			wantPages: []string{"whole text"},
+			wantCalls: []int{1},
+		},
+		{
+			name:      "stream parser emits each page",
+			parsers:   []Parser{&fakeStreamParser{fakeParser: fakeParser{name: "a"}, pages: []string{"one", "two"}}},
+			wantText:  "one\n\ntwo",
+			wantPages: []string{"one", "two"},
+			wantCalls: []int{1},
+		},
+		{
+			name: "failure before emitting falls back",
+			parsers: []Parser{
+				&fakeStreamParser{fakeParser: fakeParser{name: "a", err: errParse}},
+				&fakeParser{name: "b", text: "fallback"},
+			},
+			wantText:  "fallback",
+			wantPages: []string{"fallback"},
+			wantCalls: []int{1, 1},
+		},
+		{
+			name: "failure after emitting is returned",
+			parsers: []Parser{
+				&fakeStreamParser{fakeParser: fakeParser{name: "a", err: errParse}, pages: []string{"one"}},
+				&fakeParser{name: "b", text: "fallback"},
+			},
+			wantPages: []string{"one"},
+			wantErr:   isParseError,
+			wantCalls: []int{1, 0},
+		},
+		{
+			// emitted pages cannot be taken back, so they are kept even
+			// though the quality check would reject them
+			name: "quality check is not applied after emitting",
+			parsers: []Parser{
+				&fakeStreamParser{fakeParser: fakeParser{name: "a"}, pages: []string{" "}},
+				&fakeParser{name: "b", text: "fallback"},
+			},
+			wantPages: []string{" "},
+			wantCalls: []int{1, 0},
+		},
+		{
+			name: "low-quality whole-file result is not emitted before falling back",
+			parsers: []Parser{
+				&fakeParser{name: "a", text: " "},
+				&fakeParser{name: "b", text: "better"},
+			},
+			wantText:  "better",
+			wantPages: []string{"better"},
+			wantCalls: []int{1, 1},
+		},
+		{
+			name: "first low-quality result when nothing is better",
+			parsers: []Parser{
+				&fakeParser{name: "a", text: " "},
+				&fakeParser{name: "b", err: errParse},
+			},
+			wantText:  " ",
+			wantPages: []string{" "},
+			wantCalls: []int{1, 1},
+		},
+		{
+			name: "password errors stop the chain",
+			parsers: []Parser{
+				&fakeParser{name: "a", err: &PasswordRequiredError{}},
+				&fakeParser{name: "b", text: "fallback"},
+			},
+			wantErr:   isPasswordError,
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:      "input over the limit is not parsed",
+			parsers:   []Parser{&fakeParser{name: "a", text: "text"}},
+			limits:    Limits{MaxInputBytes: 4},
+			wantErr:   isLimitError,
+			wantCalls: []int{0},
+		},
+		{
+			name:      "emit errors abort the parse",
+			parsers:   []Parser{&fakeStreamParser{fakeParser: fakeParser{name: "a"}, pages: []string{"one", "two"}}},
+			emitErr:   errParse,
+			wantPages: []string{"one"},
+			wantErr:   isParseError,
+			wantCalls: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			for i, p := range tt.parsers {
+				r.Register(p, -i)
+			}
+			var pages []string
+			emit := func(page models.Page) error {
+				pages = append(pages, page.Text)
+				return tt.emitErr
+			}
+
+			doc, err := r.ParseStream(context.Background(), "text/test", bytes.NewReader(input), int64(len(input)), ParseOptions{Limits: tt.limits}, emit)
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Fatalf("unexpected err %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.wantText != "" && doc.Text != tt.wantText {
+					t.Errorf("text = %q, want %q", doc.Text, tt.wantText)
+				}
+			}
+			if strings.Join(pages, "|") != strings.Join(tt.wantPages, "|") {
+				t.Errorf("emitted %q, want %q", pages, tt.wantPages)
+			}
+			for i, p := range tt.parsers {
+				if got := parserCalls(p); got != tt.wantCalls[i] {
+					t.Errorf("parser %s called %d times, want %d", p.Name(), got, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryParseStreamReadsWholeFileOnce(t *testing.T) {
+	input := []byte("input bytes")
+	a := &fakeParser{name: "a", err: errParse}
+	b := &fakeParser{name: "b", text: "text"}
+	r := NewRegistry()
+	r.Register(a, PriorityPreferred)
+	r.Register(b, PriorityDefault)
+
+	if _, err := r.ParseStream(context.Background(), "text/test", bytes.NewReader(input), int64(len(input)), ParseOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*fakeParser{a, b} {
+		if !bytes.Equal(p.data, input) {
+			t.Errorf("parser %s got %q, want %q", p.name, p.data, input)
+		}
+	}
+	if &a.data[0] != &b.data[0] {
+		t.Error("input was read again for the second parser")
+	}
+}
+
+func parserCalls(p Parser) int {
+	switch p := p.(type) {
+	case *fakeStreamParser:
+		return p.calls
+	case *fakeParser:
+		return p.calls
+	}
+	return 0
+}
+
+func TestRegistryOrder(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&fakeParser{name: "default"}, PriorityDefault)
+	r.Register(&fakeParser{name: "fallback"}, PriorityFallback)
+	r.Register(&fakeParser{name: "preferred"}, PriorityPreferred)
+	r.Register(&fakeParser{name: "default-later"}, PriorityDefault)
+	// registering a name again replaces it, at its new priority
+	r.Register(&fakeParser{name: "fallback"}, PriorityPreferred+1)
+
+	var names []string
+	for _, p := range r.Parsers("text/test") {
+		names = append(names, p.Name())
+	}
+	want := []string{"fallback", "preferred", "default", "default-later"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("parsers = %v, want %v", names, want)
+	}
+	if name, version := r.Identity("text/test"); name != "fallback,preferred,default,default-later" || version != "1,1,1,1" {
+		t.Errorf("Identity() = %q, %q", name, version)
+	}
+	if p, err := r.Get("text/test"); err != nil || p.Name() != "fallback" {
+		t.Errorf("Get() = %v, %v, want the fallback parser", p, err)
+	}
+	if _, err := r.Get("text/other"); err == nil {
+		t.Error("Get() of an unregistered type: expected an error")
+	}
+	if infos := r.List(); len(infos) != 4 || infos[0].Name != "fallback" || infos[0].Priority != PriorityPreferred+1 {
+		t.Errorf("List() = %+v", infos)
+	}
+}
+
+func TestRegistryFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		parsers   []*fakeParser
+		quality   QualityCheck
+		wantText  string
+		wantErr   func(error) bool
+		wantCalls []int
+	}{
+		{
+			name:      "first success wins",
+			parsers:   []*fakeParser{{name: "a", text: "first"}, {name: "b", text: "second"}},
+			quality:   DefaultQualityCheck,
+			wantText:  "first",
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:      "failure falls back",
+			parsers:   []*fakeParser{{name: "a", err: errParse}, {name: "b", text: "second"}},
+			quality:   DefaultQualityCheck,
+			wantText:  "second",
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:      "low quality falls back",
+			parsers:   []*fakeParser{{name: "a", text: " "}, {name: "b", text: "second"}},
+			quality:   DefaultQualityCheck,
+			wantText:  "second",
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:      "no quality check accepts any result",
+			parsers:   []*fakeParser{{name: "a", text: " "}, {name: "b", text: "second"}},
+			wantText:  " ",
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:      "first low-quality result when nothing is better",
+			parsers:   []*fakeParser{{name: "a", err: errParse}, {name: "b", text: " "}, {name: "c", text: "\n"}},
+			quality:   DefaultQualityCheck,
+			wantText:  " ",
+			wantCalls: []int{1, 1, 1},
+		},
+		{
+			name:    "every parser failed",
+			parsers: []*fakeParser{{name: "a", err: errParse}, {name: "b", err: errors.New("other")}},
+			quality: DefaultQualityCheck,
+			wantErr: func(err error) bool {
+				var chainErr *ChainError
+				return errors.As(err, &chainErr) && len(chainErr.Attempts) == 2 &&
+					chainErr.Attempts[0].Parser == "a@1" && errors.Is(err, errParse)
+			},
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:      "password errors stop the chain",
+			parsers:   []*fakeParser{{name: "a", err: &PasswordRequiredError{}}, {name: "b", text: "second"}},
+			quality:   DefaultQualityCheck,
+			wantErr:   isPasswordError,
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:      "limit errors stop the chain",
+			parsers:   []*fakeParser{{name: "a", err: &LimitError{Limit: LimitPages}}, {name: "b", text: "second"}},
+			quality:   DefaultQualityCheck,
+			wantErr:   isLimitError,
+			wantCalls: []int{1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.SetQualityCheck(tt.quality)
+			for i, p := range tt.parsers {
+				r.Register(p, -i)
+			}
+			doc, err := r.Parse("text/test", []byte("input"))
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Fatalf("unexpected err %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if doc.Text != tt.wantText {
+					t.Errorf("text = %q, want %q", doc.Text, tt.wantText)
+				}
+			}
+			for i, p := range tt.parsers {
+				if p.calls != tt.wantCalls[i] {
+					t.Errorf("parser %s called %d times, want %d", p.name, p.calls, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+
+	if _, err := NewRegistry().Parse("text/test", nil); err == nil {
+		t.Error("parsing an unregistered type: expected an error")
+	}
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minExtractedChars is the shortest output accepted from an extractor before
+// falling back to the next one.
+const minExtractedChars = 10
+
+// maxOperatorShare is the share of content-stream tokens above which
+// extracted text is taken to be the page's drawing operators, not its text.
+const maxOperatorShare = 0.5
+
+// errContentStream is the failure recorded for an extractor that returned
+// the page's content-stream operators instead of its text.
+var errContentStream = errors.New("output is PDF content-stream operators, not text")
+
+// contentStreamOperators are the operators of PDF content streams.
+var contentStreamOperators = map[string]bool{
+	"b": true, "B": true, "b*": true, "B*": true, "BDC": true, "BI": true, "BMC": true, "BT": true, "BX": true,
+	"c": true, "cm": true, "CS": true, "cs": true, "d": true, "d0": true, "d1": true, "Do": true, "DP": true,
+	"EI": true, "EMC": true, "ET": true, "EX": true, "f": true, "F": true, "f*": true, "G": true, "g": true,
+	"gs": true, "h": true, "i": true, "ID": true, "j": true, "J": true, "K": true, "k": true, "l": true,
+	"m": true, "M": true, "MP": true, "n": true, "q": true, "Q": true, "re": true, "RG": true, "rg": true,
+	"ri": true, "s": true, "S": true, "SC": true, "sc": true, "SCN": true, "scn": true, "sh": true,
+	"T*": true, "Tc": true, "Td": true, "TD": true, "Tf": true, "Tj": true, "TJ": true, "TL": true,
+	"Tm": true, "Tr": true, "Ts": true, "Tw": true, "Tz": true, "v": true, "w": true, "W": true,
+	"W*": true, "y": true, "'": true, "\"": true,
+}
+
+// checkExtractedText returns why an extractor's output is not usable as
+// page text, or nil if it is.
+func checkExtractedText(text string) error {
+	if n := utf8.RuneCountInString(strings.TrimSpace(text)); n <= minExtractedChars {
+		return fmt.Errorf("too little text (%d chars)", n)
+	}
+	if operatorShare(text) > maxOperatorShare {
+		return errContentStream
+	}
+	return nil
+}
+
+// operatorShare estimates the share of text that is content-stream syntax:
+// operators, names, string and array delimiters, and the numeric operands
+// of operators. Numbers count only when an operator follows them, as in
+// "0 0 612 792 re" or "72 720 Td", so a page of numeric tables is not
+// mistaken for drawing operators.
+func operatorShare(text string) float64 {
+	tokens := strings.Fields(text)
+	if len(tokens) == 0 {
+		return 0
+	}
+	var syntax, operands int
+	for _, t := range tokens {
+		if _, err := strconv.ParseFloat(t, 64); err == nil {
+			operands++
+			continue
+		}
+		switch {
+		case contentStreamOperators[t]:
+			syntax += operands + 1
+		case strings.HasPrefix(t, "/"), strings.HasPrefix(t, "["), strings.HasSuffix(t, "]"),
+			strings.HasPrefix(t, "<"), strings.HasSuffix(t, ">"),
+			strings.HasPrefix(t, "("), strings.HasSuffix(t, ")Tj"), strings.HasSuffix(t, "]TJ"):
+			// names and arrays are operands too, as in "/F1 12 Tf"
+			syntax++
+			continue
+		}
+		operands = 0
+	}
+	return float64(syntax) / float64(len(tokens))
+}
+
+// scannedImageChars is the amount of native text above which a page with
+// images is treated as born-digital rather than scanned.
+const scannedImageChars = 200
+
+// scannedScore estimates how likely a page is a scanned image: pages with
+// images and little native text score close to 1.
+func scannedScore(nativeChars, images int) float64 {
+	if images == 0 {
+		return 0
+	}
+	if nativeChars >= scannedImageChars {
+		return 0
+	}
+	return 1 - float64(nativeChars)/scannedImageChars
+}
+
+// garbledScore estimates the share of text that is not readable prose:
+// replacement and control characters, private-use glyphs from broken font
+// encodings, and "words" with no letters at all.
+func garbledScore(text string) float64 {
+	var total, bad int
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		switch {
+		case r == unicode.ReplacementChar,
+			unicode.IsControl(r),
+			unicode.In(r, unicode.Co),
+			unicode.In(r, unicode.Cs):
+			bad++
+		}
+	}
+
+	words := strings.Fields(text)
+	var noLetters int
+	for _, w := range words {
+		if strings.IndexFunc(w, unicode.IsLetter) < 0 && strings.IndexFunc(w, unicode.IsDigit) < 0 {
+			noLetters++
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	score := float64(bad) / float64(total)
+	if len(words) > 0 {
+		score = max(score, float64(noLetters)/float64(len(words)))
+	}
+	return score
+}
@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckExtractedText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want error
+	}{
+		{
+			name: "prose",
+			text: "Photosynthesis converts light energy into chemical energy stored in glucose.",
+		},
+		{
+			name: "numeric table",
+			text: "Year Revenue Cost Margin\n2019 120.5 80.2 40.3\n2020 130.1 85.7 44.4\n2021 142.8 90.0 52.8\n2022 150.3 94.6 55.7",
+		},
+		{
+			name: "table of numbers only",
+			text: "1 2 3 4 5 6 7 8 9 10\n11 12 13 14 15 16 17 18 19 20\n0.5 0.25 0.125 -1 -2 -3",
+		},
+		{
+			name: "content-stream operators",
+			text: "BT /F1 12 Tf 72 720 Td (Hello) Tj ET q 0 0 612 792 re W n 1 0 0 1 0 0 cm Q",
+			want: errContentStream,
+		},
+		{
+			name: "path operators with numeric operands",
+			text: "0.5 w 100 200 m 300 200 l 300 400 l h S 10 10 50 50 re f",
+			want: errContentStream,
+		},
+		{
+			name: "too short",
+			text: "  Page 3  ",
+			want: errors.New("too little text"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExtractedText(tt.text)
+			switch {
+			case tt.want == nil && err != nil:
+				t.Errorf("rejected: %v (operator share %.2f)", err, operatorShare(tt.text))
+			case tt.want != nil && err == nil:
+				t.Errorf("accepted, want %v (operator share %.2f)", tt.want, operatorShare(tt.text))
+			case errors.Is(tt.want, errContentStream) && !errors.Is(err, errContentStream):
+				t.Errorf("err = %v, want %v", err, errContentStream)
+			}
+		})
+	}
+}
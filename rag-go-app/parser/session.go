@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// parseSession holds the state of one parse: its limits and deadline, and
+// every temp file and child process it created. cleanup must be deferred
+// right after the session is created so temp files are removed on every
+// return path.
+type parseSession struct {
+	ctx      context.Context
+	limits   Limits
+	password string
+	src      io.ReaderAt
+	size     int64
+
+	children   int
+	tempFiles  []string
+	sourceFile string
+
+	layoutPath string
+	layoutErr  error
+	layoutDone bool
+	layout     *layoutReader
+}
+
+func newParseSession(ctx context.Context, src io.ReaderAt, size int64, opts ParseOptions) *parseSession {
+	return &parseSession{ctx: ctx, limits: opts.Limits, password: opts.Password, src: src, size: size}
+}
+
+// cleanup removes every temp file created during the session.
+func (s *parseSession) cleanup() {
+	if s.layout != nil {
+		s.layout.close()
+	}
+	for _, name := range s.tempFiles {
+		os.Remove(name)
+	}
+	s.tempFiles = nil
+}
+
+// err returns a LimitError once the session deadline has passed.
+func (s *parseSession) err() error {
+	if err := s.ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return &LimitError{Limit: LimitTimeout, Max: int64(s.limits.Timeout)}
+		}
+		return err
+	}
+	return nil
+}
+
+// createTemp creates a temp file that is removed by cleanup.
+func (s *parseSession) createTemp(pattern string) (*os.File, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.tempFiles = append(s.tempFiles, f.Name())
+	return f, nil
+}
+
+// writeTemp writes data to a new temp file and returns its path.
+func (s *parseSession) writeTemp(pattern string, data []byte) (string, error) {
+	return s.copyTemp(pattern, bytes.NewReader(data))
+}
+
+// copyTemp streams r into a new temp file and returns its path.
+func (s *parseSession) copyTemp(pattern string, r io.Reader) (string, error) {
+	tmpFile, err := s.createTemp(pattern)
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		tmpFile.Close()
+		return "", fmt.Errorf("writing to temp file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", fmt.Errorf("closing temp file failed: %w", err)
+	}
+	return tmpFile.Name(), nil
+}
+
+// sourcePath returns a path external tools can read the source file from.
+// An *os.File source is used in place; anything else is copied once to a
+// temp file.
+func (s *parseSession) sourcePath(pattern string) (string, error) {
+	if s.sourceFile != "" {
+		return s.sourceFile, nil
+	}
+	if f, ok := s.src.(*os.File); ok {
+		s.sourceFile = f.Name()
+		return s.sourceFile, nil
+	}
+	path, err := s.copyTemp(pattern, io.NewSectionReader(s.src, 0, s.size))
+	if err != nil {
+		return "", err
+	}
+	s.sourceFile = path
+	return path, nil
+}
+
+// run starts an external process bound to the session deadline, counting it
+// against MaxChildProcesses, and returns its stdout capped at
+// MaxDecompressedBytes.
+func (s *parseSession) run(stdin io.Reader, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	if err := s.runTo(&out, stdin, name, args...); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// runTo is like run but writes the process's stdout to w as it is produced.
+func (s *parseSession) runTo(w io.Writer, stdin io.Reader, name string, args ...string) error {
+	if err := s.err(); err != nil {
+		return err
+	}
+	if s.limits.MaxChildProcesses > 0 && s.children >= s.limits.MaxChildProcesses {
+		return &LimitError{Limit: LimitChildProcesses, Value: int64(s.children + 1), Max: int64(s.limits.MaxChildProcesses)}
+	}
+	s.children++
+
+	cmd := exec.CommandContext(s.ctx, name, args...)
+	cmd.Stdin = stdin
+	out := &cappedWriter{w: w, max: s.limits.MaxDecompressedBytes}
+	var stderr bytes.Buffer
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if limitErr := s.err(); limitErr != nil {
+			return limitErr
+		}
+		if out.exceeded() {
+			return &LimitError{Limit: LimitDecompressedBytes, Value: out.attempted, Max: out.max}
+		}
+		return fmt.Errorf("%s execution error: %w, stderr: %s", name, err, stderr.String())
+	}
+	return nil
+}
+
+// pdfminerWithPassword does what pdf2txt.py -t xml does, reading the
+// password from the first line of stdin rather than from an argument, where
+// it would show in the process list.
+const pdfminerWithPassword = `import sys
+from pdfminer.high_level import extract_text_to_fp
+from pdfminer.layout import LAParams
+password = sys.stdin.readline().rstrip("\n")
+with open(sys.argv[1], "rb") as f:
+    extract_text_to_fp(f, sys.stdout.buffer, output_type="xml", codec="utf-8", laparams=LAParams(), password=password)
+`
+
+// pdf2txt runs pdfminer's pdf2txt.py over the session's PDF once and
+// returns the path of a temp file holding its XML layout output, which can
+// be far larger than the PDF. pdfminer decrypts the file itself when given
+// the password. Later calls reuse the first result.
+func (s *parseSession) pdf2txt() (string, error) {
+	if s.layoutDone {
+		return s.layoutPath, s.layoutErr
+	}
+	s.layoutDone = true
+	s.layoutPath, s.layoutErr = s.runPdf2txt()
+	return s.layoutPath, s.layoutErr
+}
+
+func (s *parseSession) runPdf2txt() (string, error) {
+	path, err := s.sourcePath("temp.*.pdf")
+	if err != nil {
+		return "", err
+	}
+	out, err := s.createTemp("layout.*.xml")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+	if s.password != "" {
+		stdin := strings.NewReader(s.password + "\n")
+		err = s.runTo(out, stdin, "python3", "-c", pdfminerWithPassword, path)
+	} else {
+		err = s.runTo(out, nil, "pdf2txt.py", "-t", "xml", path)
+	}
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("closing temp file failed: %w", closeErr)
+	}
+	if err != nil {
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// layoutPage returns the pdfminer layout of page pageNum, decoding the
+// layout file one page at a time. ok is false when pdfminer produced no
+// such page.
+func (s *parseSession) layoutPage(pageNum int) (page pdfminerPage, ok bool, err error) {
+	if err := s.err(); err != nil {
+		return pdfminerPage{}, false, err
+	}
+	if s.layout == nil {
+		path, err := s.pdf2txt()
+		if err != nil {
+			return pdfminerPage{}, false, err
+		}
+		s.layout = &layoutReader{path: path}
+	}
+	return s.layout.page(pageNum)
+}
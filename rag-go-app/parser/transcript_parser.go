@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"rag-go-app/models"
+)
+
+const (
+	defaultMaxSegmentChars = 600
+	defaultMinSegmentChars = 200
+	defaultMaxCueGap       = 2 * time.Second
+)
+
+// cue is a single caption entry before cues are merged into segments.
+type cue struct {
+	start time.Duration
+	end   time.Duration
+	text  string
+}
+
+// TranscriptOptions controls how caption cues are merged into segments.
+// Zero values fall back to the package defaults.
+type TranscriptOptions struct {
+	// MaxSegmentChars caps the length of a merged segment.
+	MaxSegmentChars int
+	// MinSegmentChars is the length after which a segment is closed at the
+	// next sentence boundary.
+	MinSegmentChars int
+	// MaxCueGap is the silence between cues that always starts a new segment.
+	MaxCueGap time.Duration
+}
+
+// SRTParser parses SubRip (.srt) caption files.
+type SRTParser struct {
+	Options TranscriptOptions
+}
+
+func (p *SRTParser) Name() string    { return "srt" }
+func (p *SRTParser) Version() string { return "1.2.0" }
+
+func (p *SRTParser) SupportedContentTypes() []string {
+	return []string{"application/x-subrip", "text/srt"}
+}
+
+func (p *SRTParser) Parse(data []byte) (*models.Document, error) {
+	cues, err := parseSRT(data)
+	if err != nil {
+		return nil, err
+	}
+	return newTranscriptDocument(cues, p.Options)
+}
+
+// VTTParser parses WebVTT (.vtt) caption files.
+type VTTParser struct {
+	Options TranscriptOptions
+}
+
+func (p *VTTParser) Name() string    { return "webvtt" }
+func (p *VTTParser) Version() string { return "1.2.0" }
+
+func (p *VTTParser) SupportedContentTypes() []string {
+	return []string{"text/vtt"}
+}
+
+func (p *VTTParser) Parse(data []byte) (*models.Document, error) {
+	cues, err := parseVTT(data)
+	if err != nil {
+		return nil, err
+	}
+	return newTranscriptDocument(cues, p.Options)
+}
+
+func newTranscriptDocument(cues []cue, opts TranscriptOptions) (*models.Document, error) {
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("transcript contains no cues")
+	}
+
+	segments := mergeCues(cues, opts)
+	texts := make([]string, len(segments))
+	for i, seg := range segments {
+		texts[i] = seg.Text
+	}
+
+	doc, err := models.NewDocument(0, strings.Join(texts, "\n\n"), models.Metadata{})
+	if err != nil {
+		return nil, err
+	}
+	doc.Segments = segments
+	return doc, nil
+}
+
+// mergeCues joins consecutive cues into paragraph-sized segments. A segment
+// is closed on a long pause, when it would grow past MaxSegmentChars, or at
+// the first sentence end once it is longer than MinSegmentChars.
+func mergeCues(cues []cue, opts TranscriptOptions) []models.TranscriptSegment {
+	maxChars := opts.MaxSegmentChars
+	if maxChars <= 0 {
+		maxChars = defaultMaxSegmentChars
+	}
+	minChars := opts.MinSegmentChars
+	if minChars <= 0 {
+		minChars = defaultMinSegmentChars
+	}
+	maxGap := opts.MaxCueGap
+	if maxGap <= 0 {
+		maxGap = defaultMaxCueGap
+	}
+
+	var segments []models.TranscriptSegment
+	var current *models.TranscriptSegment
+	flush := func() {
+		if current != nil && current.Text != "" {
+			segments = append(segments, *current)
+		}
+		current = nil
+	}
+
+	prevText := ""
+	for _, c := range cues {
+		// Auto-generated captions often repeat the previous line verbatim.
+		if c.text == prevText {
+			if current != nil && c.end > current.End {
+				current.End = c.end
+			}
+			continue
+		}
+		prevText = c.text
+
+		if current != nil {
+			gap := c.start - current.End
+			if gap > maxGap || len(current.Text)+1+len(c.text) > maxChars {
+				flush()
+			}
+		}
+		if current == nil {
+			current = &models.TranscriptSegment{Start: c.start, End: c.end, Text: c.text}
+		} else {
+			current.Text += " " + c.text
+			if c.end > current.End {
+				current.End = c.end
+			}
+		}
+		if len(current.Text) >= minChars && endsSentence(current.Text) {
+			flush()
+		}
+	}
+	flush()
+	return segments
+}
+
+func endsSentence(text string) bool {
+	text = strings.TrimRight(text, `"')]`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") ||
+		strings.HasSuffix(text, "!") || strings.HasSuffix(text, "।")
+}
+
+func parseSRT(data []byte) ([]cue, error) {
+	var cues []cue
+	for n, block := range splitCueBlocks(data) {
+		lines := block
+		// The numeric counter line is optional in practice.
+		if len(lines) > 0 && !strings.Contains(lines[0], "-->") {
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		start, end, err := parseCueTiming(lines[0])
+		if err != nil {
+			return nil, fmt.Errorf("SRT cue %d: %w", n+1, err)
+		}
+		if text := cleanCueText(lines[1:]); text != "" {
+			cues = append(cues, cue{start: start, end: end, text: text})
+		}
+	}
+	return cues, nil
+}
+
+func parseVTT(data []byte) ([]cue, error) {
+	blocks := splitCueBlocks(data)
+	if len(blocks) == 0 || !strings.HasPrefix(blocks[0][0], "WEBVTT") {
+		return nil, fmt.Errorf("missing WEBVTT header")
+	}
+
+	var cues []cue
+	for n, lines := range blocks[1:] {
+		switch {
+		case strings.HasPrefix(lines[0], "NOTE"),
+			strings.HasPrefix(lines[0], "STYLE"),
+			strings.HasPrefix(lines[0], "REGION"):
+			continue
+		}
+		// Cue identifiers are optional and precede the timing line.
+		if !strings.Contains(lines[0], "-->") {
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		start, end, err := parseCueTiming(lines[0])
+		if err != nil {
+			return nil, fmt.Errorf("WebVTT cue %d: %w", n+1, err)
+		}
+		if text := cleanCueText(lines[1:]); text != "" {
+			cues = append(cues, cue{start: start, end: end, text: text})
+		}
+	}
+	return cues, nil
+}
+
+// splitCueBlocks splits a caption file into blank-line separated blocks of
+// non-empty lines.
+func splitCueBlocks(data []byte) [][]string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var blocks [][]string
+	var current []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r \t")
+		if line == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+// parseCueTiming parses "00:01:02,500 --> 00:01:05,000" and the WebVTT form,
+// which uses '.' for milliseconds, may omit hours and may carry cue settings.
+func parseCueTiming(line string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid timing line %q", line)
+	}
+	endFields := strings.Fields(parts[1])
+	if len(endFields) == 0 {
+		return 0, 0, fmt.Errorf("invalid timing line %q", line)
+	}
+	start, err := parseTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseTimestamp(endFields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("cue ends before it starts: %q", line)
+	}
+	return start, end, nil
+}
+
+func parseTimestamp(ts string) (time.Duration, error) {
+	ts = strings.Replace(ts, ",", ".", 1)
+	clock, frac, _ := strings.Cut(ts, ".")
+	fields := strings.Split(clock, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+
+	var total time.Duration
+	units := []time.Duration{time.Second, time.Minute, time.Hour}
+	for i := range fields {
+		v, err := strconv.Atoi(fields[len(fields)-1-i])
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		total += time.Duration(v) * units[i]
+	}
+	if frac != "" {
+		if len(frac) > 3 {
+			frac = frac[:3]
+		}
+		for len(frac) < 3 {
+			frac += "0"
+		}
+		ms, err := strconv.Atoi(frac)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		total += time.Duration(ms) * time.Millisecond
+	}
+	return total, nil
+}
+
+var (
+	cueTagPattern      = regexp.MustCompile(`<[^>]*>`)
+	cueOverridePattern = regexp.MustCompile(`\{\\[^}]*\}`)
+)
+
+// cleanCueText joins the text lines of a cue, dropping markup such as <i>,
+// <v Speaker>, inline WebVTT timestamps and SSA overrides like {\an8}.
+func cleanCueText(lines []string) string {
+	text := strings.Join(lines, " ")
+	text = cueTagPattern.ReplaceAllString(text, "")
+	text = cueOverridePattern.ReplaceAllString(text, "")
+	text = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&nbsp;", " ").Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
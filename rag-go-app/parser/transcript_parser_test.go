@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"rag-go-app/models"
+)
+
+func TestTranscriptParsers(t *testing.T) {
+	tests := []struct {
+		file   string
+		parser Parser
+		want   []models.TranscriptSegment
+	}{
+		{
+			file:   "testdata/lecture.srt",
+			parser: &SRTParser{},
+			want: []models.TranscriptSegment{
+				// the repeated cue is dropped but extends the segment
+				{Start: time.Second, End: 9 * time.Second, Text: "Welcome to the lecture on photosynthesis. Plants convert light energy into chemical energy."},
+				// a long pause starts a new segment
+				{Start: 15 * time.Second, End: 18 * time.Second, Text: "Chlorophyll absorbs red & blue light."},
+			},
+		},
+		{
+			file:   "testdata/lecture.vtt",
+			parser: &VTTParser{},
+			want: []models.TranscriptSegment{
+				{Start: time.Second, End: 5250 * time.Millisecond, Text: "Today we study cells. Every cell has a membrane."},
+				{Start: 10 * time.Second, End: 12500 * time.Millisecond, Text: "Any questions?"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := tt.parser.Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Segments) != len(tt.want) {
+				t.Fatalf("segments = %+v, want %+v", doc.Segments, tt.want)
+			}
+			for i, seg := range doc.Segments {
+				if seg != tt.want[i] {
+					t.Errorf("segment %d = %+v, want %+v", i, seg, tt.want[i])
+				}
+			}
+			if want := tt.want[0].Text + "\n\n" + tt.want[1].Text; doc.Text != want {
+				t.Errorf("text = %q, want %q", doc.Text, want)
+			}
+		})
+	}
+}
+
+func TestTranscriptParsersAcceptVariants(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser Parser
+		input  string
+		want   string
+	}{
+		{"srt without counters", &SRTParser{}, "00:00:01,000 --> 00:00:02,000\nFirst.\n\n00:00:02,000 --> 00:00:03,000\nSecond.\n", "First. Second."},
+		{"srt with CRLF and BOM", &SRTParser{}, "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,000\r\nFirst line\r\nsecond line\r\n", "First line second line"},
+		{"vtt without hours", &VTTParser{}, "WEBVTT\n\n01:02.500 --> 01:04.000\nShort form.\n", "Short form."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := tt.parser.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Text != tt.want {
+				t.Errorf("text = %q, want %q", doc.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscriptParsersReject(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser Parser
+		input  string
+	}{
+		{"vtt without header", &VTTParser{}, "00:01.000 --> 00:02.000\nText.\n"},
+		{"no cues", &SRTParser{}, "\n\n"},
+		{"bad timing", &SRTParser{}, "1\n00:00:01,000 -> 00:00:02,000\nText.\n"},
+		{"bad timestamp", &SRTParser{}, "1\n00:xx:01,000 --> 00:00:02,000\nText.\n"},
+		{"ends before it starts", &VTTParser{}, "WEBVTT\n\n00:05.000 --> 00:02.000\nText.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.parser.Parse([]byte(tt.input)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestMergeCues(t *testing.T) {
+	cues := []cue{
+		{start: 0, end: time.Second, text: "One two."},
+		{start: time.Second, end: 2 * time.Second, text: "Three four"},
+		{start: 2 * time.Second, end: 3 * time.Second, text: "five six."},
+		{start: 3 * time.Second, end: 4 * time.Second, text: "Seven."},
+	}
+	tests := []struct {
+		name string
+		opts TranscriptOptions
+		want []string
+	}{
+		{"defaults merge everything", TranscriptOptions{}, []string{"One two. Three four five six. Seven."}},
+		{"closes at a sentence end once long enough", TranscriptOptions{MinSegmentChars: 5}, []string{"One two.", "Three four five six.", "Seven."}},
+		{"never grows past the maximum", TranscriptOptions{MaxSegmentChars: 20}, []string{"One two. Three four", "five six. Seven."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, seg := range mergeCues(cues, tt.opts) {
+				got = append(got, seg.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("segments = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	paused := []cue{
+		{start: 0, end: time.Second, text: "Before."},
+		{start: 1500 * time.Millisecond, end: 2 * time.Second, text: "Short pause."},
+		{start: 10 * time.Second, end: 11 * time.Second, text: "After."},
+	}
+	segments := mergeCues(paused, TranscriptOptions{MaxCueGap: time.Second})
+	if len(segments) != 2 || segments[0].End != 2*time.Second || segments[1].Start != 10*time.Second {
+		t.Errorf("segments = %+v, want a break at the nine-second pause only", segments)
+	}
+}
+
+func TestTranscriptSegmentJSON(t *testing.T) {
+	seg := models.TranscriptSegment{Start: 61500 * time.Millisecond, End: 65 * time.Second, Text: "Hello."}
+	data, err := json.Marshal(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"start":61.5,"end":65,"text":"Hello."}`; string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+	var back models.TranscriptSegment
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != seg {
+		t.Errorf("round trip = %+v, want %+v", back, seg)
+	}
+}
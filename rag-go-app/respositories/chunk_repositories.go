@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"errors"
+	"rag-go-app/models"
+)
+
+// ChunkRepository defines the interface for storing document chunks.
+type ChunkRepository interface {
+	SaveChunks(documentID int64, chunks []models.Chunk) error
+	FindChunksByDocument(documentID int64) ([]models.Chunk, error)
+	FindChunkByID(id int64) (*models.Chunk, error)
+	DeleteChunks(documentID int64) error
+}
+
+// InMemoryChunkRepository is an in-memory implementation of ChunkRepository for demonstration purposes.
+type InMemoryChunkRepository struct {
+	chunks     map[int64]models.Chunk
+	byDocument map[int64][]int64
+	nextID     int64
+}
+
+// NewInMemoryChunkRepository creates a new instance of InMemoryChunkRepository.
+func NewInMemoryChunkRepository() *InMemoryChunkRepository {
+	return &InMemoryChunkRepository{
+		chunks:     make(map[int64]models.Chunk),
+		byDocument: make(map[int64][]int64),
+		nextID:     1,
+	}
+}
+
+// SaveChunks replaces the chunks of a document, assigning each an ID.
+func (r *InMemoryChunkRepository) SaveChunks(documentID int64, chunks []models.Chunk) error {
+	r.DeleteChunks(documentID)
+
+	ids := make([]int64, len(chunks))
+	for i := range chunks {
+		chunks[i].ID = r.nextID
+		chunks[i].DocumentID = documentID
+		r.chunks[r.nextID] = chunks[i]
+		ids[i] = r.nextID
+		r.nextID++
+	}
+	r.byDocument[documentID] = ids
+	return nil
+}
+
+// FindChunksByDocument returns the chunks of a document in order.
+func (r *InMemoryChunkRepository) FindChunksByDocument(documentID int64) ([]models.Chunk, error) {
+	ids := r.byDocument[documentID]
+	chunks := make([]models.Chunk, len(ids))
+	for i, id := range ids {
+		chunks[i] = r.chunks[id]
+	}
+	return chunks, nil
+}
+
+// FindChunkByID retrieves a chunk by its ID.
+func (r *InMemoryChunkRepository) FindChunkByID(id int64) (*models.Chunk, error) {
+	chunk, exists := r.chunks[id]
+	if !exists {
+		return nil, errors.New("chunk not found")
+	}
+	return &chunk, nil
+}
+
+// DeleteChunks removes every chunk of a document.
+func (r *InMemoryChunkRepository) DeleteChunks(documentID int64) error {
+	for _, id := range r.byDocument[documentID] {
+		delete(r.chunks, id)
+	}
+	delete(r.byDocument, documentID)
+	return nil
+}
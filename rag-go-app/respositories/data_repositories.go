@@ -10,6 +10,8 @@ type DataRepository interface {
 	SaveDocument(doc *models.Document) error
 	FindDocumentByID(id int64) (*models.Document, error)
 	UpdateDocument(doc *models.Document) error
+	ListDocuments() ([]*models.Document, error)
+	DeleteDocument(id int64) error
 }
 
 // InMemoryDataRepository is an in-memory implementation of DataRepository for demonstration purposes.
@@ -51,3 +53,23 @@ func (r *InMemoryDataRepository) UpdateDocument(doc *models.Document) error {
 	r.documents[doc.ID] = doc
 	return nil
 }
+
+// DeleteDocument deletes a document by its ID.
+func (r *InMemoryDataRepository) DeleteDocument(id int64) error {
+	if _, exists := r.documents[id]; !exists {
+		return errors.New("document not found")
+	}
+	delete(r.documents, id)
+	return nil
+}
+
+// ListDocuments returns all documents ordered by ID.
+func (r *InMemoryDataRepository) ListDocuments() ([]*models.Document, error) {
+	docs := make([]*models.Document, 0, len(r.documents))
+	for id := int64(1); id < r.nextID; id++ {
+		if doc, exists := r.documents[id]; exists {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"errors"
+	"rag-go-app/models"
+	"sort"
+)
+
+// EmbeddingRepository defines the interface for storing chunk embeddings.
+// Embeddings are kept per model, so several models can be stored side by
+// side while a collection is migrated from one to another.
+type EmbeddingRepository interface {
+	SaveEmbeddings(documentID int64, model models.EmbeddingModel, embeddings []models.Embedding) error
+	FindEmbeddings(documentID int64, model models.EmbeddingModel) ([]models.Embedding, error)
+	FindEmbedding(chunkID int64, model models.EmbeddingModel) (*models.Embedding, error)
+	Models() ([]models.EmbeddingModel, error)
+	DeleteEmbeddings(documentID int64) error
+	DeleteModel(model models.EmbeddingModel) error
+}
+
+// InMemoryEmbeddingRepository is an in-memory implementation of EmbeddingRepository for demonstration purposes.
+type InMemoryEmbeddingRepository struct {
+	// embeddings holds, for each model, the embeddings of each document
+	embeddings map[models.EmbeddingModel]map[int64][]models.Embedding
+}
+
+// NewInMemoryEmbeddingRepository creates a new instance of InMemoryEmbeddingRepository.
+func NewInMemoryEmbeddingRepository() *InMemoryEmbeddingRepository {
+	return &InMemoryEmbeddingRepository{
+		embeddings: make(map[models.EmbeddingModel]map[int64][]models.Embedding),
+	}
+}
+
+// SaveEmbeddings replaces the embeddings of a document under model,
+// leaving those under other models alone.
+func (r *InMemoryEmbeddingRepository) SaveEmbeddings(documentID int64, model models.EmbeddingModel, embeddings []models.Embedding) error {
+	for _, e := range embeddings {
+		if e.Model != model {
+			return errors.New("embedding model does not match")
+		}
+		if len(e.Vector) != model.Dimension {
+			return errors.New("embedding dimension does not match model")
+		}
+	}
+
+	byDocument, exists := r.embeddings[model]
+	if !exists {
+		byDocument = make(map[int64][]models.Embedding)
+		r.embeddings[model] = byDocument
+	}
+	saved := make([]models.Embedding, len(embeddings))
+	for i, e := range embeddings {
+		e.DocumentID = documentID
+		saved[i] = e
+	}
+	byDocument[documentID] = saved
+	return nil
+}
+
+// FindEmbeddings returns the embeddings of a document under model, in
+// chunk order.
+func (r *InMemoryEmbeddingRepository) FindEmbeddings(documentID int64, model models.EmbeddingModel) ([]models.Embedding, error) {
+	embeddings := r.embeddings[model][documentID]
+	return append([]models.Embedding(nil), embeddings...), nil
+}
+
+// FindEmbedding retrieves the embedding of a chunk under model.
+func (r *InMemoryEmbeddingRepository) FindEmbedding(chunkID int64, model models.EmbeddingModel) (*models.Embedding, error) {
+	for _, embeddings := range r.embeddings[model] {
+		for _, e := range embeddings {
+			if e.ChunkID == chunkID {
+				return &e, nil
+			}
+		}
+	}
+	return nil, errors.New("embedding not found")
+}
+
+// Models lists the models that have stored embeddings.
+func (r *InMemoryEmbeddingRepository) Models() ([]models.EmbeddingModel, error) {
+	var list []models.EmbeddingModel
+	for model := range r.embeddings {
+		list = append(list, model)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Dimension < b.Dimension
+	})
+	return list, nil
+}
+
+// DeleteEmbeddings removes the embeddings of a document under every model.
+func (r *InMemoryEmbeddingRepository) DeleteEmbeddings(documentID int64) error {
+	for model, byDocument := range r.embeddings {
+		delete(byDocument, documentID)
+		if len(byDocument) == 0 {
+			delete(r.embeddings, model)
+		}
+	}
+	return nil
+}
+
+// DeleteModel removes every embedding made with model, once a migration
+// away from it is complete.
+func (r *InMemoryEmbeddingRepository) DeleteModel(model models.EmbeddingModel) error {
+	delete(r.embeddings, model)
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package retrieval combines the rankings of several retrievers, such as a
+// lexical and a vector index, into one. Every fused result records what
+// each retriever contributed to its score, so a surprising ranking can be
+// traced back to the retriever responsible.
+package retrieval
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Method is a way of fusing rankings.
+type Method string
+
+const (
+	// RRF is reciprocal rank fusion: a result scores weight/(k+rank) in each
+	// ranking it appears in. It ignores the retrievers' raw scores, which
+	// are on different scales, and is the default.
+	RRF Method = "rrf"
+	// Weighted blends raw scores after min-max normalizing each ranking to
+	// [0, 1], so a result scores weight*normalized score in each ranking.
+	// It keeps how far apart results are, at the cost of trusting scores.
+	Weighted Method = "weighted"
+)
+
+// DefaultRRFK is the customary rank offset for RRF. Larger values flatten
+// the difference between top and lower ranks.
+const DefaultRRFK = 60
+
+// ParseMethod returns the method named s, or RRF if s is empty.
+func ParseMethod(s string) (Method, error) {
+	switch Method(s) {
+	case "", RRF:
+		return RRF, nil
+	case Weighted:
+		return Weighted, nil
+	}
+	return "", fmt.Errorf("unknown fusion method %q", s)
+}
+
+// Hit is one result of a retriever. Terms optionally breaks Score down by
+// query term.
+type Hit struct {
+	ID    int64
+	Score float64
+	Terms map[string]float64
+}
+
+// Ranking is the results of one retriever, best first.
+type Ranking struct {
+	Retriever string
+	Weight    float64
+	Hits      []Hit
+}
+
+// Contribution is what one retriever added to a fused result.
+type Contribution struct {
+	Retriever string `json:"retriever"`
+	// Rank is the 1-based position of the result in the retriever's ranking.
+	Rank int `json:"rank"`
+	// Score is the retriever's raw score, such as BM25 or cosine similarity.
+	Score float64 `json:"score"`
+	// Normalized is Score scaled to [0, 1] within the ranking. It is only
+	// set for Weighted fusion.
+	Normalized float64 `json:"normalized,omitempty"`
+	Weight     float64 `json:"weight"`
+	// Contribution is the part of the fused score from this retriever.
+	Contribution float64            `json:"contribution"`
+	Terms        map[string]float64 `json:"terms,omitempty"`
+}
+
+// Result is a fused result. Score is the sum of the contributions.
+type Result struct {
+	ID            int64          `json:"id"`
+	Score         float64        `json:"score"`
+	Contributions []Contribution `json:"contributions"`
+}
+
+// Fuse merges rankings with method, best first. k is the RRF rank offset,
+// or DefaultRRFK if not positive; it is ignored by Weighted. Ties are
+// broken by ID so the order is stable.
+func Fuse(method Method, rankings []Ranking, k int) []Result {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+
+	byID := make(map[int64]*Result)
+	var results []*Result
+	for _, ranking := range rankings {
+		low, high := scoreRange(ranking.Hits)
+		for i, hit := range ranking.Hits {
+			c := Contribution{
+				Retriever: ranking.Retriever,
+				Rank:      i + 1,
+				Score:     hit.Score,
+				Weight:    ranking.Weight,
+				Terms:     hit.Terms,
+			}
+			if method == Weighted {
+				c.Normalized = 1
+				if high > low {
+					c.Normalized = (hit.Score - low) / (high - low)
+				}
+				c.Contribution = ranking.Weight * c.Normalized
+			} else {
+				c.Contribution = ranking.Weight / float64(k+i+1)
+			}
+
+			r, ok := byID[hit.ID]
+			if !ok {
+				r = &Result{ID: hit.ID}
+				byID[hit.ID] = r
+				results = append(results, r)
+			}
+			r.Score += c.Contribution
+			r.Contributions = append(r.Contributions, c)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	fused := make([]Result, len(results))
+	for i, r := range results {
+		fused[i] = *r
+	}
+	return fused
+}
+
+// scoreRange returns the lowest and highest score among hits.
+func scoreRange(hits []Hit) (low, high float64) {
+	for i, hit := range hits {
+		if i == 0 || hit.Score < low {
+			low = hit.Score
+		}
+		if i == 0 || hit.Score > high {
+			high = hit.Score
+		}
+	}
+	return low, high
+}
@@ -0,0 +1,149 @@
+package retrieval
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFuse(t *testing.T) {
+	lexical := Ranking{Retriever: "lexical", Weight: 1, Hits: []Hit{
+		{ID: 1, Score: 12, Terms: map[string]float64{"ohm": 12}},
+		{ID: 2, Score: 8},
+		{ID: 3, Score: 2},
+	}}
+	vector := Ranking{Retriever: "vector", Weight: 1, Hits: []Hit{
+		{ID: 3, Score: 0.9},
+		{ID: 4, Score: 0.7},
+		{ID: 1, Score: 0.5},
+	}}
+
+	tests := []struct {
+		name     string
+		method   Method
+		rankings []Ranking
+		k        int
+		want     []int64
+		scores   []float64
+	}{
+		{
+			name:     "rrf one ranking keeps its order",
+			method:   RRF,
+			rankings: []Ranking{lexical},
+			k:        60,
+			want:     []int64{1, 2, 3},
+			scores:   []float64{1.0 / 61, 1.0 / 62, 1.0 / 63},
+		},
+		{
+			name:     "rrf sums ranks across rankings",
+			method:   RRF,
+			rankings: []Ranking{lexical, vector},
+			k:        60,
+			want:     []int64{1, 3, 2, 4},
+			scores:   []float64{1.0/61 + 1.0/63, 1.0/63 + 1.0/61, 1.0 / 62, 1.0 / 62},
+		},
+		{
+			name:     "rrf default k",
+			method:   "",
+			rankings: []Ranking{lexical},
+			k:        0,
+			want:     []int64{1, 2, 3},
+			scores:   []float64{1.0 / 61, 1.0 / 62, 1.0 / 63},
+		},
+		{
+			name:   "rrf weights",
+			method: RRF,
+			rankings: []Ranking{
+				{Retriever: "lexical", Weight: 0.5, Hits: lexical.Hits},
+				{Retriever: "vector", Weight: 2, Hits: vector.Hits},
+			},
+			k:      1,
+			want:   []int64{3, 1, 4, 2},
+			scores: []float64{2.0/2 + 0.5/4, 0.5/2 + 2.0/4, 2.0 / 3, 0.5 / 3},
+		},
+		{
+			name:     "weighted normalizes each ranking",
+			method:   Weighted,
+			rankings: []Ranking{lexical, vector},
+			want:     []int64{1, 3, 2, 4},
+			scores:   []float64{1 + 0, 0 + 1, 0.6, 0.5},
+		},
+		{
+			name:   "weighted ranking of equal scores",
+			method: Weighted,
+			rankings: []Ranking{{Retriever: "vector", Weight: 0.5, Hits: []Hit{
+				{ID: 9, Score: 0.4}, {ID: 7, Score: 0.4},
+			}}},
+			want:   []int64{7, 9},
+			scores: []float64{0.5, 0.5},
+		},
+		{
+			name:     "no rankings",
+			method:   RRF,
+			rankings: nil,
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fuse(tt.method, tt.rankings, tt.k)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(got), len(tt.want))
+			}
+			for i, r := range got {
+				if r.ID != tt.want[i] {
+					t.Errorf("result %d is %d, want %d", i, r.ID, tt.want[i])
+				}
+				if math.Abs(r.Score-tt.scores[i]) > 1e-9 {
+					t.Errorf("result %d scores %v, want %v", r.ID, r.Score, tt.scores[i])
+				}
+				sum := 0.0
+				for _, c := range r.Contributions {
+					sum += c.Contribution
+				}
+				if math.Abs(sum-r.Score) > 1e-9 {
+					t.Errorf("result %d contributions sum to %v, not its score %v", r.ID, sum, r.Score)
+				}
+			}
+		})
+	}
+}
+
+func TestFuseContributions(t *testing.T) {
+	got := Fuse(Weighted, []Ranking{
+		{Retriever: "lexical", Weight: 2, Hits: []Hit{
+			{ID: 1, Score: 10, Terms: map[string]float64{"ohm": 6, "law": 4}},
+			{ID: 2, Score: 5},
+		}},
+	}, 0)
+	want := Contribution{Retriever: "lexical", Rank: 2, Score: 5, Normalized: 0, Weight: 2, Contribution: 0}
+	if len(got) != 2 || len(got[1].Contributions) != 1 {
+		t.Fatalf("results = %+v", got)
+	}
+	if c := got[1].Contributions[0]; c.Retriever != want.Retriever || c.Rank != want.Rank || c.Score != want.Score ||
+		c.Normalized != want.Normalized || c.Weight != want.Weight || c.Contribution != want.Contribution {
+		t.Errorf("contribution = %+v, want %+v", c, want)
+	}
+	if terms := got[0].Contributions[0].Terms; terms["ohm"] != 6 || terms["law"] != 4 {
+		t.Errorf("terms = %v, want the hit's terms", terms)
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Method
+		wantErr bool
+	}{
+		{"", RRF, false},
+		{"rrf", RRF, false},
+		{"weighted", Weighted, false},
+		{"borda", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMethod(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMethod(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
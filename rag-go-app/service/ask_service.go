@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"rag-go-app/chunking"
+	"rag-go-app/lexical"
+	"rag-go-app/llm"
+	"rag-go-app/models"
+	"rag-go-app/repositories"
+)
+
+const (
+	// DefaultAskSources and MaxAskSources bound the chunks an answer is
+	// drawn from.
+	DefaultAskSources = 5
+	MaxAskSources     = 20
+	// maxQuoteLength is the longest quote given for a citation, in bytes.
+	maxQuoteLength = 300
+)
+
+// notFoundAnswer is the answer when the notes do not hold one.
+const notFoundAnswer = "I could not find the answer to this in your notes."
+
+// ErrProviderFailed is returned when the language model could not be
+// reached or failed to answer.
+var ErrProviderFailed = errors.New("language model request failed")
+
+// citationMarker matches the source numbers a model cites, as [1] or [1, 3],
+// leadingCitations the markers at the start of a text, and
+// trailingCitations those at the end of a sentence, before or after its
+// final punctuation.
+var (
+	citationMarker    = regexp.MustCompile(`\[\s*(\d+(?:\s*,\s*\d+)*)\s*\]`)
+	leadingCitations  = regexp.MustCompile(`^(?:\s*\[\s*\d+(?:\s*,\s*\d+)*\s*\])+`)
+	trailingCitations = regexp.MustCompile(`((?:\s*\[\s*\d+(?:\s*,\s*\d+)*\s*\])+)\s*([.?!।]?)\s*$`)
+)
+
+type AskService struct {
+	retrieval *RetrievalService
+	docs      repositories.DataRepository
+	provider  llm.Provider
+}
+
+// NewAskService creates a new instance of AskService, which answers
+// questions with provider from the chunks retrieval finds, and describes
+// the cited chunks with the documents in docs.
+func NewAskService(retrieval *RetrievalService, docs repositories.DataRepository, provider llm.Provider) *AskService {
+	return &AskService{retrieval: retrieval, docs: docs, provider: provider}
+}
+
+// AskRequest is a question about the notes, optionally limited to
+// documents matching Filter. Sources is the number of chunks the answer is
+// drawn from, DefaultAskSources if not positive.
+type AskRequest struct {
+	Question string
+	Filter   RetrievalFilter
+	Sources  int
+}
+
+// Answer is a grounded answer. Found is false when the notes did not hold
+// an answer, or the model cited none of its sentences, in which case
+// Sentences is empty.
+type Answer struct {
+	Question  string           `json:"question"`
+	Answer    string           `json:"answer"`
+	Found     bool             `json:"found"`
+	Sentences []AnswerSentence `json:"sentences"`
+	Sources   []AnswerSource   `json:"sources"`
+	Model     string           `json:"model"`
+}
+
+// AnswerSentence is one sentence of an answer and the chunks it cites.
+// Sentences the model gave without a valid citation are left out of the
+// answer, so every sentence has at least one.
+type AnswerSentence struct {
+	Text      string           `json:"text"`
+	Citations []AnswerCitation `json:"citations"`
+}
+
+// AnswerCitation points a sentence at the chunk supporting it. Quote is
+// the sentence of the chunk closest to the answer sentence, and Page the
+// page it is on, when known.
+type AnswerCitation struct {
+	Source     int    `json:"source"`
+	ChunkID    int64  `json:"chunk_id"`
+	DocumentID int64  `json:"document_id"`
+	Page       int    `json:"page,omitempty"`
+	Quote      string `json:"quote"`
+}
+
+// AnswerSource is a chunk the model was given, numbered as it is cited.
+type AnswerSource struct {
+	Number     int    `json:"number"`
+	ChunkID    int64  `json:"chunk_id"`
+	DocumentID int64  `json:"document_id"`
+	Title      string `json:"title"`
+	Page       int    `json:"page,omitempty"`
+	PageEnd    int    `json:"page_end,omitempty"`
+}
+
+// Ask retrieves the chunks most relevant to a question, asks the model to
+// answer from them alone, and ties each sentence of the reply to the chunks
+// it cites, dropping sentences that cite none.
+func (s *AskService) Ask(ctx context.Context, req AskRequest) (*Answer, error) {
+	limit := req.Sources
+	if limit <= 0 {
+		limit = DefaultAskSources
+	}
+	limit = min(limit, MaxAskSources)
+
+	retrieved, err := s.retrieval.Search(ctx, RetrievalRequest{Query: req.Question, Filter: req.Filter, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	answer := &Answer{
+		Question:  req.Question,
+		Answer:    notFoundAnswer,
+		Sentences: []AnswerSentence{},
+		Sources:   []AnswerSource{},
+		Model:     s.provider.Name(),
+	}
+	if len(retrieved) == 0 {
+		return answer, nil
+	}
+
+	chunks := make([]models.Chunk, len(retrieved))
+	docs := make(map[int64]*models.Document)
+	sources := make([]llm.Source, len(retrieved))
+	for i, r := range retrieved {
+		chunks[i] = r.Chunk
+		doc, ok := docs[r.Chunk.DocumentID]
+		if !ok {
+			doc, err = s.docs.FindDocumentByID(r.Chunk.DocumentID)
+			if err != nil {
+				return nil, fmt.Errorf("loading document %d failed: %w", r.Chunk.DocumentID, err)
+			}
+			if doc == nil {
+				return nil, fmt.Errorf("document %d not found", r.Chunk.DocumentID)
+			}
+			docs[r.Chunk.DocumentID] = doc
+		}
+		sources[i] = llm.Source{Number: i + 1, Label: sourceLabel(doc, r.Chunk), Text: r.Chunk.Text}
+		answer.Sources = append(answer.Sources, AnswerSource{
+			Number:     i + 1,
+			ChunkID:    r.Chunk.ID,
+			DocumentID: r.Chunk.DocumentID,
+			Title:      doc.Metadata.Title,
+			Page:       r.Chunk.PageStart,
+			PageEnd:    r.Chunk.PageEnd,
+		})
+	}
+
+	reply, err := s.provider.Complete(ctx, llm.GroundedRequest(req.Question, sources))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProviderFailed, err)
+	}
+	reply = strings.TrimSpace(reply)
+	if reply == "" || reply == llm.NotFound {
+		return answer, nil
+	}
+
+	analyzer := s.retrieval.Analyzer()
+	var texts []string
+	for _, sentence := range splitAnswer(reply) {
+		text, numbers := stripCitations(sentence)
+		if text == "" {
+			continue
+		}
+		cited := AnswerSentence{Text: text, Citations: []AnswerCitation{}}
+		for _, n := range numbers {
+			if n < 1 || n > len(chunks) {
+				continue
+			}
+			chunk := chunks[n-1]
+			quote := bestQuote(analyzer, text, chunk.Text)
+			if quote == "" {
+				// The source shares no terms with the sentence, so
+				// nothing in it can be quoted in support
+				continue
+			}
+			cited.Citations = append(cited.Citations, AnswerCitation{
+				Source:     n,
+				ChunkID:    chunk.ID,
+				DocumentID: chunk.DocumentID,
+				Page:       quotePage(docs[chunk.DocumentID], chunk, quote),
+				Quote:      shortenQuote(quote),
+			})
+		}
+		// A sentence no source supports is not part of a grounded answer
+		if len(cited.Citations) == 0 {
+			continue
+		}
+		answer.Sentences = append(answer.Sentences, cited)
+		texts = append(texts, text)
+	}
+	if len(texts) > 0 {
+		answer.Answer = strings.Join(texts, " ")
+		answer.Found = true
+	}
+	return answer, nil
+}
+
+// sourceLabel describes where a chunk comes from, for the model.
+func sourceLabel(doc *models.Document, chunk models.Chunk) string {
+	label := doc.Metadata.Title
+	if label == "" {
+		label = "Document " + strconv.FormatInt(doc.ID, 10)
+	}
+	switch {
+	case chunk.PageStart > 0 && chunk.PageEnd > chunk.PageStart:
+		label += fmt.Sprintf(", pages %d-%d", chunk.PageStart, chunk.PageEnd)
+	case chunk.PageStart > 0:
+		label += fmt.Sprintf(", page %d", chunk.PageStart)
+	}
+	return label
+}
+
+// splitAnswer splits a reply into sentences, keeping citations written
+// after a sentence's full stop with that sentence.
+func splitAnswer(reply string) []string {
+	var sentences []string
+	for _, span := range chunking.SentenceSpans(reply) {
+		sentence := reply[span.Start:span.End]
+		if len(sentences) > 0 {
+			if markers := leadingCitations.FindString(sentence); markers != "" {
+				sentences[len(sentences)-1] += markers
+				sentence = sentence[len(markers):]
+			}
+		}
+		if sentence = strings.TrimSpace(sentence); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+	}
+	return sentences
+}
+
+// stripCitations removes the citation markers from the end of a sentence,
+// returning the tidied text and the source numbers cited, each once.
+// Bracketed numbers elsewhere, as in "as shown in [3]", may be copied from
+// a source and are left in the text.
+func stripCitations(sentence string) (string, []int) {
+	text := strings.TrimSpace(sentence)
+	var markers []string
+	for {
+		m := trailingCitations.FindStringSubmatchIndex(text)
+		if m == nil {
+			break
+		}
+		markers = append([]string{text[m[2]:m[3]]}, markers...)
+		text = text[:m[0]] + text[m[4]:m[5]]
+	}
+
+	var numbers []int
+	seen := make(map[int]bool)
+	for _, m := range citationMarker.FindAllStringSubmatch(strings.Join(markers, ""), -1) {
+		for _, field := range strings.Split(m[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err == nil && !seen[n] {
+				seen[n] = true
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(text), " "), numbers
+}
+
+// bestQuote returns the sentence of a chunk sharing the most terms with an
+// answer sentence, or "" if none share any.
+func bestQuote(analyzer lexical.Analyzer, sentence string, chunkText string) string {
+	terms := make(map[string]bool)
+	for _, t := range analyzer.Analyze(sentence) {
+		terms[t.Term] = true
+	}
+
+	best, bestScore := "", 0
+	for _, span := range chunking.SentenceSpans(chunkText) {
+		candidate := strings.Join(strings.Fields(chunkText[span.Start:span.End]), " ")
+		if candidate == "" {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, t := range analyzer.Analyze(candidate) {
+			if terms[t.Term] {
+				seen[t.Term] = true
+			}
+		}
+		if len(seen) > bestScore {
+			best, bestScore = candidate, len(seen)
+		}
+	}
+	return best
+}
+
+// quotePage returns the page of a chunk a quote is on: the chunk's page
+// when it has one, otherwise the first of its pages whose text holds the
+// quote, or its first page.
+func quotePage(doc *models.Document, chunk models.Chunk, quote string) int {
+	if chunk.PageEnd <= chunk.PageStart || doc == nil {
+		return chunk.PageStart
+	}
+	for _, page := range doc.Pages {
+		if page.Number < chunk.PageStart || page.Number > chunk.PageEnd {
+			continue
+		}
+		if strings.Contains(strings.Join(strings.Fields(page.Text), " "), quote) {
+			return page.Number
+		}
+	}
+	return chunk.PageStart
+}
+
+// shortenQuote cuts a quote longer than maxQuoteLength at a word boundary.
+func shortenQuote(quote string) string {
+	if len(quote) <= maxQuoteLength {
+		return quote
+	}
+	cut := strings.LastIndex(quote[:maxQuoteLength], " ")
+	if cut <= 0 {
+		cut = maxQuoteLength
+		for cut > 0 && !utf8.RuneStart(quote[cut]) {
+			cut--
+		}
+	}
+	return quote[:cut] + "…"
+}
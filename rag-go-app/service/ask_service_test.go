@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"rag-go-app/lexical"
+	"rag-go-app/llm"
+	"rag-go-app/models"
+	"rag-go-app/repositories"
+)
+
+// replyProvider answers every request with the same reply.
+type replyProvider string
+
+func (p replyProvider) Name() string { return "reply" }
+
+func (p replyProvider) Complete(ctx context.Context, req llm.Request) (string, error) {
+	return string(p), nil
+}
+
+// newAskService returns an AskService over a lexical index of texts, one
+// document and chunk each, with provider answering.
+func newAskService(t *testing.T, provider llm.Provider, texts ...string) *AskService {
+	t.Helper()
+	docs := repositories.NewInMemoryDataRepository()
+	chunks := repositories.NewInMemoryChunkRepository()
+	retrieval := NewRetrievalService(chunks, nil, lexical.NewIndex(lexical.English{}, lexical.DefaultBM25()), nil)
+	for _, text := range texts {
+		doc := &models.Document{}
+		doc.Metadata.Title = "Notes"
+		if err := docs.SaveDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+		c := []models.Chunk{{Text: text, PageStart: 2, PageEnd: 2}}
+		if err := chunks.SaveChunks(doc.ID, c); err != nil {
+			t.Fatal(err)
+		}
+		if err := retrieval.IndexDocument(doc, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewAskService(retrieval, docs, provider)
+}
+
+func TestAsk(t *testing.T) {
+	texts := []string{
+		"Volta built the first battery in 1800. It was a pile of zinc and copper discs.",
+		"Ohm's law relates voltage, current and resistance.",
+	}
+	tests := []struct {
+		name      string
+		provider  llm.Provider
+		question  string
+		wantFound bool
+		want      string
+		wantCites [][]int
+	}{
+		{
+			name:      "stub quotes the sources",
+			provider:  llm.Stub{MaxSentences: 1},
+			question:  "Who built the first battery?",
+			wantFound: true,
+			want:      "Volta built the first battery in 1800.",
+			wantCites: [][]int{{1}},
+		},
+		{
+			name:      "stub cites each sentence",
+			provider:  llm.Stub{},
+			question:  "Volta's battery of discs",
+			wantFound: true,
+			want:      "Volta built the first battery in 1800. It was a pile of zinc and copper discs.",
+			wantCites: [][]int{{1}, {1}},
+		},
+		{
+			name:     "not found",
+			provider: replyProvider(" NOT_FOUND\n"),
+			question: "Who built the first battery?",
+		},
+		{
+			name:      "not found mentioned in an answer",
+			provider:  replyProvider("Despite the NOT_FOUND marker, Volta built the battery. [1]"),
+			question:  "Who built the first battery?",
+			wantFound: true,
+			want:      "Despite the NOT_FOUND marker, Volta built the battery.",
+			wantCites: [][]int{{1}},
+		},
+		{
+			name:      "markers before the full stop",
+			provider:  replyProvider("Volta built the first battery [1]. It used zinc discs [1]."),
+			question:  "Who built the first battery?",
+			wantFound: true,
+			want:      "Volta built the first battery. It used zinc discs.",
+			wantCites: [][]int{{1}, {1}},
+		},
+		{
+			// "[3]" is copied text, not a citation of a third source
+			name:      "bracketed numbers inside a sentence are text",
+			provider:  replyProvider("Volta built the battery, as shown in [3], in 1800. [1]"),
+			question:  "Who built the first battery?",
+			wantFound: true,
+			want:      "Volta built the battery, as shown in [3], in 1800.",
+			wantCites: [][]int{{1}},
+		},
+		{
+			name:     "markers only inside a sentence",
+			provider: replyProvider("Volta [1] built the battery."),
+			question: "Who built the first battery?",
+		},
+		{
+			name:     "cited source shares nothing with the sentence",
+			provider: replyProvider("Ohm's law relates voltage and current. [1]"),
+			question: "Who built the first battery?",
+		},
+		{
+			name:      "uncited sentences are dropped",
+			provider:  replyProvider("Volta built it in 1800. [1] He was Italian. It used discs.[1, 1]"),
+			question:  "Who built the first battery?",
+			wantFound: true,
+			want:      "Volta built it in 1800. It used discs.",
+			wantCites: [][]int{{1}, {1}},
+		},
+		{
+			name:     "only invalid citations",
+			provider: replyProvider("Volta built it. [9]"),
+			question: "Who built the first battery?",
+		},
+		{
+			name:     "no citations",
+			provider: replyProvider("Volta built it."),
+			question: "Who built the first battery?",
+		},
+		{
+			name:     "nothing retrieved",
+			provider: replyProvider("Invented anyway. [1]"),
+			question: "photosynthesis",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAskService(t, tt.provider, texts...)
+			answer, err := s.Ask(context.Background(), AskRequest{Question: tt.question})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if answer.Found != tt.wantFound {
+				t.Fatalf("Found = %v, want %v (answer %q)", answer.Found, tt.wantFound, answer.Answer)
+			}
+			if !tt.wantFound {
+				if answer.Answer != notFoundAnswer || len(answer.Sentences) != 0 {
+					t.Errorf("answer = %q with %d sentences, want the not-found answer", answer.Answer, len(answer.Sentences))
+				}
+				return
+			}
+			if answer.Answer != tt.want {
+				t.Errorf("answer = %q, want %q", answer.Answer, tt.want)
+			}
+			if len(answer.Sentences) != len(tt.wantCites) {
+				t.Fatalf("got %d sentences, want %d", len(answer.Sentences), len(tt.wantCites))
+			}
+			for i, sentence := range answer.Sentences {
+				if len(sentence.Citations) != len(tt.wantCites[i]) {
+					t.Errorf("sentence %d cites %d sources, want %v", i, len(sentence.Citations), tt.wantCites[i])
+					continue
+				}
+				for j, c := range sentence.Citations {
+					if c.Source != tt.wantCites[i][j] {
+						t.Errorf("sentence %d cites source %d, want %d", i, c.Source, tt.wantCites[i][j])
+					}
+					source := answer.Sources[c.Source-1]
+					if c.ChunkID != source.ChunkID || c.DocumentID != source.DocumentID || c.Page != 2 || c.Quote == "" {
+						t.Errorf("citation %+v does not match source %+v", c, source)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStripCitations(t *testing.T) {
+	tests := []struct {
+		sentence string
+		want     string
+		numbers  []int
+	}{
+		{"Volta built it. [1]", "Volta built it.", []int{1}},
+		{"Volta built it [1, 2].", "Volta built it.", []int{1, 2}},
+		{"Volta built it.[2][1, 2]", "Volta built it.", []int{2, 1}},
+		{"Volta built it [1]. [2]", "Volta built it.", []int{1, 2}},
+		{"As shown in [3], Volta built it. [1]", "As shown in [3], Volta built it.", []int{1}},
+		{"Volta [1] built it.", "Volta [1] built it.", nil},
+		{"Volta built it.", "Volta built it.", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sentence, func(t *testing.T) {
+			text, numbers := stripCitations(tt.sentence)
+			if text != tt.want || !slices.Equal(numbers, tt.numbers) {
+				t.Errorf("stripCitations() = %q, %v, want %q, %v", text, numbers, tt.want, tt.numbers)
+			}
+		})
+	}
+}
+
+func TestBestQuote(t *testing.T) {
+	chunk := "Volta built the first battery in 1800. It was a pile of zinc and copper discs."
+	analyzer := lexical.English{}
+	if got, want := bestQuote(analyzer, "The battery used copper and zinc.", chunk), "It was a pile of zinc and copper discs."; got != want {
+		t.Errorf("bestQuote() = %q, want %q", got, want)
+	}
+	if got := bestQuote(analyzer, "Ohm's law relates voltage and current.", chunk); got != "" {
+		t.Errorf("bestQuote() with no shared terms = %q, want none", got)
+	}
+}
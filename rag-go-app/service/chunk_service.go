@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+
+	"rag-go-app/chunking"
+	"rag-go-app/models"
+	"rag-go-app/repositories"
+)
+
+type ChunkService struct {
+	repo       repositories.ChunkRepository
+	chunker    *chunking.Chunker
+	embeddings *EmbeddingService
+	retrieval  *RetrievalService
+}
+
+// NewChunkService creates a new instance of ChunkService. Documents are
+// split with the strategy chunker picks for their content type, and the
+// chunks embedded with embeddings and indexed for search in retrieval.
+// embeddings and retrieval may be nil to skip embedding and indexing.
+func NewChunkService(repo repositories.ChunkRepository, chunker *chunking.Chunker, embeddings *EmbeddingService, retrieval *RetrievalService) *ChunkService {
+	return &ChunkService{repo: repo, chunker: chunker, embeddings: embeddings, retrieval: retrieval}
+}
+
+// ChunkDocument splits a saved document into chunks, replacing any it had
+// along with their embeddings, and embeds and indexes the new chunks.
+func (s *ChunkService) ChunkDocument(ctx context.Context, doc *models.Document, contentType string) ([]models.Chunk, error) {
+	chunks := s.chunker.Chunk(contentType, doc)
+	if err := s.repo.SaveChunks(doc.ID, chunks); err != nil {
+		return nil, err
+	}
+
+	if s.embeddings != nil {
+		// Chunk IDs change on every save, so embeddings of the old chunks
+		// would never be found again
+		if err := s.embeddings.DeleteEmbeddings(doc.ID); err != nil {
+			return nil, err
+		}
+		if err := s.embeddings.EmbedChunks(ctx, doc.ID, chunks); err != nil {
+			return nil, err
+		}
+	}
+	if s.retrieval != nil {
+		if err := s.retrieval.IndexDocument(doc, chunks); err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+// RefreshDocument brings the search filters up to date after a document's
+// metadata is edited.
+func (s *ChunkService) RefreshDocument(doc *models.Document) {
+	if s.retrieval != nil {
+		s.retrieval.RefreshDocument(doc)
+	}
+}
+
+// RemoveDocument deletes the chunks of a document with their embeddings and
+// removes them from search.
+func (s *ChunkService) RemoveDocument(documentID int64) error {
+	if s.retrieval != nil {
+		s.retrieval.RemoveDocument(documentID)
+	}
+	if s.embeddings != nil {
+		if err := s.embeddings.DeleteEmbeddings(documentID); err != nil {
+			return err
+		}
+	}
+	return s.repo.DeleteChunks(documentID)
+}
+
+// Chunks returns the chunks of a document in order.
+func (s *ChunkService) Chunks(documentID int64) ([]models.Chunk, error) {
+	return s.repo.FindChunksByDocument(documentID)
+}
+
+// Chunk retrieves a chunk by its ID.
+func (s *ChunkService) Chunk(id int64) (*models.Chunk, error) {
+	return s.repo.FindChunkByID(id)
+}
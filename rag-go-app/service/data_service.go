@@ -1,46 +1,213 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"strings"
 
 	"rag-go-app/models"
+	"rag-go-app/parsecache"
 	"rag-go-app/parser"
 	"rag-go-app/repositories"
+	"rag-go-app/utils"
 )
 
 type DataService struct {
-	repo      repositories.DataRepository
-	pdfParser *parser.PDFParser // Add PDFParser instance
+	repo    repositories.DataRepository
+	parsers *parser.Registry
+	cache   parsecache.Cache
+	chunks  *ChunkService
 }
 
-// NewDataService creates a new instance of DataService.
-func NewDataService(repo repositories.DataRepository, parser *parser.PDFParser) *DataService { // Add parser dependency
-	return &DataService{repo: repo, pdfParser: parser} // Initialize parser
+// NewDataService creates a new instance of DataService. Files are parsed
+// with the parsers in the given registry, and parse results are reused from
+// cache for files seen before. New documents are split into chunks by
+// chunks. cache and chunks may be nil to always parse and to skip chunking.
+func NewDataService(repo repositories.DataRepository, parsers *parser.Registry, cache parsecache.Cache, chunks *ChunkService) *DataService {
+	return &DataService{repo: repo, parsers: parsers, cache: cache, chunks: chunks}
 }
 
-// CreateDocument processes a file and creates a new document in the repository.
-func (s *DataService) CreateDocument(filePath string) (*models.Document, error) {
-	// Read the file content
-	data, err := os.ReadFile(filePath)
+// CreateDocument processes an uploaded file and creates a new document in
+// the repository.
+func (s *DataService) CreateDocument(file *models.File) (*models.Document, error) {
+	return s.CreateDocumentWithPassword(file, "")
+}
+
+// CreateDocumentWithPassword is like CreateDocument for PDFs that may be
+// encrypted. It returns a *parser.PasswordRequiredError when the password
+// is missing or wrong.
+func (s *DataService) CreateDocumentWithPassword(file *models.File, password string) (*models.Document, error) {
+	return s.CreateDocumentStream(context.Background(), file, password, nil)
+}
+
+// CreateDocumentStream is like CreateDocumentWithPassword, stopping when ctx
+// is done. The file is parsed in place rather than read into memory, and
+// onPage is called with each page as soon as it is extracted so later
+// stages can start before parsing finishes. onPage may be nil.
+func (s *DataService) CreateDocumentStream(ctx context.Context, file *models.File, password string, onPage func(models.Page) error) (*models.Document, error) {
+	contentType, err := utils.GetContentType(file.Filename)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse the PDF content
-	doc, err := s.pdfParser.Parse(data) // Use the Parse method
+	f, err := os.Open(file.Path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("reading file %d failed: %w", file.ID, withoutPath(err))
 	}
+	defer f.Close()
 
-	// Save the document to the repository
-	if err := s.repo.SaveDocument(doc); err != nil {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading file %d failed: %w", file.ID, withoutPath(err))
+	}
+
+	if limit := s.parsers.Limits().MaxInputBytes; limit > 0 && info.Size() > limit {
+		return nil, &parser.LimitError{Limit: parser.LimitInputBytes, Value: info.Size(), Max: limit}
+	}
+
+	// A cached result is emitted page by page, or as a single page with
+	// the full text for formats without pages
+	var key parsecache.Key
+	if s.cache != nil {
+		name, version := s.parsers.Identity(contentType)
+		key, err = parsecache.NewKeyFromReader(io.NewSectionReader(f, 0, info.Size()), name, version)
+		if err != nil {
+			return nil, err
+		}
+		key = key.WithSecret(password)
+	}
+	doc, ok := s.cachedDocument(key)
+	if ok {
+		if err := emitPages(doc, onPage); err != nil {
+			return nil, err
+		}
+	} else {
+		opts := parser.ParseOptions{Password: password, Limits: s.parsers.Limits()}
+		doc, err = s.parsers.ParseStream(ctx, contentType, f, info.Size(), opts, onPage)
+		if err != nil {
+			return nil, err
+		}
+		s.cacheDocument(key, doc)
+	}
+
+	setSource(doc, file)
+
+	if err := s.saveDocument(ctx, doc, contentType); err != nil {
 		return nil, err
 	}
 
 	return doc, nil
 }
 
+// saveDocument saves a new document and chunks it. A document that fails
+// to chunk or embed is deleted again, so that a failed upload does not leave
+// behind a document search cannot find.
+func (s *DataService) saveDocument(ctx context.Context, doc *models.Document, contentType string) error {
+	if err := s.repo.SaveDocument(doc); err != nil {
+		return err
+	}
+	if err := s.chunkDocument(ctx, doc, contentType); err != nil {
+		s.discardDocument(doc.ID)
+		return err
+	}
+	return nil
+}
+
+// discardDocument deletes a document that could not be indexed, along with
+// whatever of its chunks and embeddings were stored. Failures are logged.
+func (s *DataService) discardDocument(id int64) {
+	if s.chunks != nil {
+		if err := s.chunks.RemoveDocument(id); err != nil {
+			log.Printf("Failed to remove chunks of document %d: %v", id, err)
+		}
+	}
+	if err := s.repo.DeleteDocument(id); err != nil {
+		log.Printf("Failed to remove document %d: %v", id, err)
+	}
+}
+
+// chunkDocument splits a saved document into chunks, and embeds them, when
+// chunking is enabled.
+func (s *DataService) chunkDocument(ctx context.Context, doc *models.Document, contentType string) error {
+	if s.chunks == nil {
+		return nil
+	}
+	_, err := s.chunks.ChunkDocument(ctx, doc, contentType)
+	return err
+}
+
+// setSource records the file a document was parsed from, and its type.
+func setSource(doc *models.Document, file *models.File) {
+	doc.FileID = file.ID
+	if fileType, err := utils.GetFileType(file.Filename); err == nil {
+		doc.Metadata.FileType = strings.TrimPrefix(fileType, ".")
+	}
+}
+
+// documentContentType returns the content type of the file doc was parsed
+// from, or "" if it is not known, in which case the document is chunked
+// with the default strategy.
+func documentContentType(doc *models.Document) string {
+	if doc.Metadata.FileType == "" {
+		return ""
+	}
+	contentType, err := utils.GetContentType("." + doc.Metadata.FileType)
+	if err != nil {
+		return ""
+	}
+	return contentType
+}
+
+// emitPages calls onPage with each page of doc.
+func emitPages(doc *models.Document, onPage func(models.Page) error) error {
+	if onPage == nil {
+		return nil
+	}
+	if len(doc.Pages) == 0 {
+		return onPage(models.Page{Number: 1, Text: doc.Text})
+	}
+	for _, page := range doc.Pages {
+		if err := onPage(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cachedDocument returns the cached parse result for key, if any. Cache
+// failures are logged and treated as a miss.
+func (s *DataService) cachedDocument(key parsecache.Key) (*models.Document, bool) {
+	if s.cache == nil {
+		return nil, false
+	}
+	doc, ok, err := s.cache.Get(key)
+	if err != nil {
+		log.Printf("Failed to read parse cache: %v", err)
+		return nil, false
+	}
+	return doc, ok
+}
+
+// cacheDocument stores a fresh parse result before it is saved and given
+// an ID. Cache failures are logged and otherwise ignored.
+func (s *DataService) cacheDocument(key parsecache.Key, doc *models.Document) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Put(key, doc); err != nil {
+		log.Printf("Failed to write parse cache: %v", err)
+	}
+}
+
+// Parsers lists the parsers available to the service.
+func (s *DataService) Parsers() []parser.ParserInfo {
+	return s.parsers.List()
+}
+
 // GetDocument retrieves a document by its ID.
 func (s *DataService) GetDocument(id int64) (*models.Document, error) {
 	doc, err := s.repo.FindDocumentByID(id)
@@ -50,7 +217,9 @@ func (s *DataService) GetDocument(id int64) (*models.Document, error) {
 	return doc, nil
 }
 
-// UpdateDocument updates an existing document.
+// UpdateDocument updates an existing document. A change to the text
+// re-chunks and re-embeds the document; a change to the metadata alone
+// only updates the search filters.
 func (s *DataService) UpdateDocument(id int64, text string, metadata models.Metadata) error {
 	doc, err := s.repo.FindDocumentByID(id)
 	if err != nil {
@@ -60,11 +229,119 @@ func (s *DataService) UpdateDocument(id int64, text string, metadata models.Meta
 		return errors.New("document not found") // Handle the case where the document is not found
 	}
 
+	// The file type comes from the file, not the client
+	metadata.FileType = doc.Metadata.FileType
+
 	// Update the document
+	textChanged := text != doc.Text
 	if err := doc.Update(text, metadata); err != nil {
 		return err
 	}
 
 	// Save the updated document back to the repository
-	return s.repo.UpdateDocument(doc)
+	if err := s.repo.UpdateDocument(doc); err != nil {
+		return err
+	}
+	if textChanged {
+		if err := s.chunkDocument(context.Background(), doc, documentContentType(doc)); err != nil {
+			return fmt.Errorf("re-chunking document %d failed: %w", doc.ID, err)
+		}
+		return nil
+	}
+	s.refreshDocument(doc)
+	return nil
+}
+
+// FinalizeDocument validates a draft document in full and marks it
+// finalized. It fails while required fields are missing.
+func (s *DataService) FinalizeDocument(id int64) (*models.Document, error) {
+	doc, err := s.repo.FindDocumentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("document not found")
+	}
+
+	if err := doc.Finalize(); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateDocument(doc); err != nil {
+		return nil, err
+	}
+	s.refreshDocument(doc)
+	return doc, nil
+}
+
+// refreshDocument updates the search filters after doc's metadata changes.
+func (s *DataService) refreshDocument(doc *models.Document) {
+	if s.chunks != nil {
+		s.chunks.RefreshDocument(doc)
+	}
+}
+
+// FlaggedDocument summarises a document with pages whose text extraction
+// needs review.
+type FlaggedDocument struct {
+	DocumentID int64  `json:"document_id"`
+	FileID     int64  `json:"file_id"`
+	Title      string `json:"title"`
+	Pages      []int  `json:"pages"`
+}
+
+// FlaggedDocuments lists documents with at least one page that failed
+// extraction or produced low-quality text, so they can be reprocessed.
+func (s *DataService) FlaggedDocuments() ([]FlaggedDocument, error) {
+	docs, err := s.repo.ListDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	flagged := []FlaggedDocument{}
+	for _, doc := range docs {
+		pages := doc.FlaggedPages()
+		if len(pages) == 0 {
+			continue
+		}
+		flagged = append(flagged, FlaggedDocument{
+			DocumentID: doc.ID,
+			FileID:     doc.FileID,
+			Title:      doc.Metadata.Title,
+			Pages:      pages,
+		})
+	}
+	return flagged, nil
+}
+
+// AuthoredDocument summarises a document credited to an author.
+type AuthoredDocument struct {
+	DocumentID int64           `json:"document_id"`
+	FileID     int64           `json:"file_id"`
+	Title      string          `json:"title"`
+	Authors    []models.Author `json:"authors"`
+}
+
+// DocumentsByAuthor lists documents with an author matching author, so that
+// "Sharma, R." finds papers by "Rahul Sharma" too. See models.Author.Matches.
+func (s *DataService) DocumentsByAuthor(author models.Author) ([]AuthoredDocument, error) {
+	docs, err := s.repo.ListDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []AuthoredDocument{}
+	for _, doc := range docs {
+		for _, a := range doc.Metadata.Authors {
+			if a.Matches(author) {
+				matched = append(matched, AuthoredDocument{
+					DocumentID: doc.ID,
+					FileID:     doc.FileID,
+					Title:      doc.Metadata.Title,
+					Authors:    doc.Metadata.Authors,
+				})
+				break
+			}
+		}
+	}
+	return matched, nil
 }
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"rag-go-app/models"
+	"rag-go-app/parsecache"
+	"rag-go-app/parser"
+	"rag-go-app/repositories"
+)
+
+// pagesParser emits the same pages for any SRT input and counts its calls.
+type pagesParser struct {
+	pages []string
+	calls int
+}
+
+func (p *pagesParser) Name() string                    { return "pages" }
+func (p *pagesParser) Version() string                 { return "1" }
+func (p *pagesParser) SupportedContentTypes() []string { return []string{"application/x-subrip"} }
+
+func (p *pagesParser) Parse(data []byte) (*models.Document, error) {
+	return nil, errors.New("pagesParser only streams")
+}
+
+func (p *pagesParser) ParseStream(ctx context.Context, r io.ReaderAt, size int64, opts parser.ParseOptions, emit func(models.Page) error) (*models.Document, error) {
+	p.calls++
+	doc := &models.Document{}
+	for i, text := range p.pages {
+		page := models.Page{Number: i + 1, Text: text}
+		doc.Pages = append(doc.Pages, page)
+		if emit != nil {
+			if err := emit(page); err != nil {
+				return nil, err
+			}
+		}
+	}
+	doc.DeriveText()
+	return doc, nil
+}
+
+func TestCreateDocumentStreamUsesCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lecture.srt")
+	if err := os.WriteFile(path, []byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file := &models.File{ID: 7, Filename: "lecture.srt", Path: path}
+
+	p := &pagesParser{pages: []string{"Page one text.", "Page two text."}}
+	parsers := parser.NewRegistry()
+	parsers.Register(p, parser.PriorityDefault)
+	cache, err := parsecache.NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDataService(repositories.NewInMemoryDataRepository(), parsers, cache, nil)
+
+	for i, password := range []string{"", "", "secret"} {
+		var emitted []string
+		doc, err := s.CreateDocumentStream(context.Background(), file, password, func(page models.Page) error {
+			emitted = append(emitted, page.Text)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("upload %d: %v", i, err)
+		}
+		if !slices.Equal(emitted, p.pages) {
+			t.Errorf("upload %d emitted %q, want %q", i, emitted, p.pages)
+		}
+		if doc.FileID != file.ID || doc.Metadata.FileType != "srt" {
+			t.Errorf("upload %d: file ID %d and type %q, want %d and srt", i, doc.FileID, doc.Metadata.FileType, file.ID)
+		}
+	}
+	// the second upload is served from the cache; the third has a password,
+	// so it cannot be
+	if p.calls != 2 {
+		t.Errorf("parser called %d times, want 2", p.calls)
+	}
+}
+
+func TestCreateDocumentStreamRejectsLargeFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lecture.srt")
+	if err := os.WriteFile(path, make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &pagesParser{pages: []string{"Page one text."}}
+	parsers := parser.NewRegistry()
+	parsers.Register(p, parser.PriorityDefault)
+	parsers.SetLimits(parser.Limits{MaxInputBytes: 99})
+	s := NewDataService(repositories.NewInMemoryDataRepository(), parsers, nil, nil)
+
+	_, err := s.CreateDocumentStream(context.Background(), &models.File{Filename: "lecture.srt", Path: path}, "", nil)
+	var limitErr *parser.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != parser.LimitInputBytes {
+		t.Fatalf("err = %v, want an input size LimitError", err)
+	}
+	if p.calls != 0 {
+		t.Errorf("parser called %d times, want 0", p.calls)
+	}
+}
+
+func TestEmitPages(t *testing.T) {
+	errStop := errors.New("stop")
+	tests := []struct {
+		name    string
+		doc     *models.Document
+		stopAt  int
+		want    []models.Page
+		wantErr error
+	}{
+		{
+			name: "pages in order",
+			doc:  &models.Document{Text: "a\n\nb", Pages: []models.Page{{Number: 1, Text: "a"}, {Number: 2, Text: "b"}}},
+			want: []models.Page{{Number: 1, Text: "a"}, {Number: 2, Text: "b"}},
+		},
+		{
+			name: "document without pages",
+			doc:  &models.Document{Text: "whole text"},
+			want: []models.Page{{Number: 1, Text: "whole text"}},
+		},
+		{
+			name:    "callback error stops",
+			doc:     &models.Document{Pages: []models.Page{{Number: 1, Text: "a"}, {Number: 2, Text: "b"}}},
+			stopAt:  1,
+			want:    []models.Page{{Number: 1, Text: "a"}},
+			wantErr: errStop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []models.Page
+			err := emitPages(tt.doc, func(page models.Page) error {
+				got = append(got, page)
+				if len(got) == tt.stopAt {
+					return errStop
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("emitted %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Number != tt.want[i].Number || got[i].Text != tt.want[i].Text {
+					t.Errorf("page %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if err := emitPages(&models.Document{Text: "x"}, nil); err != nil {
+		t.Errorf("nil callback: err = %v", err)
+	}
+}
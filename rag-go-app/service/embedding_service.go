@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rag-go-app/embedding"
+	"rag-go-app/models"
+	"rag-go-app/repositories"
+)
+
+type EmbeddingService struct {
+	repo      repositories.EmbeddingRepository
+	embedders []embedding.Embedder
+}
+
+// NewEmbeddingService creates a new instance of EmbeddingService. Chunks
+// are embedded with every embedder given, so a new model can be filled in
+// alongside the one in use before switching over. The first embedder is
+// the primary one, used for queries.
+func NewEmbeddingService(repo repositories.EmbeddingRepository, embedders ...embedding.Embedder) *EmbeddingService {
+	return &EmbeddingService{repo: repo, embedders: embedders}
+}
+
+// Primary returns the embedder used for queries, or nil if there is none.
+func (s *EmbeddingService) Primary() embedding.Embedder {
+	if len(s.embedders) == 0 {
+		return nil
+	}
+	return s.embedders[0]
+}
+
+// EmbedChunks embeds the chunks of a document with every embedder,
+// replacing the document's embeddings under those models.
+func (s *EmbeddingService) EmbedChunks(ctx context.Context, documentID int64, chunks []models.Chunk) error {
+	for _, e := range s.embedders {
+		if err := s.EmbedChunksWith(ctx, e, documentID, chunks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EmbedChunksWith embeds the chunks of a document with e alone, replacing
+// the document's embeddings under e's model. It is used to backfill a new
+// model over documents that were embedded before it was added.
+func (s *EmbeddingService) EmbedChunksWith(ctx context.Context, e embedding.Embedder, documentID int64, chunks []models.Chunk) error {
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	vectors, err := embedding.EmbedAll(ctx, e, texts)
+	if err != nil {
+		return fmt.Errorf("embedding document %d: %w", documentID, err)
+	}
+
+	// The model is read after embedding, since a remote embedder may only
+	// learn its dimension from the first response
+	model := e.Model()
+	now := time.Now()
+	embeddings := make([]models.Embedding, len(chunks))
+	for i, c := range chunks {
+		embeddings[i] = models.Embedding{
+			ChunkID:    c.ID,
+			DocumentID: documentID,
+			Model:      model,
+			Vector:     vectors[i],
+			CreatedAt:  now,
+		}
+	}
+	return s.repo.SaveEmbeddings(documentID, model, embeddings)
+}
+
+// Embeddings returns the embeddings of a document under model, in chunk
+// order.
+func (s *EmbeddingService) Embeddings(documentID int64, model models.EmbeddingModel) ([]models.Embedding, error) {
+	return s.repo.FindEmbeddings(documentID, model)
+}
+
+// Models lists the models that have stored embeddings.
+func (s *EmbeddingService) Models() ([]models.EmbeddingModel, error) {
+	return s.repo.Models()
+}
+
+// DeleteEmbeddings removes the embeddings of a document under every model.
+func (s *EmbeddingService) DeleteEmbeddings(documentID int64) error {
+	return s.repo.DeleteEmbeddings(documentID)
+}
+
+// DeleteModel removes every embedding made with model.
+func (s *EmbeddingService) DeleteModel(model models.EmbeddingModel) error {
+	return s.repo.DeleteModel(model)
+}
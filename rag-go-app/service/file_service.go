@@ -1,32 +1,74 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"time"
 
 	"rag-go-app/models"
 	"rag-go-app/repositories"
+	"rag-go-app/utils"
 )
 
+// ErrFileTooLarge is returned when an upload is larger than the service
+// accepts.
+var ErrFileTooLarge = errors.New("file is too large")
+
 type FileService struct {
-	repo repositories.FileRepository
+	repo     repositories.FileRepository
+	dir      string
+	maxBytes int64
+}
+
+// NewFileService creates a new instance of FileService. Uploaded content
+// is stored under dir, which is created if needed, and uploads larger than
+// maxBytes are rejected. maxBytes may be zero for no limit.
+func NewFileService(repo repositories.FileRepository, dir string, maxBytes int64) (*FileService, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating upload directory failed: %w", err)
+	}
+	return &FileService{repo: repo, dir: dir, maxBytes: maxBytes}, nil
 }
 
-// NewFileService creates a new instance of FileService.
-func NewFileService(repo repositories.FileRepository) *FileService {
-	return &FileService{repo: repo}
+// MaxBytes returns the largest upload accepted, or zero for no limit.
+func (s *FileService) MaxBytes() int64 {
+	return s.maxBytes
 }
 
-// UploadFile handles the file upload and creates a new File record.
-func (s *FileService) UploadFile(filename string, contentType string) (*models.File, error) {
-	if filename == "" || contentType == "" {
-		return nil, errors.New("filename and content type cannot be empty")
+// UploadFile stores the content of an uploaded file and creates a new File
+// record for it. The file is stored under a generated name, so filename
+// only has to carry a supported extension. contentType is derived from the
+// extension when empty.
+func (s *FileService) UploadFile(filename string, contentType string, content io.Reader) (*models.File, error) {
+	filename = filepath.Base(filename)
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
+		return nil, errors.New("filename cannot be empty")
+	}
+	fileType, err := utils.GetFileType(filename)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" || contentType == "application/octet-stream" {
+		if contentType, err = utils.GetContentType(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	path, err := s.store(content, fileType)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create a new File model
 	file := &models.File{
 		Filename:    filename,
+		Path:        path,
 		ContentType: contentType,
 		Processed:   false,
 		CreatedAt:   time.Now(),
@@ -35,12 +77,46 @@ func (s *FileService) UploadFile(filename string, contentType string) (*models.F
 
 	// Save the file record to the repository
 	if err := s.repo.SaveFile(file); err != nil {
+		os.Remove(path)
 		return nil, err
 	}
 
 	return file, nil
 }
 
+// store writes content to a new file under the upload directory with a
+// random name ending in ext, and returns its path.
+func (s *FileService) store(content io.Reader, ext string) (string, error) {
+	var name [16]byte
+	if _, err := rand.Read(name[:]); err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.dir, hex.EncodeToString(name[:])+ext)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("creating upload failed: %w", withoutPath(err))
+	}
+
+	if s.maxBytes > 0 {
+		content = io.LimitReader(content, s.maxBytes+1)
+	}
+	n, err := io.Copy(f, content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && s.maxBytes > 0 && n > s.maxBytes {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		if errors.Is(err, ErrFileTooLarge) {
+			return "", err
+		}
+		return "", fmt.Errorf("storing upload failed: %w", withoutPath(err))
+	}
+	return path, nil
+}
+
 // GetFile retrieves a file by its ID.
 func (s *FileService) GetFile(id int64) (*models.File, error) {
 	file, err := s.repo.FindFileByID(id)
@@ -50,17 +126,26 @@ func (s *FileService) GetFile(id int64) (*models.File, error) {
 	return file, nil
 }
 
-// DeleteFile deletes a file record by its ID.
+// DeleteFile deletes a file record by its ID, along with its content.
 func (s *FileService) DeleteFile(id int64) error {
 	file, err := s.repo.FindFileByID(id)
 	if err != nil {
 		return err
 	}
 
-	// Optionally, you can delete the actual file from the filesystem
-	if err := os.Remove(file.Filename); err != nil {
-		return err
+	if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing stored file failed: %w", withoutPath(err))
 	}
 
 	return s.repo.DeleteFile(id)
 }
+
+// withoutPath strips the file path from a filesystem error, so errors
+// passed on to clients do not reveal where files are stored.
+func withoutPath(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return fmt.Errorf("%s: %w", pathErr.Op, pathErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"rag-go-app/embedding"
+	"rag-go-app/lexical"
+	"rag-go-app/models"
+	"rag-go-app/repositories"
+	"rag-go-app/retrieval"
+	"rag-go-app/vectorindex"
+)
+
+// Names of the retrievers, as reported in result contributions.
+const (
+	RetrieverLexical = "lexical"
+	RetrieverVector  = "vector"
+)
+
+const (
+	// defaultRetrievalLimit is the number of results returned when a request
+	// does not say.
+	defaultRetrievalLimit = 10
+	// minRetrievalCandidates is the fewest results fetched from each
+	// retriever before fusion, so chunks ranked moderately by both
+	// retrievers can still rise to the top.
+	minRetrievalCandidates = 100
+)
+
+type RetrievalService struct {
+	chunks     repositories.ChunkRepository
+	embeddings *EmbeddingService
+	lexical    *lexical.Index
+	vectors    vectorindex.Index
+
+	// mu guards docs and chunkIDs, and keeps indexing from interleaving
+	// with searches so filters see consistent metadata.
+	mu       sync.RWMutex
+	docs     map[int64]models.Metadata
+	chunkIDs map[int64][]int64
+}
+
+// NewRetrievalService creates a new instance of RetrievalService. Chunk
+// texts are indexed in lex, and their embeddings under the primary model of
+// embeddings in vectors. vectors and embeddings may be nil to search
+// lexically only.
+func NewRetrievalService(chunks repositories.ChunkRepository, embeddings *EmbeddingService, lex *lexical.Index, vectors vectorindex.Index) *RetrievalService {
+	return &RetrievalService{
+		chunks:     chunks,
+		embeddings: embeddings,
+		lexical:    lex,
+		vectors:    vectors,
+		docs:       make(map[int64]models.Metadata),
+		chunkIDs:   make(map[int64][]int64),
+	}
+}
+
+// Analyzer returns the analyzer of the lexical index, so query terms can be
+// found in chunk text the way the index found them.
+func (s *RetrievalService) Analyzer() lexical.Analyzer {
+	return s.lexical.Analyzer()
+}
+
+// RetrievalFilter restricts retrieval to chunks of documents whose metadata
+// matches. Each non-empty field must match; within a field, any value may.
+type RetrievalFilter struct {
+	// Authors match as in models.Author.Matches, so "Sharma, R." finds
+	// documents by "Rahul Sharma".
+	Authors []string `json:"authors,omitempty"`
+	// YearFrom and YearTo bound the publication year, inclusive. Documents
+	// of unknown year never match a bound.
+	YearFrom int `json:"year_from,omitempty"`
+	YearTo   int `json:"year_to,omitempty"`
+	// Keywords, FileTypes and Collections match case-insensitively.
+	Keywords    []string `json:"keywords,omitempty"`
+	FileTypes   []string `json:"file_types,omitempty"`
+	Collections []string `json:"collections,omitempty"`
+}
+
+// IsEmpty reports whether f matches every document.
+func (f RetrievalFilter) IsEmpty() bool {
+	return len(f.Authors) == 0 && f.YearFrom == 0 && f.YearTo == 0 &&
+		len(f.Keywords) == 0 && len(f.FileTypes) == 0 && len(f.Collections) == 0
+}
+
+// Match reports whether a document with metadata passes f.
+func (f RetrievalFilter) Match(metadata models.Metadata) bool {
+	return f.match(metadata, parseAuthorNames(f.Authors))
+}
+
+// match is Match with f.Authors already parsed, so that a filter applied
+// to many documents parses them once.
+func (f RetrievalFilter) match(metadata models.Metadata, authors []models.Author) bool {
+	if len(authors) > 0 && !matchesAuthor(authors, metadata.Authors) {
+		return false
+	}
+	if f.YearFrom != 0 && (metadata.Year == 0 || metadata.Year < f.YearFrom) {
+		return false
+	}
+	if f.YearTo != 0 && (metadata.Year == 0 || metadata.Year > f.YearTo) {
+		return false
+	}
+	if len(f.Keywords) > 0 && !containsFold(f.Keywords, metadata.Keywords...) {
+		return false
+	}
+	if len(f.FileTypes) > 0 && !containsFold(f.FileTypes, metadata.FileType) {
+		return false
+	}
+	if len(f.Collections) > 0 && !containsFold(f.Collections, metadata.Collection) {
+		return false
+	}
+	return true
+}
+
+// parseAuthorNames parses the author names of a filter.
+func parseAuthorNames(names []string) []models.Author {
+	authors := make([]models.Author, len(names))
+	for i, name := range names {
+		authors[i] = models.ParseAuthorName(name)
+	}
+	return authors
+}
+
+// matchesAuthor reports whether any of authors matches any of wanted.
+func matchesAuthor(wanted []models.Author, authors []models.Author) bool {
+	for _, want := range wanted {
+		for _, a := range authors {
+			if a.Matches(want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsFold reports whether any of values equals any of wanted, ignoring
+// case.
+func containsFold(wanted []string, values ...string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if strings.EqualFold(strings.TrimSpace(w), v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RetrievalRequest describes a hybrid search.
+type RetrievalRequest struct {
+	Query  string
+	Filter RetrievalFilter
+	// Limit is the number of results to return, 10 if not positive.
+	Limit int
+	// Fusion is how the retrievers' rankings are combined, RRF if empty.
+	Fusion retrieval.Method
+	// LexicalWeight and VectorWeight scale each retriever's contribution.
+	// Both zero means equal weights; a retriever with zero weight when the
+	// other's is set is not run.
+	LexicalWeight float64
+	VectorWeight  float64
+}
+
+// RetrievedChunk is a chunk found by a hybrid search, with the
+// contribution of each retriever that found it.
+type RetrievedChunk struct {
+	Chunk         models.Chunk             `json:"chunk"`
+	Score         float64                  `json:"score"`
+	Contributions []retrieval.Contribution `json:"contributions"`
+}
+
+// IndexDocument makes the chunks of a document searchable, replacing any
+// chunks it had. The chunks must already be embedded.
+func (s *RetrievalService) IndexDocument(doc *models.Document, chunks []models.Chunk) error {
+	var vectors map[int64][]float32
+	if s.vectorsEnabled() {
+		embeddings, err := s.embeddings.Embeddings(doc.ID, s.embeddings.Primary().Model())
+		if err != nil {
+			return err
+		}
+		vectors = make(map[int64][]float32, len(embeddings))
+		for _, e := range embeddings {
+			vectors[e.ChunkID] = e.Vector
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeChunks(doc.ID)
+	s.docs[doc.ID] = doc.Metadata
+	// IDs are recorded first so a partly indexed document is still removed
+	// in full on the next attempt
+	ids := make([]int64, len(chunks))
+	for i, c := range chunks {
+		ids[i] = c.ID
+	}
+	s.chunkIDs[doc.ID] = ids
+
+	metadata := vectorindex.Metadata{"document_id": {strconv.FormatInt(doc.ID, 10)}}
+	for _, c := range chunks {
+		err := s.lexical.Insert(c.ID, c.Text, metadata)
+		if errors.Is(err, lexical.ErrExists) {
+			err = s.lexical.Update(c.ID, c.Text, metadata)
+		}
+		if err != nil {
+			return fmt.Errorf("indexing chunk %d failed: %w", c.ID, err)
+		}
+		vector, ok := vectors[c.ID]
+		if !ok {
+			continue
+		}
+		// A persistent vector index may hold entries for chunk IDs reused
+		// since it was written
+		err = s.vectors.Insert(c.ID, vector, metadata)
+		if errors.Is(err, vectorindex.ErrExists) {
+			err = s.vectors.Update(c.ID, vector, metadata)
+		}
+		if err != nil {
+			return fmt.Errorf("indexing chunk %d failed: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// RefreshDocument updates the metadata searches are filtered by after a
+// document is edited.
+func (s *RetrievalService) RefreshDocument(doc *models.Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.docs[doc.ID]; ok {
+		s.docs[doc.ID] = doc.Metadata
+	}
+}
+
+// RemoveDocument removes the chunks of a document from the indexes.
+func (s *RetrievalService) RemoveDocument(documentID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeChunks(documentID)
+	delete(s.docs, documentID)
+}
+
+// removeChunks removes the indexed chunks of a document. The caller holds
+// s.mu.
+func (s *RetrievalService) removeChunks(documentID int64) {
+	for _, id := range s.chunkIDs[documentID] {
+		s.lexical.Delete(id)
+		if s.vectors != nil {
+			s.vectors.Delete(id)
+		}
+	}
+	delete(s.chunkIDs, documentID)
+}
+
+// vectorsEnabled reports whether chunks are indexed by embedding.
+func (s *RetrievalService) vectorsEnabled() bool {
+	return s.vectors != nil && s.embeddings != nil && s.embeddings.Primary() != nil
+}
+
+// Search runs the lexical and vector retrievers in parallel, each limited
+// to chunks passing the request's filter, and fuses their rankings.
+func (s *RetrievalService) Search(ctx context.Context, req RetrievalRequest) ([]RetrievedChunk, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, errors.New("query is required")
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultRetrievalLimit
+	}
+	candidates := max(limit, minRetrievalCandidates)
+	lexicalWeight, vectorWeight := req.LexicalWeight, req.VectorWeight
+	if lexicalWeight == 0 && vectorWeight == 0 {
+		lexicalWeight, vectorWeight = 1, 1
+	}
+	if lexicalWeight < 0 || vectorWeight < 0 {
+		return nil, errors.New("retriever weights must not be negative")
+	}
+
+	// The query is embedded before s.mu is taken: the embedder may be a
+	// remote server, and indexing should not wait on it
+	var queryVector []float32
+	useVectors := vectorWeight > 0 && s.vectorsEnabled()
+	if useVectors {
+		vectors, err := embedding.EmbedAll(ctx, s.embeddings.Primary(), []string{req.Query})
+		if err != nil {
+			return nil, fmt.Errorf("embedding query failed: %w", err)
+		}
+		queryVector = vectors[0]
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filter := s.filter(req.Filter)
+	var (
+		wg       sync.WaitGroup
+		rankings [2]*retrieval.Ranking
+		errs     [2]error
+	)
+	if lexicalWeight > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rankings[0], errs[0] = s.searchLexical(req.Query, candidates, filter, lexicalWeight)
+		}()
+	}
+	if useVectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rankings[1], errs[1] = s.searchVectors(queryVector, candidates, filter, vectorWeight)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs[:]...); err != nil {
+		return nil, err
+	}
+
+	var lists []retrieval.Ranking
+	for _, r := range rankings {
+		if r != nil {
+			lists = append(lists, *r)
+		}
+	}
+	fused := retrieval.Fuse(req.Fusion, lists, retrieval.DefaultRRFK)
+
+	results := make([]RetrievedChunk, 0, min(limit, len(fused)))
+	for _, r := range fused {
+		if len(results) == limit {
+			break
+		}
+		chunk, err := s.chunks.FindChunkByID(r.ID)
+		if err != nil || chunk == nil {
+			// The chunk was replaced after the indexes were read
+			log.Printf("Skipping retrieved chunk %d: %v", r.ID, err)
+			continue
+		}
+		results = append(results, RetrievedChunk{Chunk: *chunk, Score: r.Score, Contributions: r.Contributions})
+	}
+	return results, nil
+}
+
+// filter adapts f to the indexes, whose entries carry only their document
+// ID. It returns nil when f is empty. The caller holds s.mu.
+func (s *RetrievalService) filter(f RetrievalFilter) vectorindex.Filter {
+	if f.IsEmpty() {
+		return nil
+	}
+	authors := parseAuthorNames(f.Authors)
+	return vectorindex.FilterFunc(func(m vectorindex.Metadata) bool {
+		ids := m["document_id"]
+		if len(ids) == 0 {
+			return false
+		}
+		id, err := strconv.ParseInt(ids[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		metadata, ok := s.docs[id]
+		return ok && f.match(metadata, authors)
+	})
+}
+
+// searchLexical ranks chunks by BM25.
+func (s *RetrievalService) searchLexical(query string, k int, filter vectorindex.Filter, weight float64) (*retrieval.Ranking, error) {
+	results, err := s.lexical.Search(query, k, filter)
+	if err != nil {
+		return nil, fmt.Errorf("lexical search failed: %w", err)
+	}
+	ranking := &retrieval.Ranking{Retriever: RetrieverLexical, Weight: weight, Hits: make([]retrieval.Hit, len(results))}
+	for i, r := range results {
+		ranking.Hits[i] = retrieval.Hit{ID: r.ID, Score: r.Score, Terms: r.Terms}
+	}
+	return ranking, nil
+}
+
+// searchVectors ranks chunks by similarity to the query's embedding under
+// the primary model.
+func (s *RetrievalService) searchVectors(query []float32, k int, filter vectorindex.Filter, weight float64) (*retrieval.Ranking, error) {
+	results, err := s.vectors.Search(query, k, filter)
+	if err != nil {
+		return nil, fmt.Errorf("vector search failed: %w", err)
+	}
+	ranking := &retrieval.Ranking{Retriever: RetrieverVector, Weight: weight, Hits: make([]retrieval.Hit, len(results))}
+	for i, r := range results {
+		ranking.Hits[i] = retrieval.Hit{ID: r.ID, Score: float64(r.Score)}
+	}
+	return ranking, nil
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rag-go-app/lexical"
+	"rag-go-app/models"
+	"rag-go-app/repositories"
+	"rag-go-app/retrieval"
+)
+
+const (
+	// DefaultSearchLimit and MaxSearchLimit bound the results per page.
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 100
+	// maxSearchResults is the most results a search ranks, and so the
+	// deepest a client can page and the most facets are counted over.
+	maxSearchResults = 1000
+	// snippetWidth is the length of result snippets, in bytes.
+	snippetWidth = 240
+	// maxFacetValues is the most values listed per facet.
+	maxFacetValues = 20
+)
+
+// ErrInvalidCursor is returned for a cursor that is malformed or was
+// issued for a different search.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	// searchCacheSize is the most searches whose rankings are kept for
+	// paging, and searchCacheTTL how long each is kept. A cursor for a
+	// search no longer kept runs the search again.
+	searchCacheSize = 64
+	searchCacheTTL  = 10 * time.Minute
+)
+
+type SearchService struct {
+	docs      repositories.DataRepository
+	retrieval *RetrievalService
+
+	mu       sync.Mutex
+	rankings map[uint64]*ranking
+	nextID   uint64
+}
+
+// ranking is the ranked results of a search, kept so that later pages
+// are read from it rather than by searching again, and so stay in the
+// order the first page was in.
+type ranking struct {
+	hits    []rankedHit
+	facets  Facets
+	created time.Time
+}
+
+// rankedHit is a search result without the chunk text, which is loaded
+// only for the page it is shown on.
+type rankedHit struct {
+	chunkID       int64
+	documentID    int64
+	score         float64
+	contributions []retrieval.Contribution
+}
+
+// NewSearchService creates a new instance of SearchService, which pages
+// through hybrid search results from retrieval and describes them with the
+// documents in docs.
+func NewSearchService(docs repositories.DataRepository, retrieval *RetrievalService) *SearchService {
+	return &SearchService{docs: docs, retrieval: retrieval, rankings: make(map[uint64]*ranking)}
+}
+
+// SearchRequest describes a page of a search. Cursor is empty for the first
+// page, and the NextCursor of the previous page after that.
+type SearchRequest struct {
+	Query         string
+	Filter        RetrievalFilter
+	Limit         int
+	Cursor        string
+	Fusion        retrieval.Method
+	LexicalWeight float64
+	VectorWeight  float64
+}
+
+// SearchHit is a chunk found by a search. Snippet is HTML: an excerpt of
+// the chunk with query terms wrapped in <mark> elements.
+type SearchHit struct {
+	ChunkID       int64                    `json:"chunk_id"`
+	DocumentID    int64                    `json:"document_id"`
+	Title         string                   `json:"title"`
+	Page          int                      `json:"page,omitempty"`
+	PageEnd       int                      `json:"page_end,omitempty"`
+	SectionPath   []string                 `json:"section_path,omitempty"`
+	Snippet       string                   `json:"snippet"`
+	Score         float64                  `json:"score"`
+	Contributions []retrieval.Contribution `json:"contributions"`
+}
+
+// FacetCount is the number of matching documents with a facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the documents behind all results of a search, not just
+// one page, by each facet's values, most common first.
+type Facets struct {
+	Authors   []FacetCount `json:"authors"`
+	Years     []FacetCount `json:"years"`
+	Keywords  []FacetCount `json:"keywords"`
+	FileTypes []FacetCount `json:"file_types"`
+}
+
+// SearchPage is one page of search results. Total counts every result up
+// to the deepest a search ranks; TotalCapped is set when it stopped there,
+// so there may be more results than Total.
+type SearchPage struct {
+	Results     []SearchHit `json:"results"`
+	Total       int         `json:"total"`
+	TotalCapped bool        `json:"total_capped"`
+	NextCursor  string      `json:"next_cursor,omitempty"`
+	Facets      Facets      `json:"facets"`
+}
+
+// Search returns a page of the chunks matching req, best first. The first
+// page runs the search and later pages read the ranking it made, so a
+// chunk neither repeats nor goes missing between pages.
+func (s *SearchService) Search(ctx context.Context, req SearchRequest) (*SearchPage, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+	fingerprint := searchFingerprint(req)
+	offset, id, err := decodeCursor(req.Cursor, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	r, ok := s.cachedRanking(id)
+	if !ok {
+		if r, err = s.rank(ctx, req); err != nil {
+			return nil, err
+		}
+		id = s.cacheRanking(r)
+	}
+
+	page := &SearchPage{
+		Results:     []SearchHit{},
+		Total:       len(r.hits),
+		TotalCapped: len(r.hits) == maxSearchResults,
+		Facets:      r.facets,
+	}
+	end := min(offset+limit, len(r.hits))
+	if offset < end {
+		analyzer := s.retrieval.Analyzer()
+		query := lexical.ParseQuery(analyzer, req.Query)
+		for _, hit := range r.hits[offset:end] {
+			chunk, err := s.retrieval.chunks.FindChunkByID(hit.chunkID)
+			if err != nil || chunk == nil {
+				// The document was edited or removed since the search ran
+				continue
+			}
+			doc, err := s.docs.FindDocumentByID(hit.documentID)
+			if err != nil || doc == nil {
+				continue
+			}
+			snippet := lexical.MakeSnippet(analyzer, chunk.Text, query, snippetWidth)
+			page.Results = append(page.Results, SearchHit{
+				ChunkID:       chunk.ID,
+				DocumentID:    chunk.DocumentID,
+				Title:         doc.Metadata.Title,
+				Page:          chunk.PageStart,
+				PageEnd:       chunk.PageEnd,
+				SectionPath:   chunk.SectionPath,
+				Snippet:       snippet.Mark("<mark>", "</mark>", html.EscapeString),
+				Score:         hit.score,
+				Contributions: hit.contributions,
+			})
+		}
+	}
+	if end < len(r.hits) {
+		page.NextCursor = encodeCursor(end, fingerprint, id)
+	}
+	return page, nil
+}
+
+// rank runs the search req describes as deep as a search ranks and counts
+// the facets of its documents.
+func (s *SearchService) rank(ctx context.Context, req SearchRequest) (*ranking, error) {
+	results, err := s.retrieval.Search(ctx, RetrievalRequest{
+		Query:         req.Query,
+		Filter:        req.Filter,
+		Limit:         maxSearchResults,
+		Fusion:        req.Fusion,
+		LexicalWeight: req.LexicalWeight,
+		VectorWeight:  req.VectorWeight,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(map[int64]*models.Document)
+	hits := make([]rankedHit, len(results))
+	for i, r := range results {
+		id := r.Chunk.DocumentID
+		hits[i] = rankedHit{chunkID: r.Chunk.ID, documentID: id, score: r.Score, contributions: r.Contributions}
+		if _, ok := docs[id]; ok {
+			continue
+		}
+		doc, err := s.docs.FindDocumentByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("loading document %d failed: %w", id, err)
+		}
+		if doc == nil {
+			return nil, fmt.Errorf("document %d not found", id)
+		}
+		docs[id] = doc
+	}
+	return &ranking{hits: hits, facets: countFacets(docs), created: time.Now()}, nil
+}
+
+// cachedRanking returns the ranking kept under id, if it has not expired.
+func (s *SearchService) cachedRanking(id uint64) (*ranking, bool) {
+	if id == 0 {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rankings[id]
+	if !ok || time.Since(r.created) > searchCacheTTL {
+		return nil, false
+	}
+	return r, true
+}
+
+// cacheRanking keeps r for paging and returns the ID it is kept under,
+// dropping expired rankings and, if there are still too many, the oldest.
+func (s *SearchService) cacheRanking(r *ranking) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.rankings {
+		if time.Since(old.created) > searchCacheTTL {
+			delete(s.rankings, id)
+		}
+	}
+	for len(s.rankings) >= searchCacheSize {
+		oldest := uint64(0)
+		for id := range s.rankings {
+			if oldest == 0 || id < oldest {
+				oldest = id
+			}
+		}
+		delete(s.rankings, oldest)
+	}
+	s.nextID++
+	s.rankings[s.nextID] = r
+	return s.nextID
+}
+
+// searchFingerprint identifies what a search asks for apart from paging,
+// so a cursor cannot be carried over to a different search.
+func searchFingerprint(req SearchRequest) uint64 {
+	data, _ := json.Marshal(struct {
+		Query         string
+		Filter        RetrievalFilter
+		Fusion        retrieval.Method
+		LexicalWeight float64
+		VectorWeight  float64
+	}{req.Query, req.Filter, req.Fusion, req.LexicalWeight, req.VectorWeight})
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// encodeCursor returns an opaque cursor for the results from offset on of
+// the ranking kept under id.
+func encodeCursor(offset int, fingerprint uint64, id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%x.%x", offset, fingerprint, id)))
+}
+
+// decodeCursor returns the offset a cursor resumes from and the ranking it
+// reads, or 0 and 0 for an empty cursor.
+func decodeCursor(cursor string, fingerprint uint64) (int, uint64, error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	parts := strings.Split(string(data), ".")
+	if len(parts) != 3 || parts[1] != strconv.FormatUint(fingerprint, 16) {
+		return 0, 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil || offset < 0 {
+		return 0, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 16, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	return offset, id, nil
+}
+
+// countFacets counts docs by author, year, keyword and file type. Authors
+// are grouped by models.Author.Key and keywords case-insensitively, each
+// shown as written in the document with the lowest ID.
+func countFacets(docs map[int64]*models.Document) Facets {
+	ids := make([]int64, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	authors, years, keywords, fileTypes := newFacet(), newFacet(), newFacet(), newFacet()
+	for _, id := range ids {
+		doc := docs[id]
+		seen := make(map[string]bool)
+		for _, a := range doc.Metadata.Authors {
+			if key := a.Key(); !seen[key] {
+				seen[key] = true
+				authors.add(key, a.String())
+			}
+		}
+		if doc.Metadata.Year != 0 {
+			year := strconv.Itoa(doc.Metadata.Year)
+			years.add(year, year)
+		}
+		seen = make(map[string]bool)
+		for _, k := range doc.Metadata.Keywords {
+			if key := strings.ToLower(k); !seen[key] {
+				seen[key] = true
+				keywords.add(key, k)
+			}
+		}
+		if doc.Metadata.FileType != "" {
+			fileTypes.add(doc.Metadata.FileType, doc.Metadata.FileType)
+		}
+	}
+	return Facets{
+		Authors:   authors.counts(),
+		Years:     years.counts(),
+		Keywords:  keywords.counts(),
+		FileTypes: fileTypes.counts(),
+	}
+}
+
+// facet counts values under a grouping key.
+type facet struct {
+	labels map[string]string
+	totals map[string]int
+}
+
+func newFacet() *facet {
+	return &facet{labels: make(map[string]string), totals: make(map[string]int)}
+}
+
+func (f *facet) add(key, label string) {
+	if _, ok := f.labels[key]; !ok {
+		f.labels[key] = label
+	}
+	f.totals[key]++
+}
+
+// counts lists the most common values, ties in label order.
+func (f *facet) counts() []FacetCount {
+	list := make([]FacetCount, 0, len(f.totals))
+	for key, n := range f.totals {
+		list = append(list, FacetCount{Value: f.labels[key], Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Value < list[j].Value
+	})
+	if len(list) > maxFacetValues {
+		list = list[:maxFacetValues]
+	}
+	return list
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"rag-go-app/embedding"
+	"rag-go-app/lexical"
+	"rag-go-app/models"
+	"rag-go-app/repositories"
+	"rag-go-app/vectorindex"
+)
+
+// searchFixture is a SearchService over in-memory repositories, a lexical
+// index and a flat vector index of hashed embeddings.
+type searchFixture struct {
+	t          *testing.T
+	docs       *repositories.InMemoryDataRepository
+	chunks     *repositories.InMemoryChunkRepository
+	embeddings *EmbeddingService
+	retrieval  *RetrievalService
+	search     *SearchService
+}
+
+func newSearchFixture(t *testing.T) *searchFixture {
+	t.Helper()
+	embedder := embedding.NewHashed(64)
+	vectors, err := vectorindex.NewFlat(embedder.Model().Dimension, vectorindex.Cosine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &searchFixture{
+		t:          t,
+		docs:       repositories.NewInMemoryDataRepository(),
+		chunks:     repositories.NewInMemoryChunkRepository(),
+		embeddings: NewEmbeddingService(repositories.NewInMemoryEmbeddingRepository(), embedder),
+	}
+	f.retrieval = NewRetrievalService(f.chunks, f.embeddings, lexical.NewIndex(lexical.English{}, lexical.DefaultBM25()), vectors)
+	f.search = NewSearchService(f.docs, f.retrieval)
+	return f
+}
+
+// add saves, embeds and indexes a document with one chunk per text.
+func (f *searchFixture) add(metadata models.Metadata, texts ...string) *models.Document {
+	f.t.Helper()
+	doc := &models.Document{Metadata: metadata}
+	if err := f.docs.SaveDocument(doc); err != nil {
+		f.t.Fatal(err)
+	}
+	chunks := make([]models.Chunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = models.Chunk{Text: text, PageStart: i + 1, PageEnd: i + 1}
+	}
+	if err := f.chunks.SaveChunks(doc.ID, chunks); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := f.embeddings.EmbedChunks(context.Background(), doc.ID, chunks); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := f.retrieval.IndexDocument(doc, chunks); err != nil {
+		f.t.Fatal(err)
+	}
+	return doc
+}
+
+// pages reads every page of the search req, returning the chunk IDs in
+// order and the first page.
+func (f *searchFixture) pages(req SearchRequest) ([]int64, *SearchPage) {
+	f.t.Helper()
+	var ids []int64
+	var first *SearchPage
+	for {
+		page, err := f.search.Search(context.Background(), req)
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		if first == nil {
+			first = page
+		}
+		for _, hit := range page.Results {
+			ids = append(ids, hit.ChunkID)
+		}
+		if page.NextCursor == "" {
+			return ids, first
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	cursor := encodeCursor(20, 0xabc, 7)
+	offset, id, err := decodeCursor(cursor, 0xabc)
+	if err != nil || offset != 20 || id != 7 {
+		t.Errorf("decodeCursor() = %d, %d, %v, want 20, 7, nil", offset, id, err)
+	}
+	if offset, id, err := decodeCursor("", 0xabc); err != nil || offset != 0 || id != 0 {
+		t.Errorf("empty cursor = %d, %d, %v, want 0, 0, nil", offset, id, err)
+	}
+
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"other search", encodeCursor(20, 0xdef, 7)},
+		{"not base64", "not a cursor!"},
+		{"missing part", encode("20.abc")},
+		{"negative offset", encode("-1.abc.7")},
+		{"bad offset", encode("x.abc.7")},
+		{"bad ranking", encode("20.abc.zz")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor, 0xabc); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	f := newSearchFixture(t)
+	for i := range 25 {
+		f.add(models.Metadata{Title: fmt.Sprintf("Notes %d", i)}, fmt.Sprintf("Lecture %d on the voltaic battery and its discs.", i))
+	}
+	req := SearchRequest{Query: "battery", Limit: 10}
+
+	ids, first := f.pages(req)
+	if first.Total != 25 || first.TotalCapped {
+		t.Errorf("total = %d, capped = %v, want 25 and not capped", first.Total, first.TotalCapped)
+	}
+	if len(ids) != 25 {
+		t.Errorf("paged through %d results, want 25", len(ids))
+	}
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("chunk %d is on more than one page", id)
+		}
+		seen[id] = true
+	}
+
+	// The cursor belongs to this search only
+	other := req
+	other.Query = "discs"
+	other.Cursor = first.NextCursor
+	if _, err := f.search.Search(context.Background(), other); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor for another query: err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestSearchKeepsRanking(t *testing.T) {
+	f := newSearchFixture(t)
+	for i := range 15 {
+		f.add(models.Metadata{Title: fmt.Sprintf("Notes %d", i)}, fmt.Sprintf("Lecture %d on the voltaic battery and its discs.", i))
+	}
+	req := SearchRequest{Query: "battery", Limit: 10}
+	first, err := f.search.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A document indexed after the first page does not show up on the
+	// second, which reads the ranking the first page made
+	added := f.add(models.Metadata{Title: "Late"}, "Battery battery battery.")
+	req.Cursor = first.NextCursor
+	second, err := f.search.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Total != 15 || len(second.Results) != 5 || second.NextCursor != "" {
+		t.Errorf("second page has %d of %d results, next cursor %q, want the last 5 of 15", len(second.Results), second.Total, second.NextCursor)
+	}
+	for _, hit := range second.Results {
+		if hit.DocumentID == added.ID {
+			t.Errorf("second page has chunk %d of a document indexed after the search", hit.ChunkID)
+		}
+	}
+
+	// Once the ranking expires the cursor runs the search again
+	f.search.mu.Lock()
+	for _, r := range f.search.rankings {
+		r.created = time.Now().Add(-searchCacheTTL - time.Minute)
+	}
+	f.search.mu.Unlock()
+	again, err := f.search.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Total != 16 || len(again.Results) != 6 {
+		t.Errorf("after expiry the page has %d of %d results, want 6 of 16", len(again.Results), again.Total)
+	}
+}
+
+func TestSearchTotalCapped(t *testing.T) {
+	// Lexical only, to keep the thousand chunks quick to index
+	docs := repositories.NewInMemoryDataRepository()
+	chunks := repositories.NewInMemoryChunkRepository()
+	retrieval := NewRetrievalService(chunks, nil, lexical.NewIndex(lexical.English{}, lexical.DefaultBM25()), nil)
+	doc := &models.Document{}
+	if err := docs.SaveDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	c := make([]models.Chunk, maxSearchResults+1)
+	for i := range c {
+		c[i] = models.Chunk{Text: fmt.Sprintf("Battery note %d.", i)}
+	}
+	if err := chunks.SaveChunks(doc.ID, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := retrieval.IndexDocument(doc, c); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := NewSearchService(docs, retrieval).Search(context.Background(), SearchRequest{Query: "battery", Limit: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != maxSearchResults || !page.TotalCapped {
+		t.Errorf("total = %d, capped = %v, want %d and capped", page.Total, page.TotalCapped, maxSearchResults)
+	}
+	if len(page.Results) != MaxSearchLimit {
+		t.Errorf("page has %d results, want the maximum %d", len(page.Results), MaxSearchLimit)
+	}
+}
+
+func TestSearchFacets(t *testing.T) {
+	f := newSearchFixture(t)
+	f.add(models.Metadata{
+		Title:    "Batteries",
+		Authors:  []models.Author{{Family: "Volta", Initials: "A."}, {Given: "Georg", Family: "Ohm", Initials: "G."}},
+		Keywords: []string{"Electricity", "electricity", "Batteries"},
+		Year:     1800,
+		FileType: "pdf",
+	}, "The voltaic battery stacks zinc and copper discs.", "A battery of discs gives a steady current.")
+	f.add(models.Metadata{
+		Title:    "More batteries",
+		Authors:  []models.Author{{Given: "Alessandro", Family: "Volta", Initials: "A"}},
+		Keywords: []string{"ELECTRICITY"},
+		Year:     1800,
+		FileType: "docx",
+	}, "Improving the battery.")
+	f.add(models.Metadata{Title: "Unrelated", Authors: []models.Author{{Family: "Faraday", Initials: "M."}}, Year: 1831}, "Induction in coils.")
+
+	// Lexical only, as the vector retriever ranks every chunk and would
+	// count the unrelated document too
+	page, err := f.search.Search(context.Background(), SearchRequest{Query: "battery", Limit: 1, LexicalWeight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Facets count documents, not chunks, across every page; authors are
+	// grouped by family name and first initial and keywords by case, each
+	// labelled as the first document writes it
+	tests := []struct {
+		name string
+		got  []FacetCount
+		want []FacetCount
+	}{
+		{"authors", page.Facets.Authors, []FacetCount{{"Volta, A.", 2}, {"Ohm, G.", 1}}},
+		{"years", page.Facets.Years, []FacetCount{{"1800", 2}}},
+		{"keywords", page.Facets.Keywords, []FacetCount{{"Electricity", 2}, {"Batteries", 1}}},
+		{"file types", page.Facets.FileTypes, []FacetCount{{"docx", 1}, {"pdf", 1}}},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestSearchSnippetEscapes(t *testing.T) {
+	f := newSearchFixture(t)
+	f.add(models.Metadata{Title: "Markup"}, `A <script>battery</script> & "discs".`)
+
+	page, err := f.search.Search(context.Background(), SearchRequest{Query: "battery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(page.Results))
+	}
+	want := `A &lt;script&gt;<mark>battery</mark>&lt;/script&gt; &amp; &#34;discs&#34;.`
+	if got := page.Results[0].Snippet; got != want {
+		t.Errorf("snippet = %q, want %q", got, want)
+	}
+	if hit := page.Results[0]; hit.Title != "Markup" || hit.Page != 1 {
+		t.Errorf("hit = %+v, want page 1 of Markup", hit)
+	}
+}
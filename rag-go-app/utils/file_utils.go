@@ -10,11 +10,18 @@ const (
 	PDF  = ".pdf"
 	DOCX = ".docx"
 	PPTX = ".pptx"
+	SRT  = ".srt"
+	VTT  = ".vtt"
 )
 
+// ErrUnsupportedFileType is returned for files of a type that cannot be
+// extracted.
+var ErrUnsupportedFileType = errors.New("unsupported file format")
+
 // IsSupportedFileType checks if the file type is supported for extraction.
 func IsSupportedFileType(filePath string) bool {
-	return strings.HasSuffix(filePath, PDF) || strings.HasSuffix(filePath, DOCX) || strings.HasSuffix(filePath, PPTX)
+	_, err := GetFileType(filePath)
+	return err == nil
 }
 
 // GetFileType returns the file type based on the file extension.
@@ -25,6 +32,28 @@ func GetFileType(filePath string) (string, error) {
 		return DOCX, nil
 	} else if strings.HasSuffix(filePath, PPTX) {
 		return PPTX, nil
+	} else if strings.HasSuffix(filePath, SRT) {
+		return SRT, nil
+	} else if strings.HasSuffix(filePath, VTT) {
+		return VTT, nil
+	}
+	return "", ErrUnsupportedFileType
+}
+
+// contentTypes maps supported file types to their MIME content types.
+var contentTypes = map[string]string{
+	PDF:  "application/pdf",
+	DOCX: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	PPTX: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	SRT:  "application/x-subrip",
+	VTT:  "text/vtt",
+}
+
+// GetContentType returns the MIME content type for a supported file.
+func GetContentType(filePath string) (string, error) {
+	fileType, err := GetFileType(filePath)
+	if err != nil {
+		return "", err
 	}
-	return "", errors.New("unsupported file format")
+	return contentTypes[fileType], nil
 }
@@ -0,0 +1,100 @@
+package vectorindex
+
+import "strconv"
+
+// Metadata is what a vector is filtered on. Each key may have several
+// values, such as the authors or keywords of a document.
+type Metadata map[string][]string
+
+// Filter selects vectors by their metadata.
+type Filter interface {
+	Match(m Metadata) bool
+}
+
+// FilterFunc adapts a function to Filter.
+type FilterFunc func(m Metadata) bool
+
+// Match implements Filter.
+func (f FilterFunc) Match(m Metadata) bool {
+	return f(m)
+}
+
+// Eq matches metadata with value among the values of key.
+func Eq(key string, value string) Filter {
+	return In(key, value)
+}
+
+// In matches metadata with any of values among the values of key.
+func In(key string, values ...string) Filter {
+	want := make(map[string]bool, len(values))
+	for _, v := range values {
+		want[v] = true
+	}
+	return FilterFunc(func(m Metadata) bool {
+		for _, v := range m[key] {
+			if want[v] {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Range matches metadata with a numeric value of key between min and max
+// inclusive. Values that are not numbers never match.
+func Range(key string, min float64, max float64) Filter {
+	return FilterFunc(func(m Metadata) bool {
+		for _, v := range m[key] {
+			if n, err := strconv.ParseFloat(v, 64); err == nil && n >= min && n <= max {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// And matches metadata that every filter matches. Nil filters are ignored.
+func And(filters ...Filter) Filter {
+	return FilterFunc(func(m Metadata) bool {
+		for _, f := range filters {
+			if f != nil && !f.Match(m) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Or matches metadata that any filter matches.
+func Or(filters ...Filter) Filter {
+	return FilterFunc(func(m Metadata) bool {
+		for _, f := range filters {
+			if f != nil && f.Match(m) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not matches metadata that f does not.
+func Not(f Filter) Filter {
+	return FilterFunc(func(m Metadata) bool {
+		return !f.Match(m)
+	})
+}
+
+func matches(f Filter, m Metadata) bool {
+	return f == nil || f.Match(m)
+}
+
+func copyMetadata(m Metadata) Metadata {
+	if m == nil {
+		return nil
+	}
+	out := make(Metadata, len(m))
+	for k, v := range m {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
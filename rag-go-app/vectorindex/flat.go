@@ -0,0 +1,136 @@
+package vectorindex
+
+import "sync"
+
+// Flat is an exact index: every search scores every stored vector. It
+// needs no tuning and always finds the true top k, and is fast enough for
+// collections of up to some tens of thousands of vectors.
+type Flat struct {
+	dim    int
+	metric Metric
+
+	mu       sync.RWMutex
+	ids      []int64
+	vectors  [][]float32
+	metadata []Metadata
+	pos      map[int64]int
+}
+
+// NewFlat creates an empty exact index for vectors of dim components.
+func NewFlat(dim int, metric Metric) (*Flat, error) {
+	if err := checkMetric(metric); err != nil {
+		return nil, err
+	}
+	return &Flat{dim: dim, metric: metric, pos: make(map[int64]int)}, nil
+}
+
+// Dimension implements Index.
+func (f *Flat) Dimension() int {
+	return f.dim
+}
+
+// Metric implements Index.
+func (f *Flat) Metric() Metric {
+	return f.metric
+}
+
+// Len implements Index.
+func (f *Flat) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.ids)
+}
+
+// Insert implements Index.
+func (f *Flat) Insert(id int64, vector []float32, metadata Metadata) error {
+	v, err := prepare(vector, f.dim, f.metric)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.pos[id]; exists {
+		return ErrExists
+	}
+	f.pos[id] = len(f.ids)
+	f.ids = append(f.ids, id)
+	f.vectors = append(f.vectors, v)
+	f.metadata = append(f.metadata, copyMetadata(metadata))
+	return nil
+}
+
+// Update implements Index.
+func (f *Flat) Update(id int64, vector []float32, metadata Metadata) error {
+	v, err := prepare(vector, f.dim, f.metric)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, exists := f.pos[id]
+	if !exists {
+		return ErrNotFound
+	}
+	f.vectors[i] = v
+	f.metadata[i] = copyMetadata(metadata)
+	return nil
+}
+
+// Delete implements Index.
+func (f *Flat) Delete(id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, exists := f.pos[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	// Move the last vector into the gap
+	last := len(f.ids) - 1
+	f.ids[i], f.vectors[i], f.metadata[i] = f.ids[last], f.vectors[last], f.metadata[last]
+	f.pos[f.ids[i]] = i
+	f.ids, f.vectors, f.metadata = f.ids[:last], f.vectors[:last], f.metadata[:last]
+	delete(f.pos, id)
+	return nil
+}
+
+// Get implements Index. The vector is returned as stored, normalized for
+// cosine similarity.
+func (f *Flat) Get(id int64) ([]float32, Metadata, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	i, exists := f.pos[id]
+	if !exists {
+		return nil, nil, false
+	}
+	return append([]float32(nil), f.vectors[i]...), copyMetadata(f.metadata[i]), true
+}
+
+// Search implements Index.
+func (f *Flat) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	q, err := prepare(query, f.dim, f.metric)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	top := topK{k: k}
+	for i, v := range f.vectors {
+		if matches(filter, f.metadata[i]) {
+			top.offer(scored{node: int32(i), score: dot(q, v)})
+		}
+	}
+
+	best := top.sorted()
+	results := make([]Result, len(best))
+	for i, s := range best {
+		results[i] = Result{ID: f.ids[s.node], Score: s.score, Metadata: copyMetadata(f.metadata[s.node])}
+	}
+	return results, nil
+}
@@ -0,0 +1,460 @@
+package vectorindex
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+)
+
+// HNSWConfig tunes an HNSW index. M is the number of links each vector
+// keeps per layer, twice that on the bottom layer; more links raise recall
+// and memory use. EfConstruction and EfSearch are how many candidates are
+// kept while inserting and searching; higher values raise recall and cost
+// time. Seed makes the layer assignment, and so the graph, reproducible.
+type HNSWConfig struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+	Seed           int64
+}
+
+// DefaultHNSWConfig returns settings that give high recall for typical
+// text embeddings.
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{M: 16, EfConstruction: 200, EfSearch: 64, Seed: 1}
+}
+
+// HNSW is an approximate index over a hierarchical navigable small world
+// graph (Malkov and Yashunin, 2016). Searches visit a small part of the
+// collection, so they stay fast as it grows, but may miss some of the true
+// top k.
+//
+// Writes are serialized among themselves, but an insert only blocks
+// searches while it links the new vector into the graph, not while it
+// looks for the vector's neighbours. Deleted vectors stay in the graph as
+// waypoints and are left out of results; once they outnumber the live
+// ones the graph is rebuilt without them.
+type HNSW struct {
+	dim       int
+	metric    Metric
+	cfg       HNSWConfig
+	levelMult float64
+
+	// write serializes writers, which lets an insert search the graph
+	// under a read lock knowing nobody else will change it
+	write sync.Mutex
+	rng   *rand.Rand
+
+	mu       sync.RWMutex
+	nodes    []*hnswNode
+	byID     map[int64]int32
+	entry    int32
+	maxLevel int
+	deleted  int
+}
+
+type hnswNode struct {
+	id       int64
+	vector   []float32
+	metadata Metadata
+	// links holds the neighbours of the node on each layer it is on
+	links   [][]int32
+	deleted bool
+}
+
+// minRebuild is the fewest deleted vectors that trigger a rebuild, so that
+// small indexes are not rebuilt over and over.
+const minRebuild = 64
+
+// NewHNSW creates an empty HNSW index for vectors of dim components. Zero
+// fields of cfg take their values from DefaultHNSWConfig.
+func NewHNSW(dim int, metric Metric, cfg HNSWConfig) (*HNSW, error) {
+	if err := checkMetric(metric); err != nil {
+		return nil, err
+	}
+	def := DefaultHNSWConfig()
+	if cfg.M <= 1 {
+		cfg.M = def.M
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = def.EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = def.EfSearch
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = def.Seed
+	}
+	return &HNSW{
+		dim:       dim,
+		metric:    metric,
+		cfg:       cfg,
+		levelMult: 1 / math.Log(float64(cfg.M)),
+		rng:       rand.New(rand.NewSource(cfg.Seed)),
+		byID:      make(map[int64]int32),
+		entry:     -1,
+	}, nil
+}
+
+// Dimension implements Index.
+func (h *HNSW) Dimension() int {
+	return h.dim
+}
+
+// Metric implements Index.
+func (h *HNSW) Metric() Metric {
+	return h.metric
+}
+
+// Len implements Index.
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.byID)
+}
+
+// Insert implements Index.
+func (h *HNSW) Insert(id int64, vector []float32, metadata Metadata) error {
+	v, err := prepare(vector, h.dim, h.metric)
+	if err != nil {
+		return err
+	}
+
+	h.write.Lock()
+	defer h.write.Unlock()
+	h.mu.RLock()
+	_, exists := h.byID[id]
+	h.mu.RUnlock()
+	if exists {
+		return ErrExists
+	}
+	h.insert(id, v, copyMetadata(metadata))
+	return nil
+}
+
+// Update implements Index. A change of metadata alone is made in place; a
+// new vector replaces the old one in the graph.
+func (h *HNSW) Update(id int64, vector []float32, metadata Metadata) error {
+	v, err := prepare(vector, h.dim, h.metric)
+	if err != nil {
+		return err
+	}
+
+	h.write.Lock()
+	defer h.write.Unlock()
+	h.mu.Lock()
+	n, exists := h.byID[id]
+	if !exists {
+		h.mu.Unlock()
+		return ErrNotFound
+	}
+	if slices.Equal(h.nodes[n].vector, v) {
+		h.nodes[n].metadata = copyMetadata(metadata)
+		h.mu.Unlock()
+		return nil
+	}
+	h.remove(n)
+	h.mu.Unlock()
+
+	h.insert(id, v, copyMetadata(metadata))
+	h.maybeRebuild()
+	return nil
+}
+
+// Delete implements Index.
+func (h *HNSW) Delete(id int64) error {
+	h.write.Lock()
+	defer h.write.Unlock()
+	h.mu.Lock()
+	n, exists := h.byID[id]
+	if !exists {
+		h.mu.Unlock()
+		return ErrNotFound
+	}
+	h.remove(n)
+	h.mu.Unlock()
+
+	h.maybeRebuild()
+	return nil
+}
+
+// Get implements Index. The vector is returned as stored, normalized for
+// cosine similarity.
+func (h *HNSW) Get(id int64) ([]float32, Metadata, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	n, exists := h.byID[id]
+	if !exists {
+		return nil, nil, false
+	}
+	node := h.nodes[n]
+	return append([]float32(nil), node.vector...), copyMetadata(node.metadata), true
+}
+
+// Search implements Index. When the graph search turns up fewer than k
+// matches, which happens with filters that few vectors pass, the matching
+// vectors are scored exhaustively instead, so filtered searches return as
+// many results as exist.
+func (h *HNSW) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	q, err := prepare(query, h.dim, h.metric)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.entry < 0 {
+		return nil, nil
+	}
+
+	ep := scored{node: h.entry, score: dot(q, h.nodes[h.entry].vector)}
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	var best []scored
+	for _, c := range h.searchLayer(q, []scored{ep}, max(h.cfg.EfSearch, k), 0) {
+		node := h.nodes[c.node]
+		if !node.deleted && matches(filter, node.metadata) {
+			best = append(best, c)
+			if len(best) == k {
+				break
+			}
+		}
+	}
+
+	if len(best) < k && len(best) < len(h.byID) {
+		top := topK{k: k}
+		for _, n := range h.byID {
+			node := h.nodes[n]
+			if matches(filter, node.metadata) {
+				top.offer(scored{node: n, score: dot(q, node.vector)})
+			}
+		}
+		best = top.sorted()
+	}
+
+	results := make([]Result, len(best))
+	for i, c := range best {
+		node := h.nodes[c.node]
+		results[i] = Result{ID: node.id, Score: c.score, Metadata: copyMetadata(node.metadata)}
+	}
+	return results, nil
+}
+
+// insert adds a vector to the graph. The caller holds h.write but not h.mu.
+func (h *HNSW) insert(id int64, v []float32, metadata Metadata) {
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+
+	// Find the neighbours on each layer while searches carry on
+	h.mu.RLock()
+	entry, maxLevel := h.entry, h.maxLevel
+	links := make([][]int32, level+1)
+	if entry >= 0 {
+		ep := scored{node: entry, score: dot(v, h.nodes[entry].vector)}
+		for l := maxLevel; l > level; l-- {
+			ep = h.greedy(v, ep, l)
+		}
+		eps := []scored{ep}
+		for l := min(level, maxLevel); l >= 0; l-- {
+			candidates := h.searchLayer(v, eps, h.cfg.EfConstruction, l)
+			links[l] = h.selectNeighbors(candidates, h.maxLinks(l))
+			eps = candidates
+		}
+	}
+	h.mu.RUnlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := int32(len(h.nodes))
+	h.nodes = append(h.nodes, &hnswNode{id: id, vector: v, metadata: metadata, links: links})
+	h.byID[id] = n
+	for l, neighbors := range links {
+		for _, nb := range neighbors {
+			h.link(nb, n, l)
+		}
+	}
+	if entry < 0 || level > maxLevel {
+		h.entry = n
+		h.maxLevel = level
+	}
+}
+
+// remove marks node n deleted. The caller holds h.write and h.mu.
+func (h *HNSW) remove(n int32) {
+	node := h.nodes[n]
+	node.deleted = true
+	delete(h.byID, node.id)
+	h.deleted++
+	if n != h.entry {
+		return
+	}
+
+	// The entry point must be live so that searches start in the graph
+	// that is still reachable; take the live node on the highest layer
+	h.entry, h.maxLevel = -1, 0
+	for i, other := range h.nodes {
+		if !other.deleted && (h.entry < 0 || len(other.links)-1 > h.maxLevel) {
+			h.entry, h.maxLevel = int32(i), len(other.links)-1
+		}
+	}
+	if h.entry < 0 {
+		h.nodes, h.deleted = nil, 0
+	}
+}
+
+// maybeRebuild rebuilds the graph without deleted nodes once they
+// outnumber live ones. The new graph is built aside while searches use the
+// old one. The caller holds h.write but not h.mu.
+func (h *HNSW) maybeRebuild() {
+	h.mu.RLock()
+	if h.deleted < minRebuild || h.deleted <= len(h.byID) {
+		h.mu.RUnlock()
+		return
+	}
+	live := make([]*hnswNode, 0, len(h.byID))
+	for _, node := range h.nodes {
+		if !node.deleted {
+			live = append(live, node)
+		}
+	}
+	h.mu.RUnlock()
+
+	fresh := &HNSW{dim: h.dim, metric: h.metric, cfg: h.cfg, levelMult: h.levelMult, rng: h.rng, byID: make(map[int64]int32), entry: -1}
+	for _, node := range live {
+		fresh.insert(node.id, node.vector, node.metadata)
+	}
+
+	h.mu.Lock()
+	h.nodes, h.byID, h.entry, h.maxLevel, h.deleted = fresh.nodes, fresh.byID, fresh.entry, fresh.maxLevel, 0
+	h.mu.Unlock()
+}
+
+func (h *HNSW) maxLinks(layer int) int {
+	if layer == 0 {
+		return 2 * h.cfg.M
+	}
+	return h.cfg.M
+}
+
+// greedy moves from ep to ever closer neighbours on layer until none is
+// closer, and returns where it stopped.
+func (h *HNSW) greedy(q []float32, ep scored, layer int) scored {
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range h.nodes[ep.node].links[layer] {
+			if s := dot(q, h.nodes[nb].vector); s > ep.score {
+				ep = scored{node: nb, score: s}
+				changed = true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns up to ef nodes on layer closest to q, best first,
+// found by expanding outwards from eps. Deleted nodes are included.
+func (h *HNSW) searchLayer(q []float32, eps []scored, ef int, layer int) []scored {
+	visited := make([]uint64, (len(h.nodes)+63)/64)
+	candidates := maxHeap{}
+	results := topK{k: ef}
+	for _, ep := range eps {
+		visited[ep.node/64] |= 1 << (ep.node % 64)
+		candidates = append(candidates, ep)
+		results.offer(ep)
+	}
+	heap.Init(&candidates)
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(&candidates).(scored)
+		if len(results.heap) == ef && c.score < results.heap[0].score {
+			break
+		}
+		for _, nb := range h.nodes[c.node].links[layer] {
+			if visited[nb/64]&(1<<(nb%64)) != 0 {
+				continue
+			}
+			visited[nb/64] |= 1 << (nb % 64)
+			s := scored{node: nb, score: dot(q, h.nodes[nb].vector)}
+			if len(results.heap) < ef || s.score > results.heap[0].score {
+				heap.Push(&candidates, s)
+				results.offer(s)
+			}
+		}
+	}
+	return results.sorted()
+}
+
+// selectNeighbors picks up to m of candidates, given best first, to link
+// to. A candidate is preferred when it is closer to the new node than to
+// any already picked, which spreads links in different directions and
+// keeps the graph navigable; the rest fill any places left. Deleted nodes
+// are never picked.
+func (h *HNSW) selectNeighbors(candidates []scored, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		node := h.nodes[c.node]
+		if node.deleted {
+			continue
+		}
+		diverse := true
+		for _, s := range selected {
+			if dot(node.vector, h.nodes[s].vector) > c.score {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, n := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, n)
+	}
+	return selected
+}
+
+// link adds a link from node from to node to on layer. When from has too
+// many links they are pruned with the same diversity rule as
+// selectNeighbors, dropping links to deleted nodes. Keeping only the
+// closest links would cut the few that lead between clusters, leaving
+// parts of the graph unreachable. The caller holds h.mu.
+//
+// The layer's links are replaced, never changed in place, so a snapshot
+// can keep reading the old ones after the lock is released.
+func (h *HNSW) link(from int32, to int32, layer int) {
+	node := h.nodes[from]
+	links := append(slices.Clip(node.links[layer]), to)
+	if len(links) <= h.maxLinks(layer) {
+		node.links[layer] = links
+		return
+	}
+
+	candidates := make([]scored, 0, len(links))
+	for _, n := range links {
+		candidates = append(candidates, scored{node: n, score: dot(node.vector, h.nodes[n].vector)})
+	}
+	slices.SortFunc(candidates, func(a, b scored) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+	node.links[layer] = h.selectNeighbors(candidates, h.maxLinks(layer))
+}
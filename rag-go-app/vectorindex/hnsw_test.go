@@ -0,0 +1,148 @@
+package vectorindex
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// randomVectors returns n vectors of dimension dim drawn around a few
+// centres, so that neighbourhoods are uneven as they are for embeddings.
+func randomVectors(rng *rand.Rand, n, dim int) [][]float32 {
+	centres := make([][]float32, 8)
+	for i := range centres {
+		centres[i] = make([]float32, dim)
+		for j := range centres[i] {
+			centres[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		c := centres[rng.Intn(len(centres))]
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = c[j] + 0.5*float32(rng.NormFloat64())
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+// nearQueries returns n queries perturbed from stored vectors, as a query
+// is near the passages that answer it.
+func nearQueries(rng *rand.Rand, vectors [][]float32, n int) [][]float32 {
+	queries := make([][]float32, n)
+	for i := range queries {
+		q := slices.Clone(vectors[rng.Intn(len(vectors))])
+		for j := range q {
+			q[j] += 0.2 * float32(rng.NormFloat64())
+		}
+		queries[i] = q
+	}
+	return queries
+}
+
+func TestHNSWRecall(t *testing.T) {
+	tests := []struct {
+		name        string
+		metric      Metric
+		cfg         HNSWConfig
+		n           int
+		deleteEvery int
+		keepEvery   int
+		k           int
+		minRecall   float64
+	}{
+		{name: "cosine", metric: Cosine, cfg: DefaultHNSWConfig(), n: 2000, k: 10, minRecall: 0.98},
+		{name: "dot", metric: Dot, cfg: DefaultHNSWConfig(), n: 2000, k: 10, minRecall: 0.95},
+		{name: "small graph", metric: Cosine, cfg: HNSWConfig{M: 4, EfConstruction: 32, EfSearch: 32, Seed: 1}, n: 2000, k: 10, minRecall: 0.85},
+		{name: "fewer vectors than k", metric: Cosine, cfg: DefaultHNSWConfig(), n: 5, k: 10, minRecall: 1},
+		// deleted vectors stay in the graph as waypoints
+		{name: "after deletes", metric: Cosine, cfg: DefaultHNSWConfig(), n: 2000, deleteEvery: 3, k: 10, minRecall: 0.98},
+		// deleted vectors outnumber live ones, so the graph is rebuilt
+		{name: "after rebuild", metric: Cosine, cfg: DefaultHNSWConfig(), n: 2000, keepEvery: 3, k: 10, minRecall: 0.98},
+	}
+
+	const dim = 32
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(7))
+			vectors := randomVectors(rng, tt.n, dim)
+
+			flat, err := NewFlat(dim, tt.metric)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hnsw, err := NewHNSW(dim, tt.metric, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range vectors {
+				for _, idx := range []Index{flat, hnsw} {
+					if err := idx.Insert(int64(i), v, nil); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			for i := range vectors {
+				remove := tt.deleteEvery > 0 && i%tt.deleteEvery == 0 ||
+					tt.keepEvery > 0 && i%tt.keepEvery != 0
+				if !remove {
+					continue
+				}
+				for _, idx := range []Index{flat, hnsw} {
+					if err := idx.Delete(int64(i)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if hnsw.Len() != flat.Len() {
+				t.Fatalf("Len() = %d, want %d", hnsw.Len(), flat.Len())
+			}
+
+			recall, err := MeasureRecall(hnsw, flat, nearQueries(rng, vectors, 50), tt.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recall < tt.minRecall {
+				t.Errorf("recall = %.3f, want at least %.2f", recall, tt.minRecall)
+			}
+		})
+	}
+}
+
+func TestHNSWSearchHonoursFilter(t *testing.T) {
+	const dim = 16
+	rng := rand.New(rand.NewSource(3))
+	vectors := randomVectors(rng, 500, dim)
+
+	hnsw, err := NewHNSW(dim, Cosine, DefaultHNSWConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vectors {
+		parity := "even"
+		if i%2 == 1 {
+			parity = "odd"
+		}
+		if err := hnsw.Insert(int64(i), v, Metadata{"parity": {parity}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := hnsw.Search(vectors[1], 10, Eq("parity", "odd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 10 {
+		t.Fatalf("got %d results, want 10", len(results))
+	}
+	if results[0].ID != 1 {
+		t.Errorf("first result = %d, want the query's own vector 1", results[0].ID)
+	}
+	for _, r := range results {
+		if r.ID%2 != 1 {
+			t.Errorf("result %d does not match the filter", r.ID)
+		}
+	}
+}
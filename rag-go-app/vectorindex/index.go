@@ -0,0 +1,167 @@
+// Package vectorindex finds the stored vectors most similar to a query
+// without an external vector database. Flat searches exhaustively and suits
+// small collections; HNSW searches a navigable graph and scales to large
+// ones at a small cost in recall; Quantized searches compressed vectors
+// for collections too large to hold in memory at full precision.
+package vectorindex
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+)
+
+// Metric is how similarity between two vectors is scored. Higher scores
+// are more similar.
+type Metric string
+
+// Supported metrics. Cosine normalizes vectors as they are added and
+// queries as they are searched, so scores fall in [-1, 1]. Dot scores
+// vectors as they are, and suits models whose vectors are already
+// normalized or whose magnitude carries meaning.
+const (
+	Cosine Metric = "cosine"
+	Dot    Metric = "dot"
+)
+
+// Errors returned by indexes.
+var (
+	ErrDimension = errors.New("vector dimension does not match index")
+	ErrExists    = errors.New("vector already in index")
+	ErrNotFound  = errors.New("vector not in index")
+	ErrMetric    = errors.New("unsupported metric")
+)
+
+// Result is one search hit.
+type Result struct {
+	ID       int64    `json:"id"`
+	Score    float32  `json:"score"`
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+// Index stores vectors by ID with metadata for filtering. Insert fails with
+// ErrExists for an ID already stored and Update with ErrNotFound for one
+// that is not. Search returns up to k results whose metadata matches
+// filter, best first; a nil filter matches everything. Indexes are safe for
+// concurrent use, and searches may run while vectors are being written.
+type Index interface {
+	Dimension() int
+	Metric() Metric
+	Len() int
+	Insert(id int64, vector []float32, metadata Metadata) error
+	Update(id int64, vector []float32, metadata Metadata) error
+	Delete(id int64) error
+	Get(id int64) ([]float32, Metadata, bool)
+	Search(query []float32, k int, filter Filter) ([]Result, error)
+}
+
+// prepare checks v against the index and returns the copy the index will
+// store, normalized for cosine similarity.
+func prepare(v []float32, dim int, metric Metric) ([]float32, error) {
+	if len(v) != dim {
+		return nil, ErrDimension
+	}
+	out := append([]float32(nil), v...)
+	if metric == Cosine {
+		normalize(out)
+	}
+	return out, nil
+}
+
+func checkMetric(metric Metric) error {
+	if metric != Cosine && metric != Dot {
+		return ErrMetric
+	}
+	return nil
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= scale
+	}
+}
+
+func dot(a, b []float32) float32 {
+	// Four running sums let the loop overlap its multiplications
+	var s0, s1, s2, s3 float32
+	b = b[:len(a)]
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// scored is a candidate during a search. node is an index into the
+// index's own storage.
+type scored struct {
+	node  int32
+	score float32
+}
+
+// minHeap keeps the worst of the best candidates found so far on top, so
+// it can be replaced when a better one turns up.
+type minHeap []scored
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(scored)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// maxHeap keeps the best candidate on top.
+type maxHeap []scored
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].score > h[j].score }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(scored)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// topK collects the k best candidates offered to it.
+type topK struct {
+	k    int
+	heap minHeap
+}
+
+func (t *topK) offer(s scored) {
+	if len(t.heap) < t.k {
+		heap.Push(&t.heap, s)
+	} else if s.score > t.heap[0].score {
+		t.heap[0] = s
+		heap.Fix(&t.heap, 0)
+	}
+}
+
+// sorted returns the candidates best first.
+func (t *topK) sorted() []scored {
+	out := make([]scored, len(t.heap))
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&t.heap).(scored)
+	}
+	return out
+}
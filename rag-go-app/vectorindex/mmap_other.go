@@ -0,0 +1,24 @@
+//go:build !unix
+
+package vectorindex
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the whole of f into memory; platforms without mmap load
+// snapshots eagerly.
+func mapFile(f *os.File) (data []byte, release func() error, err error) {
+	data, err = io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
+
+// syncDir is a no-op where directories cannot be synced; the rename itself
+// is still atomic.
+func syncDir(dir string) error {
+	return nil
+}
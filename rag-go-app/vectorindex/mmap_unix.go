@@ -0,0 +1,37 @@
+//go:build unix
+
+package vectorindex
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the whole of f into memory read-only. Pages are loaded as
+// they are touched, so a large snapshot opens in the time it takes to read
+// its graph rather than its vectors. release unmaps the file.
+func mapFile(f *os.File) (data []byte, release func() error, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	data, err = syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
+
+// syncDir flushes the directory entry changes in dir, such as a rename, to
+// disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,270 @@
+package vectorindex
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Quantized is an exhaustive index over compressed vectors, for
+// collections whose float32 vectors do not fit in memory. Searches score
+// every compressed vector and, when reranking is enabled, rescore the best
+// candidates with the original vectors.
+type Quantized struct {
+	dim    int
+	metric Metric
+	cfg    QuantizationConfig
+
+	// write serializes writers, so training can run without holding mu
+	write sync.Mutex
+
+	mu        sync.RWMutex
+	quantizer quantizer // nil until trained
+	ids       []int64
+	pos       map[int64]int
+	metadata  []Metadata
+	codes     []byte      // codeSize bytes per vector, once trained
+	full      [][]float32 // originals, kept until trained and then if reranking
+}
+
+// NewQuantized creates an empty quantized index for vectors of dim
+// components. Zero fields of cfg take defaults; PQ defaults to one
+// subspace per eight dimensions.
+func NewQuantized(dim int, metric Metric, cfg QuantizationConfig) (*Quantized, error) {
+	if err := checkMetric(metric); err != nil {
+		return nil, err
+	}
+	if cfg.Method != QuantizeInt8 && cfg.Method != QuantizePQ {
+		return nil, fmt.Errorf("unknown quantization method %q", cfg.Method)
+	}
+	return &Quantized{dim: dim, metric: metric, cfg: cfg.withDefaults(dim), pos: make(map[int64]int)}, nil
+}
+
+// Config returns the index's configuration with defaults filled in.
+func (x *Quantized) Config() QuantizationConfig {
+	return x.cfg
+}
+
+// Trained reports whether the quantizer has been trained, after which
+// searches use compressed vectors.
+func (x *Quantized) Trained() bool {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.quantizer != nil
+}
+
+// Dimension implements Index.
+func (x *Quantized) Dimension() int {
+	return x.dim
+}
+
+// Metric implements Index.
+func (x *Quantized) Metric() Metric {
+	return x.metric
+}
+
+// Len implements Index.
+func (x *Quantized) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.ids)
+}
+
+// Insert implements Index.
+func (x *Quantized) Insert(id int64, vector []float32, metadata Metadata) error {
+	v, err := prepare(vector, x.dim, x.metric)
+	if err != nil {
+		return err
+	}
+
+	x.write.Lock()
+	defer x.write.Unlock()
+	x.mu.Lock()
+	if _, exists := x.pos[id]; exists {
+		x.mu.Unlock()
+		return ErrExists
+	}
+	x.pos[id] = len(x.ids)
+	x.ids = append(x.ids, id)
+	x.metadata = append(x.metadata, copyMetadata(metadata))
+	x.store(len(x.ids)-1, v)
+	ready := x.quantizer == nil && len(x.ids) >= x.cfg.TrainSize
+	x.mu.Unlock()
+
+	if ready {
+		x.train()
+	}
+	return nil
+}
+
+// Update implements Index.
+func (x *Quantized) Update(id int64, vector []float32, metadata Metadata) error {
+	v, err := prepare(vector, x.dim, x.metric)
+	if err != nil {
+		return err
+	}
+
+	x.write.Lock()
+	defer x.write.Unlock()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i, exists := x.pos[id]
+	if !exists {
+		return ErrNotFound
+	}
+	x.metadata[i] = copyMetadata(metadata)
+	x.store(i, v)
+	return nil
+}
+
+// Delete implements Index.
+func (x *Quantized) Delete(id int64) error {
+	x.write.Lock()
+	defer x.write.Unlock()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i, exists := x.pos[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	// Move the last vector into the gap
+	last := len(x.ids) - 1
+	x.ids[i], x.metadata[i] = x.ids[last], x.metadata[last]
+	x.pos[x.ids[i]] = i
+	x.ids, x.metadata = x.ids[:last], x.metadata[:last]
+	if x.full != nil {
+		x.full[i] = x.full[last]
+		x.full = x.full[:last]
+	}
+	if x.quantizer != nil {
+		size := x.quantizer.codeSize()
+		copy(x.codes[i*size:(i+1)*size], x.codes[last*size:])
+		x.codes = x.codes[:last*size]
+	}
+	delete(x.pos, id)
+	return nil
+}
+
+// store sets the vector at position i, which may be one past the end. The
+// caller holds x.mu.
+func (x *Quantized) store(i int, v []float32) {
+	if x.quantizer == nil || x.cfg.Rerank > 0 {
+		if i == len(x.full) {
+			x.full = append(x.full, v)
+		} else {
+			x.full[i] = v
+		}
+	}
+	if x.quantizer != nil {
+		size := x.quantizer.codeSize()
+		if i*size == len(x.codes) {
+			x.codes = append(x.codes, make([]byte, size)...)
+		}
+		x.quantizer.encode(x.codes[i*size:(i+1)*size], v)
+	}
+}
+
+// train fits the quantizer to the vectors stored so far and compresses
+// them. Searches carry on, exactly, while it fits. The caller holds
+// x.write.
+func (x *Quantized) train() {
+	x.mu.RLock()
+	sample := slices.Clone(x.full)
+	x.mu.RUnlock()
+
+	var q quantizer
+	if x.cfg.Method == QuantizePQ {
+		q = trainPQ(sample, x.dim, x.cfg.Subspaces, 1)
+	} else {
+		q = trainInt8(sample, x.dim)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	size := q.codeSize()
+	codes := make([]byte, len(x.ids)*size)
+	for i, v := range x.full {
+		q.encode(codes[i*size:(i+1)*size], v)
+	}
+	x.quantizer, x.codes = q, codes
+	if x.cfg.Rerank == 0 {
+		x.full = nil
+	}
+}
+
+// Get implements Index. Without reranking the originals are gone, and the
+// vector returned is the decoded approximation.
+func (x *Quantized) Get(id int64) ([]float32, Metadata, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	i, exists := x.pos[id]
+	if !exists {
+		return nil, nil, false
+	}
+	if x.full != nil {
+		return slices.Clone(x.full[i]), copyMetadata(x.metadata[i]), true
+	}
+	size := x.quantizer.codeSize()
+	return x.quantizer.decode(x.codes[i*size : (i+1)*size]), copyMetadata(x.metadata[i]), true
+}
+
+// Search implements Index.
+func (x *Quantized) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	q, err := prepare(query, x.dim, x.metric)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var best []scored
+	if x.quantizer == nil {
+		top := topK{k: k}
+		for i, v := range x.full {
+			if matches(filter, x.metadata[i]) {
+				top.offer(scored{node: int32(i), score: dot(q, v)})
+			}
+		}
+		best = top.sorted()
+	} else {
+		candidates := k
+		if x.cfg.Rerank > 0 {
+			candidates = k * x.cfg.Rerank
+		}
+		score := x.quantizer.scorer(q)
+		size := x.quantizer.codeSize()
+		top := topK{k: candidates}
+		for i := range x.ids {
+			if matches(filter, x.metadata[i]) {
+				top.offer(scored{node: int32(i), score: score(x.codes[i*size : (i+1)*size])})
+			}
+		}
+		best = top.sorted()
+
+		if x.cfg.Rerank > 0 {
+			for i := range best {
+				best[i].score = dot(q, x.full[best[i].node])
+			}
+			slices.SortStableFunc(best, func(a, b scored) int {
+				switch {
+				case a.score > b.score:
+					return -1
+				case a.score < b.score:
+					return 1
+				}
+				return 0
+			})
+			best = best[:min(k, len(best))]
+		}
+	}
+
+	results := make([]Result, len(best))
+	for i, s := range best {
+		results[i] = Result{ID: x.ids[s.node], Score: s.score, Metadata: copyMetadata(x.metadata[s.node])}
+	}
+	return results, nil
+}
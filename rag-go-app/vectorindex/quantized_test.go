@@ -0,0 +1,233 @@
+package vectorindex
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// embeddingVectors returns n vectors of dimension dim that, like text
+// embeddings, vary along far fewer directions than they have components:
+// clustered points in a space of latent dimensions, projected into dim
+// and blurred with a little noise. Quantizers rely on that structure;
+// randomVectors, with independent noise in every component, is the worst
+// case for them.
+func embeddingVectors(rng *rand.Rand, n, latent, dim int) [][]float32 {
+	projection := randomVectors(rng, latent, dim)
+	vectors := make([][]float32, n)
+	for i, point := range randomVectors(rng, n, latent) {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = 0.05 * float32(rng.NormFloat64())
+		}
+		for l, x := range point {
+			for j := range v {
+				v[j] += x * projection[l][j]
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+func TestQuantizedRecall(t *testing.T) {
+	// The floors sit a little under the figures measured for these
+	// vectors, at the low end of the ranges in the QuantizationConfig doc
+	tests := []struct {
+		name      string
+		cfg       QuantizationConfig
+		minRecall float64
+	}{
+		{name: "int8", cfg: QuantizationConfig{Method: QuantizeInt8}, minRecall: 0.95},
+		{name: "int8 reranked", cfg: QuantizationConfig{Method: QuantizeInt8, Rerank: 4}, minRecall: 0.99},
+		{name: "pq", cfg: QuantizationConfig{Method: QuantizePQ}, minRecall: 0.5},
+		{name: "pq reranked", cfg: QuantizationConfig{Method: QuantizePQ, Rerank: 10}, minRecall: 0.95},
+		{name: "pq finer subspaces", cfg: QuantizationConfig{Method: QuantizePQ, Subspaces: 16}, minRecall: 0.6},
+	}
+
+	const dim, n = 64, 2000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(11))
+			vectors := embeddingVectors(rng, n, 12, dim)
+
+			cfg := tt.cfg
+			cfg.TrainSize = 1000
+			quantized, err := NewQuantized(dim, Cosine, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flat, err := NewFlat(dim, Cosine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range vectors {
+				for _, idx := range []Index{flat, quantized} {
+					if err := idx.Insert(int64(i), v, nil); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if !quantized.Trained() {
+				t.Fatal("quantizer was not trained")
+			}
+
+			recall, err := MeasureRecall(quantized, flat, nearQueries(rng, vectors, 100), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recall < tt.minRecall {
+				t.Errorf("recall = %.3f, want at least %.2f", recall, tt.minRecall)
+			}
+		})
+	}
+}
+
+func TestQuantizedDelete(t *testing.T) {
+	const dim = 16
+	tests := []struct {
+		name string
+		cfg  QuantizationConfig
+	}{
+		{"int8", QuantizationConfig{Method: QuantizeInt8, TrainSize: 50}},
+		{"int8 reranked", QuantizationConfig{Method: QuantizeInt8, TrainSize: 50, Rerank: 4}},
+		{"pq reranked", QuantizationConfig{Method: QuantizePQ, Subspaces: 4, TrainSize: 256, Rerank: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(5))
+			vectors := randomVectors(rng, 300, dim)
+			x, err := NewQuantized(dim, Cosine, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range vectors {
+				if err := x.Insert(int64(i), v, Metadata{"n": {string(rune('a' + i%26))}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !x.Trained() {
+				t.Fatal("quantizer was not trained")
+			}
+			// vectors before training were encoded by train, and the rest
+			// by store; delete from both ranges, and the last position
+			for i := 0; i < len(vectors); i += 3 {
+				if err := x.Delete(int64(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := x.Delete(int64(len(vectors) - 1)); err != nil {
+				t.Fatal(err)
+			}
+			if err := x.Delete(0); err != ErrNotFound {
+				t.Errorf("deleting twice: err = %v, want ErrNotFound", err)
+			}
+
+			live := 0
+			for i, v := range vectors {
+				deleted := i%3 == 0 || i == len(vectors)-1
+				got, metadata, ok := x.Get(int64(i))
+				if ok == deleted {
+					t.Fatalf("vector %d stored = %v, want %v", i, ok, !deleted)
+				}
+				if deleted {
+					continue
+				}
+				live++
+				if metadata["n"][0] != string(rune('a'+i%26)) {
+					t.Errorf("vector %d has metadata %v", i, metadata)
+				}
+				if sim := cosine(got, v); sim < 0.9 {
+					t.Errorf("vector %d decodes with cosine %.3f to the original", i, sim)
+				}
+			}
+			if x.Len() != live {
+				t.Errorf("Len() = %d, want %d", x.Len(), live)
+			}
+			if size := x.quantizer.codeSize(); len(x.codes) != live*size {
+				t.Errorf("codes hold %d bytes, want %d", len(x.codes), live*size)
+			}
+			if x.full != nil && len(x.full) != live {
+				t.Errorf("originals hold %d vectors, want %d", len(x.full), live)
+			}
+		})
+	}
+}
+
+func TestStoreSnapshotKeepsTrainedQuantizer(t *testing.T) {
+	const dim = 16
+	tests := []struct {
+		name string
+		cfg  QuantizationConfig
+	}{
+		{"int8", QuantizationConfig{Method: QuantizeInt8, TrainSize: 50}},
+		{"int8 reranked", QuantizationConfig{Method: QuantizeInt8, TrainSize: 50, Rerank: 4}},
+		{"pq", QuantizationConfig{Method: QuantizePQ, Subspaces: 4, TrainSize: 256}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(9))
+			vectors := randomVectors(rng, 300, dim)
+			queries := nearQueries(rng, vectors, 20)
+			dir := t.TempDir()
+			opts := Options{Kind: KindQuantized, Dimension: dim, Metric: Cosine, Quantization: tt.cfg, SnapshotEvery: -1}
+
+			s, err := Open(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range vectors {
+				if err := s.Insert(int64(i), v, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := make([][]Result, len(queries))
+			for i, q := range queries {
+				if want[i], err = s.Search(q, 10, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Snapshot(); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err = Open(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			x, ok := s.index.(*Quantized)
+			if !ok || !x.Trained() {
+				t.Fatalf("reopened index is %T, trained = %v", s.index, ok && x.Trained())
+			}
+			for i, q := range queries {
+				got, err := s.Search(q, 10, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != len(want[i]) {
+					t.Fatalf("query %d: %d results, want %d", i, len(got), len(want[i]))
+				}
+				for j := range got {
+					if got[j].ID != want[i][j].ID || math.Abs(float64(got[j].Score-want[i][j].Score)) > 1e-5 {
+						t.Errorf("query %d result %d = %+v, want %+v", i, j, got[j], want[i][j])
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	var ab, aa, bb float64
+	for i := range a {
+		ab += float64(a[i]) * float64(b[i])
+		aa += float64(a[i]) * float64(a[i])
+		bb += float64(b[i]) * float64(b[i])
+	}
+	return ab / math.Sqrt(aa*bb)
+}
@@ -0,0 +1,555 @@
+package vectorindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"unsafe"
+)
+
+// Snapshot file layout, all integers little-endian:
+//
+//	header   snapshotHeaderSize bytes, see below
+//	records  quantizer parameters if any, then one per node: id, flags,
+//	         metadata, links per layer
+//	codes    count*codeSize bytes of compressed vectors, if quantized
+//	padding  to a multiple of vectorAlign
+//	vectors  count*dim float32, node i at [i*dim, (i+1)*dim), unless a
+//	         quantized index has discarded them
+//
+// Vectors come last and aligned so that a memory-mapped file can serve them
+// in place. The header and records carry CRC-32 checksums; vectors do not,
+// since checking them would mean reading the whole file at startup, and the
+// file is only ever put in place whole by an atomic rename.
+const (
+	snapshotMagic      = "RGVX"
+	snapshotVersion    = 1
+	snapshotHeaderSize = 96
+	vectorAlign        = 64
+)
+
+const (
+	kindFlat      uint8 = 1
+	kindHNSW      uint8 = 2
+	kindQuantized uint8 = 3
+
+	flagDeleted uint8 = 1
+
+	quantTrained  uint8 = 1
+	quantHasFull  uint8 = 2
+	quantInt8Code uint8 = 1
+	quantPQCode   uint8 = 2
+)
+
+type snapshotHeader struct {
+	kind       uint8
+	metric     Metric
+	dim        int
+	count      int
+	walSeq     uint64
+	recordsLen uint64
+	recordsCRC uint32
+	vectorsOff uint64
+	// HNSW only
+	cfg      HNSWConfig
+	entry    int32
+	maxLevel int
+	// Quantized only
+	quant      QuantizationConfig
+	quantFlags uint8
+}
+
+// snapshotNode is a node as written to and read from a snapshot.
+type snapshotNode struct {
+	id       int64
+	deleted  bool
+	metadata Metadata
+	links    [][]int32
+	vector   []float32
+}
+
+func metricCode(m Metric) uint8 {
+	if m == Dot {
+		return 2
+	}
+	return 1
+}
+
+func metricFromCode(c uint8) (Metric, error) {
+	switch c {
+	case 1:
+		return Cosine, nil
+	case 2:
+		return Dot, nil
+	}
+	return "", fmt.Errorf("snapshot has unknown metric %d", c)
+}
+
+func (h *snapshotHeader) encode() []byte {
+	b := make([]byte, snapshotHeaderSize)
+	le := binary.LittleEndian
+	copy(b[0:4], snapshotMagic)
+	le.PutUint32(b[4:], snapshotVersion)
+	b[8] = h.kind
+	b[9] = metricCode(h.metric)
+	le.PutUint32(b[12:], uint32(h.dim))
+	le.PutUint64(b[16:], uint64(h.count))
+	le.PutUint64(b[24:], h.walSeq)
+	le.PutUint64(b[32:], h.recordsLen)
+	le.PutUint32(b[40:], h.recordsCRC)
+	le.PutUint64(b[48:], h.vectorsOff)
+	if h.kind == kindQuantized {
+		le.PutUint32(b[56:], uint32(h.quant.Subspaces))
+		le.PutUint32(b[60:], uint32(h.quant.TrainSize))
+		le.PutUint32(b[64:], uint32(h.quant.Rerank))
+		b[84] = quantInt8Code
+		if h.quant.Method == QuantizePQ {
+			b[84] = quantPQCode
+		}
+		b[85] = h.quantFlags
+	} else {
+		le.PutUint32(b[56:], uint32(h.cfg.M))
+		le.PutUint32(b[60:], uint32(h.cfg.EfConstruction))
+		le.PutUint32(b[64:], uint32(h.cfg.EfSearch))
+	}
+	le.PutUint32(b[68:], uint32(h.entry))
+	le.PutUint64(b[72:], uint64(h.cfg.Seed))
+	le.PutUint32(b[80:], uint32(h.maxLevel))
+	le.PutUint32(b[snapshotHeaderSize-4:], crc32.ChecksumIEEE(b[:snapshotHeaderSize-4]))
+	return b
+}
+
+func decodeSnapshotHeader(b []byte) (*snapshotHeader, error) {
+	if len(b) < snapshotHeaderSize || string(b[0:4]) != snapshotMagic {
+		return nil, errors.New("not a vector index snapshot")
+	}
+	le := binary.LittleEndian
+	if le.Uint32(b[snapshotHeaderSize-4:]) != crc32.ChecksumIEEE(b[:snapshotHeaderSize-4]) {
+		return nil, errors.New("snapshot header is corrupt")
+	}
+	if v := le.Uint32(b[4:]); v != snapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is not supported", v)
+	}
+	metric, err := metricFromCode(b[9])
+	if err != nil {
+		return nil, err
+	}
+	h := &snapshotHeader{
+		kind:       b[8],
+		metric:     metric,
+		dim:        int(le.Uint32(b[12:])),
+		count:      int(le.Uint64(b[16:])),
+		walSeq:     le.Uint64(b[24:]),
+		recordsLen: le.Uint64(b[32:]),
+		recordsCRC: le.Uint32(b[40:]),
+		vectorsOff: le.Uint64(b[48:]),
+		cfg: HNSWConfig{
+			M:              int(le.Uint32(b[56:])),
+			EfConstruction: int(le.Uint32(b[60:])),
+			EfSearch:       int(le.Uint32(b[64:])),
+			Seed:           int64(le.Uint64(b[72:])),
+		},
+		entry:    int32(le.Uint32(b[68:])),
+		maxLevel: int(le.Uint32(b[80:])),
+	}
+	if h.kind == kindQuantized {
+		h.quant = QuantizationConfig{
+			Method:    QuantizeInt8,
+			Subspaces: h.cfg.M,
+			TrainSize: h.cfg.EfConstruction,
+			Rerank:    h.cfg.EfSearch,
+		}
+		if b[84] == quantPQCode {
+			h.quant.Method = QuantizePQ
+		}
+		h.quantFlags = b[85]
+		h.cfg = HNSWConfig{}
+	}
+	return h, nil
+}
+
+// writeSnapshot writes idx to f, which must be empty. The nodes are copied
+// under the index's read lock, which is released before any I/O, so
+// writes are only held up for the copy, not while the file is written.
+// The copy shares vectors and links with the index; that is safe because
+// the indexes replace them rather than change them in place.
+func writeSnapshot(f *os.File, idx Index, walSeq uint64) error {
+	h := &snapshotHeader{metric: idx.Metric(), dim: idx.Dimension(), walSeq: walSeq, entry: -1}
+	var nodes []snapshotNode
+	var unlock func()
+	var params, codes []byte
+	withVectors := true
+	switch idx := idx.(type) {
+	case *Flat:
+		idx.mu.RLock()
+		unlock = idx.mu.RUnlock
+		h.kind = kindFlat
+		nodes = make([]snapshotNode, len(idx.ids))
+		for i := range idx.ids {
+			nodes[i] = snapshotNode{id: idx.ids[i], metadata: idx.metadata[i], vector: idx.vectors[i]}
+		}
+	case *HNSW:
+		idx.mu.RLock()
+		unlock = idx.mu.RUnlock
+		h.kind = kindHNSW
+		h.cfg = idx.cfg
+		h.entry = idx.entry
+		h.maxLevel = idx.maxLevel
+		nodes = make([]snapshotNode, len(idx.nodes))
+		for i, n := range idx.nodes {
+			// link replaces a layer's slice in n.links, so copy the layers
+			nodes[i] = snapshotNode{id: n.id, deleted: n.deleted, metadata: n.metadata, links: slices.Clone(n.links), vector: n.vector}
+		}
+	case *Quantized:
+		idx.mu.RLock()
+		unlock = idx.mu.RUnlock
+		h.kind = kindQuantized
+		h.quant = idx.cfg
+		if idx.quantizer != nil {
+			h.quantFlags |= quantTrained
+			params = idx.quantizer.appendParams(nil)
+			// Delete and Update rewrite codes in place
+			codes = slices.Clone(idx.codes)
+		}
+		withVectors = idx.full != nil
+		if withVectors {
+			h.quantFlags |= quantHasFull
+		}
+		nodes = make([]snapshotNode, len(idx.ids))
+		for i := range idx.ids {
+			nodes[i] = snapshotNode{id: idx.ids[i], metadata: idx.metadata[i]}
+			if withVectors {
+				nodes[i].vector = idx.full[i]
+			}
+		}
+	default:
+		return fmt.Errorf("cannot snapshot index of type %T", idx)
+	}
+	unlock()
+	h.count = len(nodes)
+
+	if _, err := f.Write(make([]byte, snapshotHeaderSize)); err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	crc := crc32.NewIEEE()
+	records := &countingWriter{w: io.MultiWriter(w, crc)}
+	if _, err := records.Write(params); err != nil {
+		return err
+	}
+	var buf []byte
+	for _, n := range nodes {
+		buf = encodeNode(buf[:0], n)
+		if _, err := records.Write(buf); err != nil {
+			return err
+		}
+	}
+	h.recordsLen = uint64(records.n)
+	h.recordsCRC = crc.Sum32()
+
+	if _, err := w.Write(codes); err != nil {
+		return err
+	}
+	end := snapshotHeaderSize + records.n + len(codes)
+	h.vectorsOff = uint64((end + vectorAlign - 1) / vectorAlign * vectorAlign)
+	if _, err := w.Write(make([]byte, int(h.vectorsOff)-end)); err != nil {
+		return err
+	}
+	var b [4]byte
+	for _, n := range nodes {
+		if !withVectors {
+			break
+		}
+		for _, x := range n.vector {
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(x))
+			if _, err := w.Write(b[:]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := f.WriteAt(h.encode(), 0)
+	return err
+}
+
+func encodeNode(b []byte, n snapshotNode) []byte {
+	b = binary.LittleEndian.AppendUint64(b, uint64(n.id))
+	var flags uint8
+	if n.deleted {
+		flags |= flagDeleted
+	}
+	b = append(b, flags)
+	b = appendMetadata(b, n.metadata)
+	b = binary.AppendUvarint(b, uint64(len(n.links)))
+	for _, layer := range n.links {
+		b = binary.AppendUvarint(b, uint64(len(layer)))
+		for _, nb := range layer {
+			b = binary.AppendUvarint(b, uint64(nb))
+		}
+	}
+	return b
+}
+
+func appendMetadata(b []byte, m Metadata) []byte {
+	b = binary.AppendUvarint(b, uint64(len(m)))
+	for k, values := range m {
+		b = appendString(b, k)
+		b = binary.AppendUvarint(b, uint64(len(values)))
+		for _, v := range values {
+			b = appendString(b, v)
+		}
+	}
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// readSnapshot rebuilds an index from the bytes of a snapshot. When the
+// host is little-endian the vectors are used where they lie in data, so
+// data must stay valid, and unchanged, for the life of the index.
+func readSnapshot(data []byte) (Index, *snapshotHeader, error) {
+	h, err := decodeSnapshotHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	recordsEnd := snapshotHeaderSize + h.recordsLen
+	withVectors := h.kind != kindQuantized || h.quantFlags&quantHasFull != 0
+	vectorsEnd := h.vectorsOff
+	if withVectors {
+		vectorsEnd += uint64(h.count) * uint64(h.dim) * 4
+	}
+	if recordsEnd > h.vectorsOff || vectorsEnd > uint64(len(data)) {
+		return nil, nil, errors.New("snapshot is truncated")
+	}
+	records := data[snapshotHeaderSize:recordsEnd]
+	if crc32.ChecksumIEEE(records) != h.recordsCRC {
+		return nil, nil, errors.New("snapshot records are corrupt")
+	}
+
+	d := &decoder{b: records}
+	var q quantizer
+	if h.kind == kindQuantized && h.quantFlags&quantTrained != 0 {
+		if q, err = readQuantizer(d, h.dim); err != nil {
+			return nil, nil, fmt.Errorf("snapshot quantizer is malformed: %w", err)
+		}
+	}
+
+	var vectors []float32
+	if withVectors {
+		vectors = floatsAt(data[h.vectorsOff:vectorsEnd], h.count*h.dim)
+	}
+	nodes := make([]snapshotNode, h.count)
+	for i := range nodes {
+		nodes[i] = d.node()
+		if withVectors {
+			// The capacity limit keeps appends from writing into the
+			// mapping
+			nodes[i].vector = vectors[i*h.dim : (i+1)*h.dim : (i+1)*h.dim]
+		}
+	}
+	if d.err != nil {
+		return nil, nil, fmt.Errorf("snapshot records are malformed: %w", d.err)
+	}
+
+	switch h.kind {
+	case kindFlat:
+		f, err := NewFlat(h.dim, h.metric)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, n := range nodes {
+			f.pos[n.id] = i
+			f.ids = append(f.ids, n.id)
+			f.vectors = append(f.vectors, n.vector)
+			f.metadata = append(f.metadata, n.metadata)
+		}
+		return f, h, nil
+	case kindHNSW:
+		idx, err := NewHNSW(h.dim, h.metric, h.cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Continue the level sequence differently from the one that built
+		// the graph, rather than repeating it
+		idx.rng = rand.New(rand.NewSource(h.cfg.Seed + int64(h.count)))
+		idx.nodes = make([]*hnswNode, len(nodes))
+		for i, n := range nodes {
+			for _, layer := range n.links {
+				for _, nb := range layer {
+					if nb < 0 || int(nb) >= len(nodes) {
+						return nil, nil, errors.New("snapshot graph links to a missing node")
+					}
+				}
+			}
+			idx.nodes[i] = &hnswNode{id: n.id, vector: n.vector, metadata: n.metadata, links: n.links, deleted: n.deleted}
+			if n.deleted {
+				idx.deleted++
+			} else {
+				idx.byID[n.id] = int32(i)
+			}
+		}
+		if h.entry >= int32(len(nodes)) {
+			return nil, nil, errors.New("snapshot graph entry point is missing")
+		}
+		idx.entry = h.entry
+		idx.maxLevel = h.maxLevel
+		return idx, h, nil
+	case kindQuantized:
+		idx, err := NewQuantized(h.dim, h.metric, h.quant)
+		if err != nil {
+			return nil, nil, err
+		}
+		if q != nil {
+			// Codes are small and rewritten in place by deletes, so they
+			// are copied out of the mapping
+			n := uint64(h.count * q.codeSize())
+			if recordsEnd+n > h.vectorsOff {
+				return nil, nil, errors.New("snapshot is truncated")
+			}
+			idx.quantizer = q
+			idx.codes = slices.Clone(data[recordsEnd : recordsEnd+n])
+		}
+		for i, n := range nodes {
+			idx.pos[n.id] = i
+			idx.ids = append(idx.ids, n.id)
+			idx.metadata = append(idx.metadata, n.metadata)
+			if withVectors {
+				idx.full = append(idx.full, n.vector)
+			}
+		}
+		return idx, h, nil
+	}
+	return nil, nil, fmt.Errorf("snapshot has unknown index kind %d", h.kind)
+}
+
+// littleEndian is true when the host stores integers, and floats, least
+// significant byte first, as snapshots do.
+var littleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// floatsAt returns the n little-endian float32s in b, as a view of b when
+// the host byte order and alignment allow it and as a copy otherwise.
+func floatsAt(b []byte, n int) []float32 {
+	if n == 0 {
+		return nil
+	}
+	if littleEndian && uintptr(unsafe.Pointer(&b[0]))%4 == 0 {
+		return unsafe.Slice((*float32)(unsafe.Pointer(&b[0])), n)
+	}
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return out
+}
+
+// decoder reads the records section, remembering the first error.
+type decoder struct {
+	b   []byte
+	off int
+	err error
+}
+
+var errShort = errors.New("unexpected end of data")
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b[d.off:])
+	if n <= 0 {
+		d.err = errShort
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.off+n > len(d.b) {
+		d.err = errShort
+		return nil
+	}
+	b := d.b[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) count() int {
+	n := d.uvarint()
+	// No count can exceed the bytes left, one per element at least
+	if n > uint64(len(d.b)-d.off) {
+		d.err = errShort
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.count()))
+}
+
+func (d *decoder) metadata() Metadata {
+	n := d.count()
+	if n == 0 {
+		return nil
+	}
+	m := make(Metadata, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k := d.string()
+		values := make([]string, d.count())
+		for j := range values {
+			values[j] = d.string()
+		}
+		m[k] = values
+	}
+	return m
+}
+
+func (d *decoder) node() snapshotNode {
+	var n snapshotNode
+	if b := d.bytes(9); b != nil {
+		n.id = int64(binary.LittleEndian.Uint64(b))
+		n.deleted = b[8]&flagDeleted != 0
+	}
+	n.metadata = d.metadata()
+	if layers := d.count(); layers > 0 {
+		n.links = make([][]int32, layers)
+		for l := range n.links {
+			links := make([]int32, d.count())
+			for i := range links {
+				links[i] = int32(d.uvarint())
+			}
+			n.links[l] = links
+		}
+	}
+	return n
+}
@@ -0,0 +1,545 @@
+package vectorindex
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Index kinds a Store can hold.
+const (
+	KindFlat      = "flat"
+	KindHNSW      = "hnsw"
+	KindQuantized = "quantized"
+)
+
+// DefaultSnapshotEvery is the number of logged writes after which a Store
+// takes a snapshot when Options.SnapshotEvery is zero.
+const DefaultSnapshotEvery = 100000
+
+// ErrClosed is returned by a Store after Close.
+var ErrClosed = errors.New("vector index store is closed")
+
+// Options configures a Store. Kind, Dimension, Metric, and HNSW or
+// Quantization for those kinds, describe the index created when the
+// directory holds none; an existing snapshot must
+// agree on kind, dimension and metric. SnapshotEvery is the number of
+// logged writes between automatic snapshots; a negative value leaves
+// snapshots to the caller. SyncWrites makes every write reach the disk
+// before it returns, which survives power loss as well as crashes at a
+// large cost in write throughput.
+type Options struct {
+	Kind          string
+	Dimension     int
+	Metric        Metric
+	HNSW          HNSWConfig
+	Quantization  QuantizationConfig
+	SnapshotEvery int
+	SyncWrites    bool
+}
+
+// Store is an Index kept on disk in dir as a snapshot and a write-ahead
+// log. Every write is appended to the log before it is applied, and opening
+// the store loads the latest snapshot and replays the log written since,
+// so nothing acknowledged is lost in a crash. Snapshots are written to a
+// temporary file and renamed into place, so a crash mid-snapshot leaves
+// the previous one intact, and are memory-mapped when loaded so a large
+// index is serving within moments of opening.
+//
+// Close must not be called while other calls are in progress, and the
+// store must not be used after it.
+type Store struct {
+	dir   string
+	opts  Options
+	index Index
+
+	// mu serializes writes, keeping the log in the order writes are
+	// applied
+	mu       sync.Mutex
+	wal      *os.File
+	walSeq   uint64
+	pending  int
+	closed   atomic.Bool
+	releases []func() error
+
+	// snapshotMu allows one snapshot at a time
+	snapshotMu   sync.Mutex
+	snapshotting bool
+	background   sync.WaitGroup
+}
+
+const (
+	snapshotFile = "snapshot.bin"
+	walPrefix    = "wal-"
+	walSuffix    = ".log"
+)
+
+// Open opens the store in dir, creating dir and an empty index if needed.
+func Open(dir string, opts Options) (*Store, error) {
+	if opts.SnapshotEvery == 0 {
+		opts.SnapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating index directory failed: %w", err)
+	}
+	s := &Store{dir: dir, opts: opts}
+
+	var walSeq uint64
+	if err := s.loadSnapshot(&walSeq); err != nil {
+		s.release()
+		return nil, err
+	}
+	if s.index == nil {
+		idx, err := newIndex(opts)
+		if err != nil {
+			return nil, err
+		}
+		s.index = idx
+	}
+
+	seqs, err := s.walSeqs()
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+	last := walSeq
+	for _, seq := range seqs {
+		if seq < walSeq {
+			// Left behind by a crash after the snapshot that covers it
+			os.Remove(s.walPath(seq))
+			continue
+		}
+		if err := s.replay(seq); err != nil {
+			s.release()
+			return nil, err
+		}
+		last = seq
+	}
+
+	// New writes go to a log of their own, so replayed logs are never
+	// appended to after a torn tail
+	if err := s.startWAL(last + 1); err != nil {
+		s.release()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newIndex(opts Options) (Index, error) {
+	switch opts.Kind {
+	case KindFlat:
+		return NewFlat(opts.Dimension, opts.Metric)
+	case KindHNSW, "":
+		return NewHNSW(opts.Dimension, opts.Metric, opts.HNSW)
+	case KindQuantized:
+		return NewQuantized(opts.Dimension, opts.Metric, opts.Quantization)
+	}
+	return nil, fmt.Errorf("unknown index kind %q", opts.Kind)
+}
+
+func (s *Store) loadSnapshot(walSeq *uint64) error {
+	f, err := os.Open(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening index snapshot failed: %w", err)
+	}
+	defer f.Close()
+
+	data, release, err := mapFile(f)
+	if err != nil {
+		return fmt.Errorf("mapping index snapshot failed: %w", err)
+	}
+	s.releases = append(s.releases, release)
+	idx, h, err := readSnapshot(data)
+	if err != nil {
+		return fmt.Errorf("loading index snapshot failed: %w", err)
+	}
+
+	kind := KindHNSW
+	switch h.kind {
+	case kindFlat:
+		kind = KindFlat
+	case kindQuantized:
+		kind = KindQuantized
+	}
+	if s.opts.Kind != "" && s.opts.Kind != kind {
+		return fmt.Errorf("index snapshot is %s, not %s", kind, s.opts.Kind)
+	}
+	if s.opts.Dimension != 0 && s.opts.Dimension != h.dim {
+		return fmt.Errorf("index snapshot has dimension %d, not %d", h.dim, s.opts.Dimension)
+	}
+	if s.opts.Metric != "" && s.opts.Metric != h.metric {
+		return fmt.Errorf("index snapshot uses %s, not %s", h.metric, s.opts.Metric)
+	}
+	s.opts.Kind, s.opts.Dimension, s.opts.Metric = kind, h.dim, h.metric
+	s.index = idx
+	*walSeq = h.walSeq
+	return nil
+}
+
+// Dimension implements Index.
+func (s *Store) Dimension() int {
+	return s.index.Dimension()
+}
+
+// Metric implements Index.
+func (s *Store) Metric() Metric {
+	return s.index.Metric()
+}
+
+// Len implements Index.
+func (s *Store) Len() int {
+	return s.index.Len()
+}
+
+// Get implements Index.
+func (s *Store) Get(id int64) ([]float32, Metadata, bool) {
+	if s.closed.Load() {
+		return nil, nil, false
+	}
+	return s.index.Get(id)
+}
+
+// Search implements Index.
+func (s *Store) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+	return s.index.Search(query, k, filter)
+}
+
+// Insert implements Index.
+func (s *Store) Insert(id int64, vector []float32, metadata Metadata) error {
+	return s.write(walInsert, id, vector, metadata)
+}
+
+// Update implements Index.
+func (s *Store) Update(id int64, vector []float32, metadata Metadata) error {
+	return s.write(walUpdate, id, vector, metadata)
+}
+
+// Delete implements Index.
+func (s *Store) Delete(id int64) error {
+	return s.write(walDelete, id, nil, nil)
+}
+
+// write checks, logs and applies one write. Writes that would fail are
+// rejected before they are logged, so replaying the log never meets one.
+func (s *Store) write(op uint8, id int64, vector []float32, metadata Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if op != walDelete && len(vector) != s.index.Dimension() {
+		return ErrDimension
+	}
+	_, _, exists := s.index.Get(id)
+	switch {
+	case op == walInsert && exists:
+		return ErrExists
+	case op != walInsert && !exists:
+		return ErrNotFound
+	}
+
+	if err := s.appendWAL(op, id, vector, metadata); err != nil {
+		return err
+	}
+	if err := apply(s.index, op, id, vector, metadata); err != nil {
+		return err
+	}
+
+	s.pending++
+	if s.opts.SnapshotEvery > 0 && s.pending >= s.opts.SnapshotEvery && !s.snapshotting {
+		s.snapshotting = true
+		s.background.Add(1)
+		go func() {
+			defer s.background.Done()
+			if err := s.Snapshot(); err != nil {
+				log.Printf("vector index snapshot failed: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// apply makes a logged write to idx. Inserts and updates both replace
+// whatever is stored and deleting a missing vector does nothing, so a
+// write can be replayed over a snapshot that already holds it.
+func apply(idx Index, op uint8, id int64, vector []float32, metadata Metadata) error {
+	_, _, exists := idx.Get(id)
+	switch {
+	case op == walDelete && exists:
+		return idx.Delete(id)
+	case op == walDelete:
+		return nil
+	case exists:
+		return idx.Update(id, vector, metadata)
+	}
+	return idx.Insert(id, vector, metadata)
+}
+
+// Snapshot writes the whole index to disk and discards the logs it makes
+// redundant. Searches carry on while it runs; writes made meanwhile are
+// logged and kept.
+func (s *Store) Snapshot() error {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.snapshotting = false
+		s.mu.Unlock()
+	}()
+
+	// Switch to a new log. Everything in the old ones is in the index
+	// already; writes from here on may or may not make it into the
+	// snapshot, and are replayed either way.
+	s.mu.Lock()
+	if s.closed.Load() {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	seq := s.walSeq + 1
+	if err := s.startWAL(seq); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.pending = 0
+	s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, ".snapshot-*")
+	if err != nil {
+		return fmt.Errorf("creating index snapshot failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeSnapshot(tmp, s.index, seq); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing index snapshot failed: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing index snapshot failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing index snapshot failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, snapshotFile)); err != nil {
+		return fmt.Errorf("storing index snapshot failed: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("syncing index directory failed: %w", err)
+	}
+
+	seqs, err := s.walSeqs()
+	if err != nil {
+		return err
+	}
+	for _, old := range seqs {
+		if old < seq {
+			os.Remove(s.walPath(old))
+		}
+	}
+	return nil
+}
+
+// Close waits for any snapshot in progress, takes a final one if there
+// are unsnapshotted writes so the next Open need not replay them, and
+// releases the files.
+func (s *Store) Close() error {
+	if s.closed.Load() {
+		return nil
+	}
+	s.background.Wait()
+	s.mu.Lock()
+	pending := s.pending
+	s.mu.Unlock()
+
+	var err error
+	if pending > 0 {
+		err = s.Snapshot()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed.Store(true)
+	if s.wal != nil {
+		if cerr := s.wal.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if rerr := s.release(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (s *Store) release() error {
+	var err error
+	for _, release := range s.releases {
+		if rerr := release(); err == nil {
+			err = rerr
+		}
+	}
+	s.releases = nil
+	return err
+}
+
+// Write-ahead log. Each record is
+//
+//	length  uint32, of the payload
+//	crc     uint32, CRC-32 of the payload
+//	payload op, id, and for inserts and updates the vector and metadata
+//
+// A record cut short by a crash, or failing its checksum, ends the log;
+// replay truncates it there.
+const (
+	walInsert uint8 = 1
+	walUpdate uint8 = 2
+	walDelete uint8 = 3
+)
+
+func (s *Store) walPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%016d%s", walPrefix, seq, walSuffix))
+}
+
+// walSeqs returns the sequence numbers of the logs in dir, oldest first.
+func (s *Store) walSeqs() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing index directory failed: %w", err)
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, walPrefix) || !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, walPrefix), walSuffix), 10, 64)
+		if err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// startWAL closes the current log and starts log seq. The caller holds
+// s.mu, or is Open.
+func (s *Store) startWAL(seq uint64) error {
+	f, err := os.OpenFile(s.walPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating index log failed: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		f.Close()
+		return fmt.Errorf("syncing index directory failed: %w", err)
+	}
+	if s.wal != nil {
+		s.wal.Sync()
+		s.wal.Close()
+	}
+	s.wal, s.walSeq = f, seq
+	return nil
+}
+
+func (s *Store) appendWAL(op uint8, id int64, vector []float32, metadata Metadata) error {
+	payload := []byte{op}
+	payload = binary.LittleEndian.AppendUint64(payload, uint64(id))
+	if op != walDelete {
+		payload = binary.AppendUvarint(payload, uint64(len(vector)))
+		for _, x := range vector {
+			payload = binary.LittleEndian.AppendUint32(payload, math.Float32bits(x))
+		}
+		payload = appendMetadata(payload, metadata)
+	}
+
+	record := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(record[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+	if _, err := s.wal.Write(record); err != nil {
+		return fmt.Errorf("writing index log failed: %w", err)
+	}
+	if s.opts.SyncWrites {
+		if err := s.wal.Sync(); err != nil {
+			return fmt.Errorf("syncing index log failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// replay applies log seq to the index, truncating a torn tail.
+func (s *Store) replay(seq uint64) error {
+	path := s.walPath(seq)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading index log failed: %w", err)
+	}
+
+	off := 0
+	for off+8 <= len(data) {
+		n := int(binary.LittleEndian.Uint32(data[off:]))
+		sum := binary.LittleEndian.Uint32(data[off+4:])
+		if off+8+n > len(data) || crc32.ChecksumIEEE(data[off+8:off+8+n]) != sum {
+			break
+		}
+		op, id, vector, metadata, err := decodeWALRecord(data[off+8 : off+8+n])
+		if err != nil {
+			return fmt.Errorf("index log %d: %w", seq, err)
+		}
+		if err := apply(s.index, op, id, vector, metadata); err != nil {
+			return fmt.Errorf("replaying index log %d failed: %w", seq, err)
+		}
+		off += 8 + n
+	}
+
+	if off < len(data) {
+		log.Printf("index log %d: discarding %d bytes of incomplete writes", seq, len(data)-off)
+		if err := os.Truncate(path, int64(off)); err != nil {
+			return fmt.Errorf("truncating index log failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func decodeWALRecord(payload []byte) (uint8, int64, []float32, Metadata, error) {
+	d := &decoder{b: payload}
+	head := d.bytes(9)
+	if head == nil {
+		return 0, 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	op := head[0]
+	id := int64(binary.LittleEndian.Uint64(head[1:]))
+	switch op {
+	case walDelete:
+		return op, id, nil, nil, nil
+	case walInsert, walUpdate:
+	default:
+		return 0, 0, nil, nil, fmt.Errorf("unknown log operation %d", op)
+	}
+
+	dim := int(d.uvarint())
+	raw := d.bytes(dim * 4)
+	metadata := d.metadata()
+	if d.err != nil {
+		return 0, 0, nil, nil, fmt.Errorf("malformed log record: %w", d.err)
+	}
+	vector := make([]float32, dim)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return op, id, vector, metadata, nil
+}
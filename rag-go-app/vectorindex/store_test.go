@@ -0,0 +1,340 @@
+package vectorindex
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// storeOp is one write made to a store under test.
+type storeOp struct {
+	op       uint8
+	id       int64
+	vector   []float32
+	metadata Metadata
+}
+
+// storeWorkload returns inserts of n vectors followed by an update and a
+// delete, so replay meets every kind of record.
+func storeWorkload(rng *rand.Rand, n, dim int) []storeOp {
+	var ops []storeOp
+	for i, v := range randomVectors(rng, n, dim) {
+		ops = append(ops, storeOp{op: walInsert, id: int64(i), vector: v, metadata: Metadata{"doc": {string(rune('a' + i%3))}}})
+	}
+	ops = append(ops,
+		storeOp{op: walUpdate, id: 3, vector: randomVectors(rng, 1, dim)[0], metadata: Metadata{"doc": {"z"}}},
+		storeOp{op: walDelete, id: 5},
+	)
+	return ops
+}
+
+// crash abandons s as a killed process would: nothing is flushed or
+// snapshotted, and the files are left as they are.
+func crash(t *testing.T, s *Store) {
+	t.Helper()
+	s.background.Wait()
+	s.closed.Store(true)
+	if err := s.wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreRecovery(t *testing.T) {
+	const dim = 8
+	tests := []struct {
+		name string
+		kind string
+		// snapshotAt takes a snapshot after that many writes, if positive
+		snapshotAt int
+		// damage alters the log that was being written at the crash, and
+		// returns how many trailing writes it destroys
+		damage func(t *testing.T, path string) int
+		// clean closes the store instead of crashing it
+		clean bool
+	}{
+		{name: "flat log only", kind: KindFlat},
+		{name: "hnsw log only", kind: KindHNSW},
+		{name: "quantized log only", kind: KindQuantized},
+		{name: "snapshot and log", kind: KindHNSW, snapshotAt: 10},
+		{name: "snapshot only", kind: KindFlat, snapshotAt: 22},
+		{name: "clean close", kind: KindHNSW, snapshotAt: 10, clean: true},
+		{name: "torn last record", kind: KindHNSW, snapshotAt: 10, damage: truncateBy(3)},
+		{name: "torn record header", kind: KindFlat, damage: truncateBy(-5)},
+		{name: "corrupt last record", kind: KindFlat, damage: corruptLastByte},
+		{name: "torn write after snapshot", kind: KindQuantized, snapshotAt: 21, damage: truncateBy(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := Options{Kind: tt.kind, Dimension: dim, Metric: Cosine, SnapshotEvery: -1}
+			if tt.kind == KindQuantized {
+				// trained after 10 vectors, so recovery rebuilds a trained
+				// quantizer
+				opts.Quantization = QuantizationConfig{Method: QuantizeInt8, TrainSize: 10, Rerank: 10}
+			}
+			ops := storeWorkload(rand.New(rand.NewSource(1)), 20, dim)
+
+			s, err := Open(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, op := range ops {
+				if err := applyOp(s, op); err != nil {
+					t.Fatal(err)
+				}
+				if i+1 == tt.snapshotAt {
+					if err := s.Snapshot(); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			lost := 0
+			if tt.clean {
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				path := s.walPath(s.walSeq)
+				crash(t, s)
+				if tt.damage != nil {
+					lost = tt.damage(t, path)
+				}
+			}
+
+			s, err = Open(dir, opts)
+			if err != nil {
+				t.Fatalf("reopening: %v", err)
+			}
+			checkStore(t, s, ops[:len(ops)-lost], dim)
+
+			// Writes after recovery must not be lost behind a torn tail
+			extra := storeOp{op: walInsert, id: 100, vector: randomVectors(rand.New(rand.NewSource(2)), 1, dim)[0]}
+			if err := applyOp(s, extra); err != nil {
+				t.Fatal(err)
+			}
+			crash(t, s)
+			s, err = Open(dir, opts)
+			if err != nil {
+				t.Fatalf("reopening after recovery: %v", err)
+			}
+			defer s.Close()
+			checkStore(t, s, append(slices.Clone(ops[:len(ops)-lost]), extra), dim)
+		})
+	}
+}
+
+func TestStoreRejectsMismatchedOptions(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Options{Kind: KindFlat, Dimension: 4, Metric: Cosine})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Insert(1, []float32{1, 0, 0, 0}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"kind", Options{Kind: KindHNSW, Dimension: 4, Metric: Cosine}},
+		{"dimension", Options{Kind: KindFlat, Dimension: 8, Metric: Cosine}},
+		{"metric", Options{Kind: KindFlat, Dimension: 4, Metric: Dot}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if s, err := Open(dir, tt.opts); err == nil {
+				s.Close()
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+// truncateBy cuts n bytes off the end of the log, cutting its last record
+// short; a negative n instead appends -n bytes of a record header that
+// never got its payload.
+func truncateBy(n int) func(t *testing.T, path string) int {
+	return func(t *testing.T, path string) int {
+		t.Helper()
+		if n < 0 {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.Write(make([]byte, -n)); err != nil {
+				t.Fatal(err)
+			}
+			return 0
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() < int64(n) {
+			t.Fatalf("log %s has only %d bytes", path, info.Size())
+		}
+		if err := os.Truncate(path, info.Size()-int64(n)); err != nil {
+			t.Fatal(err)
+		}
+		return 1
+	}
+}
+
+// corruptLastByte flips the last byte of the log, so its last record fails
+// its checksum.
+func corruptLastByte(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return 1
+}
+
+func applyOp(idx Index, op storeOp) error {
+	switch op.op {
+	case walInsert:
+		return idx.Insert(op.id, op.vector, op.metadata)
+	case walUpdate:
+		return idx.Update(op.id, op.vector, op.metadata)
+	}
+	return idx.Delete(op.id)
+}
+
+// checkStore compares s with an index that had ops applied to it.
+func checkStore(t *testing.T, s *Store, ops []storeOp, dim int) {
+	t.Helper()
+	want, err := NewFlat(dim, s.Metric())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[int64]bool{}
+	for _, op := range ops {
+		if err := applyOp(want, op); err != nil {
+			t.Fatal(err)
+		}
+		ids[op.id] = true
+	}
+
+	if s.Len() != want.Len() {
+		t.Errorf("Len() = %d, want %d", s.Len(), want.Len())
+	}
+	for id := range ids {
+		wantVector, wantMetadata, wantOK := want.Get(id)
+		gotVector, gotMetadata, gotOK := s.Get(id)
+		if gotOK != wantOK {
+			t.Errorf("vector %d stored = %v, want %v", id, gotOK, wantOK)
+			continue
+		}
+		if !wantOK {
+			continue
+		}
+		for i := range wantVector {
+			if math.Abs(float64(gotVector[i]-wantVector[i])) > 1e-5 {
+				t.Errorf("vector %d = %v, want %v", id, gotVector, wantVector)
+				break
+			}
+		}
+		if !slices.Equal(gotMetadata["doc"], wantMetadata["doc"]) {
+			t.Errorf("vector %d metadata = %v, want %v", id, gotMetadata, wantMetadata)
+		}
+	}
+}
+
+func TestWriteSnapshotWhileWriting(t *testing.T) {
+	const dim, n = 8, 400
+	tests := []Options{
+		{Kind: KindFlat},
+		{Kind: KindHNSW, HNSW: HNSWConfig{M: 4, EfConstruction: 32, EfSearch: 32, Seed: 1}},
+		{Kind: KindQuantized, Quantization: QuantizationConfig{Method: QuantizeInt8, TrainSize: 50, Rerank: 4}},
+	}
+	for _, opts := range tests {
+		t.Run(opts.Kind, func(t *testing.T) {
+			opts.Dimension, opts.Metric = dim, Cosine
+			idx, err := newIndex(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vectors := randomVectors(rand.New(rand.NewSource(3)), n, dim)
+			for i, v := range vectors[:n/2] {
+				if err := idx.Insert(int64(i), v, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Inserts and deletes carry on while snapshots are written;
+			// each snapshot must still be a consistent index
+			done := make(chan error)
+			go func() {
+				for i := n / 2; i < n; i++ {
+					if err := idx.Insert(int64(i), vectors[i], nil); err != nil {
+						done <- err
+						return
+					}
+					if i%4 == 0 {
+						if err := idx.Delete(int64(i - n/2)); err != nil {
+							done <- err
+							return
+						}
+					}
+				}
+				done <- nil
+			}()
+			for range 5 {
+				path := filepath.Join(t.TempDir(), "snapshot")
+				f, err := os.Create(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := writeSnapshot(f, idx, 1); err != nil {
+					t.Fatal(err)
+				}
+				f.Close()
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				loaded, _, err := readSnapshot(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for id := range int64(n) {
+					got, _, ok := loaded.Get(id)
+					if ok && cosine(got, vectors[id]) < 0.99 {
+						t.Fatalf("vector %d in the snapshot is not the one inserted", id)
+					}
+				}
+				if h, ok := loaded.(*HNSW); ok {
+					for _, node := range h.nodes {
+						for _, layer := range node.links {
+							for _, nb := range layer {
+								if int(nb) >= len(h.nodes) {
+									t.Fatalf("link to node %d of %d", nb, len(h.nodes))
+								}
+							}
+						}
+					}
+				}
+			}
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}